@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/fixture"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/sse"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+// benchReadyTimeout is how long bench waits for the subscription to be
+// confirmed before sending anyway.
+const benchReadyTimeout = 10 * time.Second
+
+func newBenchCmd(quiet *bool) *cobra.Command {
+	var target, url, secret, event, rate, payloadSize string
+	var duration, drain time.Duration
+	var concurrency int
+
+	cmd := &cobra.Command{
+		Use:   "bench --target <url> [--url <smee-channel>]",
+		Short: "Measure how fast a webhook relay accepts and delivers events",
+		Long: `Send signed synthetic webhooks to --target at a steady --rate for --duration,
+and print a {"type":"bench"} JSON report: how many were sent, accepted with a
+2xx, and failed, and the acceptance latency percentiles of the POSTs.
+
+With --url, bench also subscribes to that channel and matches each delivery
+ID it sends, reporting subscriber delivery latency (from the POST to the
+event arriving) and how many never arrived within --drain after the run. For
+smee.io, --url is usually the same URL as --target.
+
+Exit codes:
+  0   - Every event was accepted and, with --url, delivered
+  1   - Some events failed or were lost`,
+		Example: `  gh-pulse bench --target http://relay:8080/webhook --rate 500/s --duration 60s --payload-size 50KB
+
+  # Round trip through a smee channel
+  gh-pulse bench --target "$SMEE_URL" --url "$SMEE_URL" --rate 5/s --duration 30s`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if target == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --target"))
+			}
+			if _, err := parseRate(rate); err != nil {
+				return usageErr(cmd, fmt.Errorf("invalid --rate %q (expected N/s, N/m, or N/h)", rate))
+			}
+			if payloadSize != "" {
+				if _, err := parseSize(payloadSize); err != nil {
+					return usageErr(cmd, fmt.Errorf("--payload-size must be a size like 512B or 50KB"))
+				}
+			}
+			if duration <= 0 {
+				return usageErr(cmd, fmt.Errorf("--duration must be positive"))
+			}
+			if drain < 0 {
+				return usageErr(cmd, fmt.Errorf("--drain must be non-negative"))
+			}
+			if concurrency < 1 {
+				return usageErr(cmd, fmt.Errorf("--concurrency must be at least 1"))
+			}
+			if !slices.Contains(fixture.Events(), event) {
+				return usageErr(cmd, fmt.Errorf("no template for --event %q (known: %s)", event, strings.Join(fixture.Events(), ", ")))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.ValidateURL(target); err != nil {
+				return err
+			}
+			if url != "" {
+				if err := client.ValidateURL(url); err != nil {
+					return err
+				}
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			perSecond, _ := parseRate(rate)
+			size, _ := parseSize(payloadSize)
+			b := &bench{
+				target:      target,
+				url:         url,
+				secret:      secret,
+				event:       event,
+				rate:        perSecond,
+				interval:    time.Duration(float64(time.Second) / perSecond),
+				duration:    duration,
+				drain:       drain,
+				size:        int(size),
+				concurrency: concurrency,
+				sentAt:      make(map[string]time.Time),
+				logger:      logger,
+			}
+			return runWithSignals(b.run)
+		},
+	}
+	cmd.Flags().StringVar(&target, "target", "", "URL to POST webhooks to (required)")
+	cmd.Flags().StringVar(&url, "url", "", "channel to subscribe to for delivery latency (e.g. the same smee.io URL)")
+	cmd.Flags().StringVar(&secret, "secret", "", "webhook secret used to sign each delivery")
+	cmd.Flags().StringVar(&event, "event", "push", "event type to generate")
+	cmd.Flags().StringVar(&rate, "rate", "10/s", "how many events to send, as N/s, N/m, or N/h")
+	cmd.Flags().DurationVar(&duration, "duration", 10*time.Second, "how long to send for")
+	cmd.Flags().StringVar(&payloadSize, "payload-size", "", "pad each payload to this size, e.g. 50KB (default: the template's size)")
+	cmd.Flags().DurationVar(&drain, "drain", 10*time.Second, "with --url, how long to wait for outstanding deliveries after sending")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 32, "maximum requests in flight")
+	return cmd
+}
+
+// benchReport is the {"type":"bench"} line printed when a run ends.
+type benchReport struct {
+	Type         string  `json:"type"`
+	Target       string  `json:"target"`
+	Event        string  `json:"event"`
+	Rate         float64 `json:"rate"`
+	Seconds      float64 `json:"seconds"`
+	PayloadBytes int     `json:"payload_bytes"`
+	Sent         int     `json:"sent"`
+	Accepted     int     `json:"accepted"`
+	Failed       int     `json:"failed"`
+	// Skipped counts sends left out because --concurrency requests were
+	// already in flight.
+	Skipped      int           `json:"skipped,omitempty"`
+	StatusCodes  map[int]int   `json:"status_codes,omitempty"`
+	AcceptedRate float64       `json:"accepted_rate"`
+	Accept       *latencyStats `json:"accept_latency,omitempty"`
+	// Delivered and Lost, with --url, count the accepted events that did
+	// and did not arrive on the channel.
+	Delivered *int          `json:"delivered,omitempty"`
+	Lost      *int          `json:"lost,omitempty"`
+	Delivery  *latencyStats `json:"delivery_latency,omitempty"`
+}
+
+// latencyStats are latency percentiles in milliseconds.
+type latencyStats struct {
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+	Max float64 `json:"max_ms"`
+}
+
+// bench runs one benchmark.
+type bench struct {
+	target      string
+	url         string
+	secret      string
+	event       string
+	rate        float64
+	interval    time.Duration
+	duration    time.Duration
+	drain       time.Duration
+	size        int
+	concurrency int
+	logger      *log.Logger
+
+	mu          sync.Mutex
+	sentAt      map[string]time.Time
+	accepted    int
+	failed      int
+	statusCodes map[int]int
+	accept      []time.Duration
+	delivery    []time.Duration
+	payloadSize int
+	// allArrived is closed once sending is done and every accepted event
+	// has arrived on the channel.
+	allArrived chan struct{}
+	arrivedAll bool
+	sending    bool
+}
+
+func (b *bench) run(ctx context.Context) error {
+	subCtx, stopSub := context.WithCancel(ctx)
+	defer stopSub()
+	if b.url != "" {
+		if err := b.subscribe(subCtx); err != nil {
+			return err
+		}
+	}
+
+	if b.logger != nil {
+		b.logger.Printf("sending %s events to %s every %s for %s", b.event, b.target, b.interval, b.duration)
+	}
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	jobs := make(chan message.EventMessage, b.concurrency)
+	var workers sync.WaitGroup
+	for range b.concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range jobs {
+				b.post(ctx, httpClient, msg)
+			}
+		}()
+	}
+
+	started := time.Now()
+	sent, skipped := 0, 0
+	b.mu.Lock()
+	b.sending = true
+	b.mu.Unlock()
+	ticker := time.NewTicker(b.interval)
+	deadline := time.NewTimer(b.duration)
+send:
+	for {
+		msg, err := b.message()
+		if err != nil {
+			close(jobs)
+			ticker.Stop()
+			return err
+		}
+		select {
+		case jobs <- msg:
+			sent++
+		default:
+			// Every worker is busy: the target can't keep up with the rate.
+			skipped++
+		}
+		select {
+		case <-ctx.Done():
+			break send
+		case <-deadline.C:
+			break send
+		case <-ticker.C:
+		}
+	}
+	ticker.Stop()
+	close(jobs)
+	workers.Wait()
+	elapsed := time.Since(started)
+	b.mu.Lock()
+	b.sending = false
+	pending := len(b.sentAt)
+	b.mu.Unlock()
+
+	if b.url != "" && pending > 0 && ctx.Err() == nil {
+		if b.logger != nil {
+			b.logger.Printf("waiting up to %s for %d outstanding deliveries", b.drain, pending)
+		}
+		select {
+		case <-b.allArrived:
+		case <-time.After(b.drain):
+		case <-ctx.Done():
+		}
+	}
+	stopSub()
+
+	report := b.report(sent, skipped, elapsed)
+	if err := writeJSONLine(os.Stdout, report); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if report.Failed > 0 || report.Skipped > 0 || (report.Lost != nil && *report.Lost > 0) {
+		return exitError{code: 1}
+	}
+	return nil
+}
+
+// subscribe starts matching deliveries on the channel and waits for the
+// channel to confirm the subscription.
+func (b *bench) subscribe(ctx context.Context) error {
+	b.allArrived = make(chan struct{})
+	ready := make(chan struct{})
+	var once sync.Once
+	stream := sse.NewClient(b.url, nil)
+	stream.OnReady = func() { once.Do(func() { close(ready) }) }
+	go func() {
+		_ = stream.Run(ctx, func(msg message.EventMessage) error {
+			b.arrived(msg.DeliveryID, time.Now())
+			return nil
+		})
+	}()
+	select {
+	case <-ready:
+	case <-time.After(benchReadyTimeout):
+		if b.logger != nil {
+			b.logger.Printf("%s did not confirm the subscription within %s; sending anyway", b.url, benchReadyTimeout)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// message generates the next event, padded to the payload size.
+func (b *bench) message() (message.EventMessage, error) {
+	msg, err := fixture.Generate(b.event, fixture.Options{Repo: "gh-pulse/bench"})
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = padPayload(msg.Payload, b.size)
+	b.payloadSize = len(msg.Payload)
+	return msg, nil
+}
+
+// padPayload adds a gh_pulse_padding field to a JSON object payload so it
+// is size bytes long, when it is shorter.
+func padPayload(payload []byte, size int) []byte {
+	const field = `"gh_pulse_padding":"",`
+	n := size - len(payload) - len(field)
+	if n < 0 || len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+	var buf bytes.Buffer
+	buf.Grow(size)
+	buf.WriteString(`{"gh_pulse_padding":"`)
+	buf.WriteString(strings.Repeat("x", n))
+	buf.WriteString(`",`)
+	buf.Write(payload[1:])
+	return buf.Bytes()
+}
+
+func (b *bench) post(ctx context.Context, httpClient *http.Client, msg message.EventMessage) {
+	start := time.Now()
+	if b.url != "" {
+		b.mu.Lock()
+		b.sentAt[msg.DeliveryID] = start
+		b.mu.Unlock()
+	}
+	status, err := webhook.Post(ctx, httpClient, b.target, msg, b.secret)
+	latency := time.Since(start)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil || status < 200 || status > 299 {
+		b.failed++
+		delete(b.sentAt, msg.DeliveryID)
+		if err != nil && b.logger != nil && ctx.Err() == nil {
+			b.logger.Print(err)
+		}
+	} else {
+		b.accepted++
+		b.accept = append(b.accept, latency)
+	}
+	if status != 0 {
+		if b.statusCodes == nil {
+			b.statusCodes = make(map[int]int)
+		}
+		b.statusCodes[status]++
+	}
+}
+
+// arrived records a delivery seen on the channel.
+func (b *bench) arrived(id string, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sent, ok := b.sentAt[id]
+	if !ok {
+		return
+	}
+	delete(b.sentAt, id)
+	b.delivery = append(b.delivery, at.Sub(sent))
+	if !b.sending && len(b.sentAt) == 0 && !b.arrivedAll {
+		b.arrivedAll = true
+		close(b.allArrived)
+	}
+}
+
+func (b *bench) report(sent, skipped int, elapsed time.Duration) benchReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	report := benchReport{
+		Type:         "bench",
+		Target:       b.target,
+		Event:        b.event,
+		Rate:         b.rate,
+		Seconds:      elapsed.Seconds(),
+		PayloadBytes: b.payloadSize,
+		Sent:         sent,
+		Accepted:     b.accepted,
+		Failed:       b.failed,
+		Skipped:      skipped,
+		Accept:       summarizeLatency(b.accept),
+		StatusCodes:  b.statusCodes,
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		report.AcceptedRate = float64(b.accepted) / seconds
+	}
+	if b.url != "" {
+		delivered, lost := len(b.delivery), len(b.sentAt)
+		report.Delivered, report.Lost = &delivered, &lost
+		report.Delivery = summarizeLatency(b.delivery)
+	}
+	return report
+}
+
+// summarizeLatency returns the nearest-rank percentiles of latencies, or nil
+// when there are none.
+func summarizeLatency(latencies []time.Duration) *latencyStats {
+	if len(latencies) == 0 {
+		return nil
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p int) float64 {
+		rank := max((p*len(sorted)+99)/100, 1)
+		return float64(sorted[rank-1].Microseconds()) / 1000
+	}
+	return &latencyStats{P50: at(50), P90: at(90), P99: at(99), Max: float64(sorted[len(sorted)-1].Microseconds()) / 1000}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/kehao95/gh-pulse/internal/catalog"
+	"github.com/spf13/cobra"
+)
+
+// registerCompletions adds value completion for --event and --action to cmd
+// and every subcommand that has those flags.
+func registerCompletions(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("event") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("event", completeEvents)
+	}
+	if cmd.Flags().Lookup("action") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("action", completeActions)
+	}
+	for _, child := range cmd.Commands() {
+		registerCompletions(child)
+	}
+}
+
+func completeEvents(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return catalog.Events(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeActions suggests the actions of the events already chosen with
+// --event (or generate's event argument), or every known action.
+func completeActions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	events, _ := cmd.Flags().GetStringArray("event")
+	if len(events) == 0 && cmd.Flags().Lookup("event") == nil && len(args) > 0 {
+		events = args[:1]
+	}
+	return catalog.Actions(events...), cobra.ShellCompDirectiveNoFileComp
+}
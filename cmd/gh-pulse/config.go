@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "GH_PULSE_"
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/gh-pulse/config.yaml, falling back
+// to ~/.config/gh-pulse/config.yaml.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gh-pulse", "config.yaml")
+}
+
+// loadConfigFile reads flag defaults from a YAML file. Top-level keys are
+// flag names that apply to every command with that flag; a key naming a
+// command holds defaults for that command only. A missing file is not an
+// error unless it was requested explicitly.
+func loadConfigFile(path string, explicit bool) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyDefaults fills flags not set on the command line from GH_PULSE_*
+// environment variables, then from the config file. Flags always win, then
+// the environment, then the command's section of the file, then its top
+// level.
+func applyDefaults(cmd *cobra.Command) error {
+	path := os.Getenv(envPrefix + "CONFIG")
+	explicit := path != ""
+	if flag := cmd.Flags().Lookup("config"); flag != nil && flag.Changed {
+		path, explicit = flag.Value.String(), true
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	file, err := loadConfigFile(path, explicit)
+	if err != nil {
+		return err
+	}
+	section, _ := file[cmd.Name()].(map[string]interface{})
+
+	var applyErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if applyErr != nil || flag.Changed || flag.Name == "help" || flag.Name == "version" || flag.Name == "config" {
+			return
+		}
+		if envName, raw, ok := lookupFlagEnv(flag.Name); ok {
+			values := []string{raw}
+			if strings.HasSuffix(flag.Value.Type(), "Array") || strings.HasSuffix(flag.Value.Type(), "Slice") {
+				values = strings.Split(raw, ",")
+			}
+			if err := setFlag(cmd, flag, values); err != nil {
+				applyErr = fmt.Errorf("invalid %s: %w", envName, err)
+			}
+			return
+		}
+		value, ok := lookupFlagDefault(section, file, flag.Name)
+		if !ok {
+			return
+		}
+		var values []string
+		if list, isList := value.([]interface{}); isList {
+			for _, item := range list {
+				values = append(values, fmt.Sprint(item))
+			}
+		} else {
+			values = []string{fmt.Sprint(value)}
+		}
+		if err := setFlag(cmd, flag, values); err != nil {
+			applyErr = fmt.Errorf("invalid %s in config file %s: %w", flag.Name, path, err)
+		}
+	})
+	return applyErr
+}
+
+// flagAliases maps the other names a flag answers to onto its own.
+var flagAliases = map[string]string{"ignore-bots": "exclude-bots"}
+
+// normalizeFlagName makes an alias and its flag one flag, so it is set by
+// either name and a default never overrides it once either was given.
+func normalizeFlagName(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	if canonical, ok := flagAliases[name]; ok {
+		name = canonical
+	}
+	return pflag.NormalizedName(name)
+}
+
+// flagNames returns the names a flag's default may be given under: its own,
+// then its aliases.
+func flagNames(name string) []string {
+	names := []string{name}
+	for alias, canonical := range flagAliases {
+		if canonical == name {
+			names = append(names, alias)
+		}
+	}
+	return names
+}
+
+// lookupFlagEnv returns the GH_PULSE_* variable set for the flag, if any.
+func lookupFlagEnv(name string) (string, string, bool) {
+	for _, name := range flagNames(name) {
+		envName := envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if raw, ok := os.LookupEnv(envName); ok {
+			return envName, raw, true
+		}
+	}
+	return "", "", false
+}
+
+// lookupFlagDefault returns the flag's value from the command's section of
+// the config file, or else from its top level.
+func lookupFlagDefault(section, file map[string]interface{}, name string) (interface{}, bool) {
+	for _, name := range flagNames(name) {
+		if value, ok := section[name]; ok {
+			return value, true
+		}
+	}
+	for _, name := range flagNames(name) {
+		value, ok := file[name]
+		if _, isSection := value.(map[string]interface{}); ok && !isSection {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// setFlag applies values as if they had been passed on the command line, so
+// list flags receive one Set per value.
+func setFlag(cmd *cobra.Command, flag *pflag.Flag, values []string) error {
+	for _, value := range values {
+		if err := cmd.Flags().Set(flag.Name, strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
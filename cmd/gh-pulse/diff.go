@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/diff"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd(quiet *bool) *cobra.Command {
+	var keys []string
+
+	cmd := &cobra.Command{
+		Use:   "diff <a.jsonl> <b.jsonl>",
+		Short: "Compare two captures",
+		Long: `Compare two JSONL captures event by event.
+
+Events are matched by --key, a comma-separated list of envelope paths
+(delivery_id by default). Each difference is printed as a JSON line:
+
+  {"type":"only_in", ...}   an event present in only one capture
+  {"type":"changed", ...}   matching events whose payloads differ, with
+                            the differing paths and both values
+
+A final {"type":"summary"} line totals the comparison.
+
+Exit codes:
+  0   - Captures are identical
+  1   - Captures differ`,
+		Example: `  # Match deliveries by ID
+  gh-pulse diff before.jsonl after.jsonl
+
+  # Match by event type, repository, and action instead
+  gh-pulse diff a.jsonl b.jsonl --key event,payload.repository.full_name,payload.action`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			a, err := readEvents(args[0], logger)
+			if err != nil {
+				return err
+			}
+			b, err := readEvents(args[1], logger)
+			if err != nil {
+				return err
+			}
+
+			records, summary := diff.Compare(args[0], a, args[1], b, splitPaths(keys))
+			for _, record := range records {
+				if err := writeJSONLine(os.Stdout, record); err != nil {
+					return err
+				}
+			}
+			if err := writeJSONLine(os.Stdout, summary); err != nil {
+				return err
+			}
+			if len(records) > 0 {
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&keys, "key", []string{"delivery_id"}, "comma-separated envelope paths identifying the same event in both captures")
+	return cmd
+}
+
+// splitPaths splits comma-separated path lists, leaving commas inside
+// bracketed keys such as payload.files["a,b"] alone.
+func splitPaths(values []string) []string {
+	var paths []string
+	for _, value := range values {
+		for {
+			path, rest, found := assertion.CutPath(value, ',')
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+			if !found {
+				break
+			}
+			value = rest
+		}
+	}
+	return paths
+}
+
+// readEvents loads every event envelope from a JSONL capture file.
+func readEvents(path string, logger *log.Logger) ([]message.EventMessage, error) {
+	input, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	var events []message.EventMessage
+	err = client.Replay(context.Background(), client.Config{}, input, logger, func(msg message.EventMessage) error {
+		events = append(events, msg)
+		return nil
+	})
+	return events, err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var url string
+	var timeoutSeconds int
+
+	cmd := &cobra.Command{
+		Use:   "doctor --url <smee-channel>",
+		Short: "Diagnose connectivity to a smee.io channel",
+		Long: `Check each step between this machine and a smee.io channel: DNS, TCP,
+TLS, the event stream handshake, and a round trip of a test event through a
+temporary channel on the same host.
+
+Each check is printed as a {"type":"check"} JSON line with a hint when it
+fails, followed by a {"type":"summary"} line. If every check passes, the
+network is not the problem and missing events point at the webhook
+configuration on GitHub instead.
+
+Exit codes:
+  0   - All checks passed
+  1   - At least one check failed
+  2   - Configuration error (invalid URL)`,
+		Example: `  gh-pulse doctor --url https://smee.io/my-channel`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --url"))
+			}
+			if timeoutSeconds <= 0 {
+				return usageErr(cmd, fmt.Errorf("--timeout must be positive"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.ValidateURL(url); err != nil {
+				return err
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				report := func(result doctor.Result) error {
+					return writeJSONLine(os.Stdout, result)
+				}
+				summary, err := doctor.Run(ctx, url, time.Duration(timeoutSeconds)*time.Second, report)
+				if err != nil {
+					return err
+				}
+				if err := writeJSONLine(os.Stdout, summary); err != nil {
+					return err
+				}
+				if summary.Failed > 0 {
+					return exitError{code: 1}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", "", "smee.io channel URL (required)")
+	cmd.Flags().IntVar(&timeoutSeconds, "timeout", 10, "seconds to allow for each check")
+	return cmd
+}
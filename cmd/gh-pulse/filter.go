@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newFilterCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+
+	cmd := &cobra.Command{
+		Use:   "filter",
+		Short: "Apply stream filters and assertions to JSONL events on stdin",
+		Long: `Read JSONL events from stdin and apply the same filters, transforms, and exit
+assertions as stream, so gh-pulse can be used as a stage in a pipeline:
+
+  gh-pulse stream --url "$SMEE_URL" | gh-pulse filter --event push --jq .payload.ref
+
+Lines that are not events (e.g. result records) are skipped.
+
+Exit codes:
+  0   - Success assertion matched, or stdin ended with no success condition set
+  1   - Failure assertion matched, a deadline was missed, or stdin ended
+        before a success condition was met
+  2   - Configuration error (invalid flag values)
+  124 - Timeout reached (--timeout)
+  130 - Interrupted (Ctrl+C)`,
+		Example: `  # Split one recorder into a check for failures and a log of pushes
+  gh-pulse stream --url https://smee.io/my-channel | tee events.jsonl | gh-pulse filter --failure-on "payload.check_run.conclusion=failure"
+
+  # Assert on a saved capture
+  gh-pulse filter --success-on "event=push" < capture.jsonl`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			opts.file = "-"
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := opts.config(*quiet)
+			if err != nil {
+				return err
+			}
+			return runClient(client.Run, cfg)
+		},
+	}
+	opts.addPipelineFlags(cmd)
+	opts.addStreamFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/fixture"
+	"github.com/kehao95/gh-pulse/internal/scenario"
+	"github.com/spf13/cobra"
+)
+
+func newGenerateCmd() *cobra.Command {
+	var opts fixture.Options
+	var count int
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "generate <event> | --scenario <file|name>",
+		Short: "Print synthetic webhook events built from templates",
+		Long: fmt.Sprintf(`Generate realistic webhook events without touching GitHub.
+
+Events are printed as JSONL envelopes in the same format as stream and capture,
+so they can be piped into replay, validate, stats, or saved as test fixtures.
+Use --set path=value to override any field; paths are relative to the
+envelope (e.g. payload.pull_request.title) and values are parsed as JSON
+when possible.
+
+With --scenario, every event of a scenario (see mock-github) is printed at
+once instead, stamped with the received_at it would be played at.
+
+Built-in templates: %s
+Built-in scenarios: %s`, strings.Join(fixture.Events(), ", "), strings.Join(scenario.Builtin(), ", ")),
+		Example: `  # A push with three commits
+  gh-pulse generate push --repo me/app --commits 3
+
+  # Deliver a failed check run to a local handler
+  gh-pulse generate check_run --set payload.check_run.conclusion=failure \
+    | gh-pulse replay --file - --target http://localhost:3000/webhook
+
+  # Save a scenario's storyline as a fixture
+  gh-pulse generate --scenario pr-lifecycle > pr-lifecycle.jsonl`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: fixture.Events(),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if file != "" {
+				if len(args) > 0 {
+					return usageErr(cmd, fmt.Errorf("--scenario cannot be combined with an event argument"))
+				}
+				return nil
+			}
+			if len(args) == 0 {
+				return usageErr(cmd, fmt.Errorf("requires an event argument or --scenario"))
+			}
+			if count < 1 {
+				return usageErr(cmd, fmt.Errorf("--count must be at least 1"))
+			}
+			if opts.Commits < 0 || opts.Number < 0 {
+				return usageErr(cmd, fmt.Errorf("--commits and --number must not be negative"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := bufio.NewWriter(os.Stdout)
+			defer out.Flush()
+			if file != "" {
+				return generateScenario(out, file)
+			}
+			for range count {
+				msg, err := fixture.Generate(args[0], opts)
+				if err != nil {
+					return err
+				}
+				if err := writeJSONLine(out, msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.Repo, "repo", "", "repository as owner/name (default octo-org/octo-repo)")
+	cmd.Flags().StringVar(&opts.Sender, "sender", "", "login of the sender (default: repository owner)")
+	cmd.Flags().StringVar(&opts.Action, "action", "", "payload action (default depends on the event)")
+	cmd.Flags().StringVar(&opts.Ref, "ref", "", "git ref or branch (default refs/heads/main)")
+	cmd.Flags().IntVar(&opts.Number, "number", 1, "pull request, issue, or run number")
+	cmd.Flags().IntVar(&opts.Commits, "commits", 1, "number of commits in push and pull_request events")
+	cmd.Flags().StringVar(&opts.Tag, "tag", "", "release tag (default v1.0.0)")
+	cmd.Flags().StringArrayVar(&opts.Set, "set", nil, "override a field as path=value (can repeat)")
+	cmd.Flags().IntVar(&count, "count", 1, "number of events to generate")
+	cmd.Flags().StringVar(&file, "scenario", "", "print every event of a scenario YAML file or built-in scenario")
+	return cmd
+}
+
+// generateScenario prints a scenario's events without waiting out its
+// delays, timestamped as if it had started now.
+func generateScenario(out *bufio.Writer, file string) error {
+	s, err := scenario.Load(file)
+	if err != nil {
+		return err
+	}
+	events, err := s.Events()
+	if err != nil {
+		return err
+	}
+	start := time.Now().UTC()
+	for _, e := range events {
+		e.Message.ReceivedAt = start.Add(e.Offset)
+		if err := writeJSONLine(out, e.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
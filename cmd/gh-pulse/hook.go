@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/spf13/cobra"
+)
+
+// hookRecord is the JSON line printed for a created or deleted webhook.
+type hookRecord struct {
+	Type   string   `json:"type"`
+	Repo   string   `json:"repo"`
+	ID     int64    `json:"id"`
+	Target string   `json:"target,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+func newHookCmd(quiet *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Create and delete repository webhooks",
+		Long: `Register a repository webhook that delivers to a smee.io channel, and remove
+it again, so test environments can be provisioned and torn down without
+visiting the repository settings.
+
+Requires a token with admin access to the repository in GITHUB_TOKEN (or
+GH_TOKEN). Set GITHUB_API_URL for GitHub Enterprise Server.`,
+	}
+	cmd.AddCommand(newHookCreateCmd(quiet), newHookDeleteCmd(quiet))
+	return cmd
+}
+
+func newHookCreateCmd(quiet *bool) *cobra.Command {
+	var repo string
+	var target string
+	var events []string
+	var secret string
+
+	cmd := &cobra.Command{
+		Use:   "create --repo <owner/name> --target <smee-channel>",
+		Short: "Register a repository webhook",
+		Long: `Create a repository webhook that sends --events to --target as JSON.
+
+The new hook is printed as a {"type":"hook"} JSON line whose id can be passed
+to hook delete.`,
+		Example: `  gh-pulse hook create --repo me/app --events push,pull_request --target https://smee.io/my-channel
+
+  # Keep the id for teardown
+  HOOK_ID=$(gh-pulse hook create --repo me/app --target "$SMEE_URL" | jq .id)
+  gh-pulse hook delete --repo me/app --id "$HOOK_ID"`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if target == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --target"))
+			}
+			if len(events) == 0 {
+				return usageErr(cmd, fmt.Errorf("--events must name at least one event"))
+			}
+			for _, event := range events {
+				if strings.TrimSpace(event) == "" {
+					return usageErr(cmd, fmt.Errorf("--events must be non-empty"))
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.ValidateURL(target); err != nil {
+				return err
+			}
+			api, err := hookClient()
+			if err != nil {
+				return err
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				hook, err := api.CreateHook(ctx, repo, github.HookOptions{URL: target, Events: events, Secret: secret})
+				if err != nil {
+					return fmt.Errorf("failed to create hook on %s: %w", repo, err)
+				}
+				if !*quiet {
+					log.New(os.Stderr, "", log.LstdFlags).Printf("created hook %d on %s", hook.ID, repo)
+				}
+				return writeJSONLine(os.Stdout, hookRecord{Type: "hook", Repo: repo, ID: hook.ID, Target: hook.Config.URL, Events: hook.Events})
+			})
+		},
+	}
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().StringVar(&target, "target", "", "URL to deliver webhooks to, e.g. a smee.io channel (required)")
+	cmd.Flags().StringSliceVar(&events, "events", []string{"push"}, "events to subscribe to, comma-separated (* for all)")
+	cmd.Flags().StringVar(&secret, "secret", "", "webhook secret GitHub signs deliveries with")
+	return cmd
+}
+
+func newHookDeleteCmd(quiet *bool) *cobra.Command {
+	var repo string
+	var id int64
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "delete --repo <owner/name> (--id <hook-id> | --target <smee-channel>)",
+		Short: "Remove a repository webhook",
+		Long: `Delete a repository webhook by --id, or every hook on the repository that
+delivers to --target.
+
+Each removed hook is printed as a {"type":"hook_deleted"} JSON line. Deleting
+by --target when no hook matches is not an error, so teardown can run
+unconditionally.`,
+		Example: `  gh-pulse hook delete --repo me/app --id 123456
+  gh-pulse hook delete --repo me/app --target https://smee.io/my-channel`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if (id == 0) == (target == "") {
+				return usageErr(cmd, fmt.Errorf("exactly one of --id or --target is required"))
+			}
+			if id < 0 {
+				return usageErr(cmd, fmt.Errorf("--id must be positive"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := hookClient()
+			if err != nil {
+				return err
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				var hooks []github.Hook
+				if id != 0 {
+					hooks = append(hooks, github.Hook{ID: id})
+				} else {
+					all, err := api.ListHooks(ctx, repo)
+					if err != nil {
+						return fmt.Errorf("failed to list hooks on %s: %w", repo, err)
+					}
+					for _, hook := range all {
+						if strings.TrimRight(hook.Config.URL, "/") == strings.TrimRight(target, "/") {
+							hooks = append(hooks, hook)
+						}
+					}
+					if len(hooks) == 0 && logger != nil {
+						logger.Printf("no hook on %s delivers to %s", repo, target)
+					}
+				}
+				for _, hook := range hooks {
+					if err := api.DeleteHook(ctx, repo, hook.ID); err != nil {
+						return fmt.Errorf("failed to delete hook %d on %s: %w", hook.ID, repo, err)
+					}
+					if logger != nil {
+						logger.Printf("deleted hook %d on %s", hook.ID, repo)
+					}
+					if err := writeJSONLine(os.Stdout, hookRecord{Type: "hook_deleted", Repo: repo, ID: hook.ID, Target: hook.Config.URL}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().Int64Var(&id, "id", 0, "ID of the hook to delete")
+	cmd.Flags().StringVar(&target, "target", "", "delete every hook that delivers to this URL")
+	return cmd
+}
+
+// hookClient returns a GitHub API client authenticated from the environment.
+func hookClient() (*github.Client, error) {
+	token := github.TokenFromEnv()
+	if token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN or GH_TOKEN must be set")
+	}
+	return github.NewClient("", token), nil
+}
+
+func validateRepo(repo string) error {
+	if repo == "" {
+		return fmt.Errorf("missing required flag: --repo")
+	}
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("--repo must be owner/name, got %q", repo)
+	}
+	return nil
+}
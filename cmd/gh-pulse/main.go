@@ -4,14 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"os/exec"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/kehao95/gh-pulse/internal/assertion"
 	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/kehao95/gh-pulse/internal/route"
+	"github.com/kehao95/gh-pulse/internal/secret"
+	"github.com/kehao95/gh-pulse/internal/sse"
 	"github.com/spf13/cobra"
 )
 
@@ -82,7 +89,12 @@ func main() {
 
 Events are emitted as JSON Lines (one event per line) so you can pipe them
 into scripts, filters, and assertion checks. Use stream for live output or
-capture to buffer events until an exit condition is met.`,
+capture to buffer events until an exit condition is met.
+
+Flags not given on the command line default to GH_PULSE_<FLAG> environment
+variables (e.g. GH_PULSE_URL, GH_PULSE_EVENT=push,pull_request), then to the
+config file, whose top-level keys are flag names and whose per-command
+sections (e.g. "capture:") apply to one command.`,
 	}
 	rootCmd.Version = version
 	rootCmd.SilenceUsage = true
@@ -93,18 +105,14 @@ capture to buffer events until an exit condition is met.`,
 		return usageErr(cmd, err)
 	})
 
-	var streamURL string
-	var events []string
-	var successOn []string
-	var failureOn []string
-	var timeoutSeconds int
 	var quiet bool
-	var captureURL string
-	var captureEvents []string
-	var captureSuccessOn []string
-	var captureFailureOn []string
-	var captureTimeoutSeconds int
+	var streamOpts runOptions
+	var captureOpts runOptions
 	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "suppress non-JSON log output")
+	rootCmd.PersistentFlags().String("config", "", "YAML file of flag defaults (default ~/.config/gh-pulse/config.yaml)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return applyDefaults(cmd)
+	}
 	streamCmd := &cobra.Command{
 		Use:   "stream --url <smee-channel>",
 		Short: "Stream GitHub webhooks as JSONL to stdout",
@@ -114,9 +122,10 @@ Each webhook received is printed as a single JSON line to stdout.
 Connection status and errors go to stderr.
 
 Exit codes:
-  0   - Success assertion matched (--success-on)
-  1   - Failure assertion matched (--failure-on)
+  0   - Success assertion matched (--success-on, --success-on-all, --until)
+  1   - Failure assertion matched (--failure-on) or deadline missed (--deadline)
   2   - Configuration error (invalid flag values)
+  69  - Channel unreachable (--fail-fast, --max-retries)
   124 - Timeout reached (--timeout)
   130 - Interrupted (Ctrl+C)`,
 		Example: `  # Stream all events
@@ -125,53 +134,45 @@ Exit codes:
   # Wait for push, exit 0 when received
   gh-pulse stream --url https://smee.io/my-channel --success-on "event=push" --timeout 60
 
+  # Succeed once the check suite passed and the deployment succeeded, in any order
+  gh-pulse stream --url https://smee.io/my-channel --success-on-all "payload.check_suite.conclusion=success" --success-on-all "payload.deployment_status.state=success"
+
+  # Succeed after a push plus three check runs, unless a check run failed first
+  gh-pulse stream --url https://smee.io/my-channel --until "event=push && count(event=check_run) >= 3 && !payload.check_run.conclusion=failure"
+
+  # Fail if any workflow run takes longer than 10 minutes to complete
+  gh-pulse stream --url https://smee.io/my-channel --event workflow_run --deadline "payload.action=requested => payload.action=completed within 10m by payload.workflow_run.id"
+
+  # Keep streaming until check runs stop arriving for 30 seconds
+  gh-pulse stream --url https://smee.io/my-channel --success-on "event=check_run" --settle 30s
+
+  # Also emit the statuses that follow a push for 10 seconds
+  gh-pulse stream --url https://smee.io/my-channel --success-on "event=push" --grace 10s
+
   # Filter to only pull_request events
-  gh-pulse stream --url https://smee.io/my-channel --event pull_request`,
+  gh-pulse stream --url https://smee.io/my-channel --event pull_request
+
+  # Only opened or synchronized pull requests
+  gh-pulse stream --url https://smee.io/my-channel --event pull_request --action opened --action synchronize
+
+  # On a failed check run, also show the 10 events of any type before it
+  gh-pulse stream --url https://smee.io/my-channel --event check_run --failure-on "payload.check_run.conclusion=failure" --context 10
+
+  # Write push.jsonl, pull_request.jsonl, ... instead of stdout
+  gh-pulse stream --url https://smee.io/my-channel --split-by event --output-dir ./out`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if streamURL == "" {
-				return usageErr(cmd, fmt.Errorf("missing required flag: --url"))
-			}
-			if err := validateEvents(events); err != nil {
-				return usageErr(cmd, err)
-			}
-			return nil
+			return usageErr(cmd, streamOpts.validate())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			successAssertions, err := assertion.ParseAssertions(successOn, 0)
-			if err != nil {
-				return err
-			}
-			failureAssertions, err := assertion.ParseAssertions(failureOn, 1)
+			cfg, err := streamOpts.config(quiet)
 			if err != nil {
 				return err
 			}
-			timeout := time.Duration(timeoutSeconds) * time.Second
-
-			return runWithSignals(func(ctx context.Context) error {
-				err := client.Run(ctx, client.Config{
-					URL:               streamURL,
-					Events:            events,
-					SuccessAssertions: successAssertions,
-					FailureAssertions: failureAssertions,
-					Timeout:           timeout,
-					Quiet:             quiet,
-				})
-				if errors.Is(err, context.Canceled) {
-					return nil
-				}
-				var exitErr interface{ ExitCode() int }
-				if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
-					return nil
-				}
-				return err
-			})
+			return runClient(client.Run, cfg)
 		},
 	}
-	streamCmd.Flags().StringVar(&streamURL, "url", "", "smee.io channel URL (required)")
-	streamCmd.Flags().StringArrayVar(&events, "event", nil, "filter by GitHub event type (can repeat)")
-	streamCmd.Flags().StringArrayVar(&successOn, "success-on", nil, "exit 0 when JSON path matches (e.g., 'event=push')")
-	streamCmd.Flags().StringArrayVar(&failureOn, "failure-on", nil, "exit 1 when JSON path matches")
-	streamCmd.Flags().IntVar(&timeoutSeconds, "timeout", 0, "exit 124 after N seconds (0 = no timeout)")
+	streamOpts.addFlags(streamCmd)
+	streamOpts.addStreamFlags(streamCmd)
 
 	captureCmd := &cobra.Command{
 		Use:   "capture --url <smee-channel>",
@@ -179,14 +180,24 @@ Exit codes:
 		Long: `Connect to a smee.io channel and buffer GitHub webhook events.
 
 When an exit condition is met, all buffered events are printed as JSONL to stdout.
+The buffer is also printed when capture is interrupted (Ctrl+C, SIGTERM) or
+stops on a fatal error, so partial captures are not lost.
+With --trigger, only a rolling --pre-trigger window is kept until the trigger
+matches; capture then continues for --post-trigger and exits 0.
+The buffer is limited to 500MB unless --spill-dir lets it overflow to disk;
+--keep-last instead retains only the most recent events.
+Send SIGUSR1 to dump the current buffer (to stdout or --dump-file) without
+exiting.
 Connection status and errors go to stderr.
 
 Exit codes:
-  0   - Success assertion matched (--success-on)
-  1   - Failure assertion matched (--failure-on)
+  0   - Success assertion matched (--success-on, --success-on-all, --until)
+  1   - Failure assertion matched (--failure-on) or deadline missed (--deadline)
   2   - Configuration error (invalid flag values)
+  69  - Channel unreachable (--fail-fast, --max-retries)
   124 - Timeout reached (--timeout)
-  130 - Interrupted (Ctrl+C)`,
+  130 - Interrupted (Ctrl+C)
+  143 - Terminated (SIGTERM)`,
 		Example: `  # Capture until a push event arrives
   gh-pulse capture --url https://smee.io/my-channel --success-on "event=push" --timeout 60
 
@@ -194,57 +205,35 @@ Exit codes:
   gh-pulse capture --url https://smee.io/my-channel --event pull_request --timeout 10
 
   # Fail when a workflow_run event is received
-  gh-pulse capture --url https://smee.io/my-channel --failure-on "event=workflow_run" --timeout 120`,
+  gh-pulse capture --url https://smee.io/my-channel --failure-on "event=workflow_run" --timeout 120
+
+  # Show the 50 events leading up to a failure
+  gh-pulse capture --url https://smee.io/my-channel --failure-on "payload.check_run.conclusion=failure" --keep-last 50
+
+  # Dashcam: on a failed check run, dump the previous 5 minutes plus the next 30 seconds
+  gh-pulse capture --url https://smee.io/my-channel --trigger "payload.check_run.conclusion=failure" --pre-trigger 5m --post-trigger 30s`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if captureURL == "" {
-				return usageErr(cmd, fmt.Errorf("missing required flag: --url"))
-			}
-			if err := validateEvents(captureEvents); err != nil {
+			if err := captureOpts.validate(); err != nil {
 				return usageErr(cmd, err)
 			}
-			if len(captureSuccessOn) == 0 && len(captureFailureOn) == 0 && captureTimeoutSeconds == 0 {
-				return usageErr(cmd, fmt.Errorf("capture mode requires at least one exit condition (--success-on, --failure-on, or --timeout)"))
+			if len(captureOpts.successOn) == 0 && len(captureOpts.successOnAll) == 0 && len(captureOpts.until) == 0 && len(captureOpts.failureOn) == 0 && len(captureOpts.deadlines) == 0 && len(captureOpts.trigger) == 0 && captureOpts.timeoutSeconds == 0 {
+				return usageErr(cmd, fmt.Errorf("capture mode requires at least one exit condition (--success-on, --success-on-all, --until, --failure-on, --deadline, --trigger, or --timeout)"))
 			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			successAssertions, err := assertion.ParseAssertions(captureSuccessOn, 0)
-			if err != nil {
-				return err
-			}
-			failureAssertions, err := assertion.ParseAssertions(captureFailureOn, 1)
+			cfg, err := captureOpts.config(quiet)
 			if err != nil {
 				return err
 			}
-			timeout := time.Duration(captureTimeoutSeconds) * time.Second
-
-			return runWithSignals(func(ctx context.Context) error {
-				err := client.RunCapture(ctx, client.Config{
-					URL:               captureURL,
-					Events:            captureEvents,
-					SuccessAssertions: successAssertions,
-					FailureAssertions: failureAssertions,
-					Timeout:           timeout,
-					Quiet:             quiet,
-				})
-				if errors.Is(err, context.Canceled) {
-					return nil
-				}
-				var exitErr interface{ ExitCode() int }
-				if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
-					return nil
-				}
-				return err
-			})
+			return runClient(client.RunCapture, cfg)
 		},
 	}
-	captureCmd.Flags().StringVar(&captureURL, "url", "", "smee.io channel URL (required)")
-	captureCmd.Flags().StringArrayVar(&captureEvents, "event", nil, "filter by GitHub event type (can repeat)")
-	captureCmd.Flags().StringArrayVar(&captureSuccessOn, "success-on", nil, "exit 0 when JSON path matches (e.g., 'event=push')")
-	captureCmd.Flags().StringArrayVar(&captureFailureOn, "failure-on", nil, "exit 1 when JSON path matches")
-	captureCmd.Flags().IntVar(&captureTimeoutSeconds, "timeout", 0, "exit 124 after N seconds (0 = no timeout)")
+	captureOpts.addFlags(captureCmd)
+	captureOpts.addCaptureFlags(captureCmd)
 
-	rootCmd.AddCommand(streamCmd, captureCmd)
+	rootCmd.AddCommand(streamCmd, captureCmd, newMonitorCmd(&quiet), newWatchCmd(), newStatsCmd(&quiet), newDiffCmd(&quiet), newValidateCmd(&quiet), newGenerateCmd(), newReplayCmd(&quiet), newDoctorCmd(), newHookCmd(&quiet), newSetupCmd(&quiet), newRedeliverCmd(&quiet), newTailCmd(&quiet), newFilterCmd(&quiet), newMergeCmd(&quiet), newSplitCmd(&quiet), newRedactCmd(&quiet), newWaitCmd(&quiet), newBenchCmd(&quiet), newMockGitHubCmd(&quiet))
+	registerCompletions(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		var usageErr usageError
@@ -256,7 +245,8 @@ Exit codes:
 		}
 		var exitErr interface{ ExitCode() int }
 		if errors.As(err, &exitErr) {
-			if exitErr.ExitCode() == 2 {
+			switch exitErr.ExitCode() {
+			case 2, 69:
 				fmt.Fprintln(os.Stderr, err)
 			}
 			os.Exit(exitErr.ExitCode())
@@ -266,6 +256,538 @@ Exit codes:
 	}
 }
 
+// runOptions holds the flags shared by the stream and capture commands.
+type runOptions struct {
+	url            string
+	events         []string
+	actions        []string
+	successOn      []string
+	successOnAll   []string
+	until          []string
+	deadlines      []string
+	failureOn      []string
+	timeoutSeconds int
+	settle         time.Duration
+	grace          time.Duration
+	context        int
+	maxRate        string
+	heartbeat      time.Duration
+	statsInterval  time.Duration
+	ready          bool
+	readyFile      string
+	statsd         string
+	statsdTags     []string
+	ratePolicy     string
+	result         bool
+	report         string
+	outputVars     []string
+	jq             string
+	script         string
+	ignoreFile     string
+	redact         []string
+	maxEventSize   string
+	truncate       bool
+	redactFile     string
+	senders        []string
+	excludeBots    bool
+	onlyHuman      bool
+	verifySecret   string
+	secretFile     string
+	secretFrom     string
+	secretRefresh  time.Duration
+	keepUnverified bool
+	enrich         bool
+	generic        bool
+	eventHeader    string
+	chaos          string
+	appID          string
+	appKey         string
+	appPoll        time.Duration
+	redeliver      bool
+	failFast       bool
+	maxRetries     int
+	splitBy        string
+	groupBy        []string
+	routes         string
+	journalDir     string
+	ordering       string
+	orderKey       string
+	concurrency    int
+	deadLetter     string
+	signSecret     string
+	emitResponses  bool
+	successOnResp  []string
+	failureOnResp  []string
+	outputDir      string
+	output         string
+	outputBatch    int
+	outputFlush    time.Duration
+	sinkPlugin     string
+	trigger        []string
+	preTrigger     time.Duration
+	postTrigger    time.Duration
+	spillDir       string
+	keepLast       int
+	keepLastBytes  int64
+	dumpFile       string
+	encrypt        []string
+	file           string
+	follow         bool
+}
+
+// addFlags registers the flags shared by the stream and capture commands.
+func (o *runOptions) addFlags(cmd *cobra.Command) {
+	o.addSourceFlags(cmd)
+	o.addPipelineFlags(cmd)
+}
+
+// addSourceFlags registers the flags that choose and configure the live
+// event sources.
+func (o *runOptions) addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.url, "url", "", "smee.io channel URL (required unless --app-id is set)")
+	cmd.Flags().StringVar(&o.verifySecret, "verify-secret", "", "drop events whose signature (or GitLab token) does not match this webhook secret")
+	cmd.Flags().StringVar(&o.secretFile, "webhook-secret-file", "", "like --verify-secret, but read the secret from this file")
+	cmd.Flags().StringVar(&o.secretFrom, "webhook-secret-from", "", "like --verify-secret, but fetch the secret from vault:<path>#<field> or aws-sm:<secret-id>[#<json-key>]")
+	cmd.Flags().DurationVar(&o.secretRefresh, "secret-refresh", 5*time.Minute, "how often to reread --webhook-secret-file or --webhook-secret-from, so rotations are picked up (0 = never)")
+	cmd.Flags().BoolVar(&o.keepUnverified, "keep-unverified", false, "with --verify-secret, keep failing events marked \"verified\": false")
+	cmd.Flags().BoolVar(&o.generic, "generic", false, "accept webhooks without GitHub headers, e.g. from Stripe or internal services")
+	cmd.Flags().StringVar(&o.eventHeader, "event-header", "", "with --generic, request header that names the event (e.g. X-Event-Type)")
+	cmd.Flags().StringVar(&o.appID, "app-id", "", "also read events from this GitHub App's webhook delivery log (needs --app-key)")
+	cmd.Flags().StringVar(&o.appKey, "app-key", "", "PEM private key file for --app-id")
+	cmd.Flags().DurationVar(&o.appPoll, "app-poll-interval", 10*time.Second, "how often to poll the App's deliveries")
+	cmd.Flags().BoolVar(&o.redeliver, "redeliver-failed", false, "with --app-id, ask GitHub to redeliver deliveries the App's endpoint rejected")
+	cmd.Flags().BoolVar(&o.failFast, "fail-fast", false, "exit 69 when the channel can't be reached instead of retrying forever")
+	cmd.Flags().IntVar(&o.maxRetries, "max-retries", 0, "exit 69 after N consecutive failed reconnects (0 = forever, or none with --fail-fast)")
+	cmd.Flags().StringVar(&o.chaos, "chaos", "", "inject faults into the channel stream for testing, e.g. drop=0.05,delay=200ms,dup=0.1,seed=1")
+	_ = cmd.Flags().MarkHidden("chaos")
+}
+
+// addPipelineFlags registers the filter, output, and exit-condition flags,
+// which also apply to events read from a file.
+func (o *runOptions) addPipelineFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&o.events, "event", nil, "filter by GitHub event type (can repeat)")
+	cmd.Flags().StringArrayVar(&o.actions, "action", nil, "filter by payload action, e.g. opened (can repeat)")
+	cmd.Flags().StringArrayVar(&o.successOn, "success-on", nil, "exit 0 when JSON path matches (e.g., 'event=push')")
+	cmd.Flags().StringArrayVar(&o.successOnAll, "success-on-all", nil, "exit 0 once every one of these has matched some event, in any order (can repeat)")
+	cmd.Flags().StringArrayVar(&o.until, "until", nil, "exit 0 once this expression holds, e.g. 'event=push && count(event=check_run) >= 3' (can repeat)")
+	cmd.Flags().StringArrayVar(&o.failureOn, "failure-on", nil, "exit 1 when JSON path matches")
+	cmd.Flags().StringArrayVar(&o.deadlines, "deadline", nil, "exit 1 unless END follows START in time: 'START => END within 90s [by PATH]' (can repeat)")
+	cmd.Flags().IntVar(&o.timeoutSeconds, "timeout", 0, "exit 124 after N seconds (0 = no timeout)")
+	cmd.Flags().StringVar(&o.ignoreFile, "ignore-file", "", "YAML file of assertion patterns for events to drop")
+	cmd.Flags().StringArrayVar(&o.senders, "sender", nil, "filter by payload.sender.login (can repeat)")
+	cmd.Flags().BoolVar(&o.excludeBots, "exclude-bots", false, "drop events sent by bots (sender.type Bot or [bot] login; alias --ignore-bots)")
+	cmd.Flags().SetNormalizeFunc(normalizeFlagName)
+	cmd.Flags().BoolVar(&o.onlyHuman, "only-human", false, "keep only events sent by human users (sender.type User)")
+	cmd.Flags().BoolVar(&o.enrich, "enrich", false, "attach GitHub API data to pull_request, check_run, and workflow_run events (needs GITHUB_TOKEN)")
+	cmd.Flags().StringVar(&o.maxEventSize, "max-event-size", "", "drop events whose payload is larger than this, e.g. 1MB (KB, MB, GB, or bytes)")
+	cmd.Flags().BoolVar(&o.truncate, "truncate-oversize", false, "with --max-event-size, keep oversized events with the payload fields that fit, marked \"truncated\": true")
+	cmd.Flags().StringSliceVar(&o.redact, "redact", nil, "scrub these from events before output: emails, tokens, names (comma-separated)")
+	cmd.Flags().StringVar(&o.redactFile, "redact-file", "", "YAML file of redaction rules (classes, field keys, paths, patterns)")
+	cmd.Flags().StringVar(&o.jq, "jq", "", "jq query applied to each event before output (e.g., '.payload.ref')")
+	cmd.Flags().StringVar(&o.script, "script", "", "Lua file whose transform(event) function changes, drops, or emits events before output")
+	cmd.Flags().StringVar(&o.splitBy, "split-by", "", "write one file per value of this path instead of stdout, e.g. event or payload.repository.full_name (needs --output-dir)")
+	cmd.Flags().StringVar(&o.outputDir, "output-dir", "", "directory for --split-by files, appended to as <value>.jsonl")
+	cmd.Flags().StringVar(&o.output, "output", "", "write events to a database instead of stdout: sqlite:<file> or clickhouse:<http-url>/<table>")
+	cmd.Flags().StringVar(&o.sinkPlugin, "sink-plugin", "", "send events to this command's stdin as JSONL instead of stdout; its exit code decides the run")
+	cmd.Flags().IntVar(&o.outputBatch, "output-batch-size", 1000, "with a clickhouse --output, insert this many events at a time")
+	cmd.Flags().DurationVar(&o.outputFlush, "output-flush-interval", 5*time.Second, "with a clickhouse --output, insert pending events at least this often")
+	cmd.Flags().DurationVar(&o.settle, "settle", 0, "after success matches, wait until no events arrive for this long before exiting (e.g., 30s)")
+	cmd.Flags().DurationVar(&o.grace, "grace", 0, "after success matches, keep running this long before exiting (e.g., 10s)")
+	cmd.Flags().StringVar(&o.report, "report", "", "write the exit rules as test cases to a report when the run ends; only junit=<file> is supported")
+	cmd.Flags().StringArrayVar(&o.outputVars, "output-var", nil, "in GitHub Actions, also set step output KEY from the deciding event: KEY=PATH (can repeat)")
+	cmd.Flags().DurationVar(&o.statsInterval, "stats-interval", 0, "log event and byte rates, drops, buffer usage, and reconnects to stderr this often (e.g., 30s)")
+	cmd.Flags().BoolVar(&o.ready, "ready", false, "write a {\"type\":\"ready\"} line once the channel is subscribed, before any events")
+	cmd.Flags().StringVar(&o.readyFile, "ready-file", "", "write the ready line to this file once the channel is subscribed")
+	cmd.Flags().StringVar(&o.statsd, "statsd", "", "StatsD host:port to send event, output, and reconnect counters to")
+	cmd.Flags().StringArrayVar(&o.statsdTags, "statsd-tag", nil, "DogStatsD tag added to every metric, e.g. job:deploy (can repeat)")
+	cmd.Flags().BoolVar(&o.result, "result", false, "end output with a {\"type\":\"result\"} line naming the exit code, rule, and deciding event")
+}
+
+// addStreamFlags registers the flags that only apply to stream mode.
+func (o *runOptions) addStreamFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&o.heartbeat, "heartbeat", 0, "write a {\"type\":\"heartbeat\"} line this often while connected (e.g., 15s)")
+	cmd.Flags().StringVar(&o.maxRate, "max-rate", "", "write at most this many events, e.g. 50/s or 600/m (assertions still see every event)")
+	cmd.Flags().StringVar(&o.ratePolicy, "rate-policy", "wait", "what to do with events over --max-rate: wait for their turn, or drop them from the output")
+	cmd.Flags().StringArrayVar(&o.groupBy, "group-by", nil, "print one {\"type\":\"group\"} summary per value of this path instead of each event, e.g. payload.pull_request.number (can repeat; the first path an event has names its group)")
+	cmd.Flags().StringVar(&o.routes, "routes", "", "YAML file of routes that also POST matching events to local services, with retries")
+	cmd.Flags().StringVar(&o.ordering, "ordering", "serial", "how routes without their own ordering deliver: serial (one at a time, in order), per-key (in order per --key value), or parallel")
+	cmd.Flags().StringVar(&o.orderKey, "key", "", "with --ordering per-key, the path events are ordered by, e.g. payload.repository.full_name")
+	cmd.Flags().IntVar(&o.concurrency, "concurrency", route.DefaultConcurrency, "with per-key or parallel ordering, how many deliveries each route has in flight")
+	cmd.Flags().StringVar(&o.journalDir, "journal-dir", "", "keep each route's undelivered events in this directory, so they wait out a down target and survive a restart")
+	cmd.Flags().StringVar(&o.deadLetter, "dead-letter", "", "append events a route gave up on to this JSONL file")
+	cmd.Flags().BoolVar(&o.emitResponses, "emit-responses", false, "write a {\"type\":\"response\"} line with the status and latency of each forwarded delivery")
+	cmd.Flags().StringArrayVar(&o.successOnResp, "success-on-response", nil, "exit 0 when a forwarded delivery's response matches, e.g. 'status=200' (fields as in --emit-responses; can repeat)")
+	cmd.Flags().StringArrayVar(&o.failureOnResp, "failure-on-response", nil, "exit 1 when a forwarded delivery's response matches, e.g. 'status=~5..' or 'error exists' (can repeat)")
+	cmd.Flags().StringVar(&o.signSecret, "sign-secret", "", "sign deliveries with X-Hub-Signature-256 for this secret, for routes without their own secret")
+	cmd.Flags().IntVar(&o.context, "context", 0, "when an assertion ends the run, also print the filtered-out events among the N before it, marked \"context\": true")
+}
+
+// addCaptureFlags registers the flags that only apply to capture mode.
+func (o *runOptions) addCaptureFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&o.trigger, "trigger", nil, "keep a rolling window and dump it when JSON path matches")
+	cmd.Flags().DurationVar(&o.preTrigger, "pre-trigger", time.Minute, "events to keep from before the trigger (with --trigger)")
+	cmd.Flags().DurationVar(&o.postTrigger, "post-trigger", 0, "keep capturing this long after the trigger before exiting 0")
+	cmd.Flags().IntVar(&o.keepLast, "keep-last", 0, "keep only the most recent N events (0 = all)")
+	cmd.Flags().Int64Var(&o.keepLastBytes, "keep-last-bytes", 0, "keep only the most recent events up to N bytes (0 = no limit)")
+	cmd.Flags().StringVar(&o.dumpFile, "dump-file", "", "write on-demand SIGUSR1 dumps to this file instead of stdout")
+	cmd.Flags().StringArrayVar(&o.encrypt, "encrypt", nil, "encrypt stdout, --output-dir files, and --dump-file to this age recipient: age:<age1...> (can repeat)")
+	cmd.Flags().StringVar(&o.spillDir, "spill-dir", "", "move buffered events to temp files in this directory instead of failing at 500MB")
+}
+
+func (o *runOptions) validate() error {
+	if o.url == "" && o.appID == "" && o.file == "" {
+		return fmt.Errorf("missing required flag: --url")
+	}
+	if (o.appID == "") != (o.appKey == "") {
+		return fmt.Errorf("--app-id and --app-key must be used together")
+	}
+	if o.eventHeader != "" && !o.generic {
+		return fmt.Errorf("--event-header requires --generic")
+	}
+	if o.chaos != "" {
+		if _, err := sse.ParseChaos(o.chaos); err != nil {
+			return fmt.Errorf("invalid --chaos: %w", err)
+		}
+	}
+	if o.redeliver && o.appID == "" {
+		return fmt.Errorf("--redeliver-failed requires --app-id")
+	}
+	if o.appID != "" && o.appPoll <= 0 {
+		return fmt.Errorf("--app-poll-interval must be positive")
+	}
+	if err := validateEvents(o.events); err != nil {
+		return err
+	}
+	for _, action := range o.actions {
+		if strings.TrimSpace(action) == "" {
+			return fmt.Errorf("--action must be non-empty")
+		}
+	}
+	for _, sender := range o.senders {
+		if strings.TrimSpace(sender) == "" {
+			return fmt.Errorf("--sender must be non-empty")
+		}
+	}
+	secrets := 0
+	for _, value := range []string{o.verifySecret, o.secretFile, o.secretFrom} {
+		if value != "" {
+			secrets++
+		}
+	}
+	if secrets > 1 {
+		return fmt.Errorf("--verify-secret, --webhook-secret-file, and --webhook-secret-from cannot be combined")
+	}
+	if o.keepUnverified && secrets == 0 {
+		return fmt.Errorf("--keep-unverified requires --verify-secret")
+	}
+	if o.secretRefresh < 0 {
+		return fmt.Errorf("--secret-refresh must be non-negative")
+	}
+	if o.maxRetries < 0 {
+		return fmt.Errorf("--max-retries must be non-negative")
+	}
+	if o.splitBy != "" {
+		if err := assertion.ValidatePath(o.splitBy); err != nil {
+			return fmt.Errorf("invalid --split-by: %w", err)
+		}
+	}
+	if (o.splitBy == "") != (o.outputDir == "") {
+		return fmt.Errorf("--split-by and --output-dir must be used together")
+	}
+	if o.output != "" {
+		if _, _, err := client.ParseOutput(o.output); err != nil {
+			return fmt.Errorf("invalid --output: %w", err)
+		}
+		if o.outputDir != "" || o.jq != "" {
+			return fmt.Errorf("--output cannot be combined with --output-dir or --jq")
+		}
+		if o.outputBatch < 1 {
+			return fmt.Errorf("--output-batch-size must be at least 1")
+		}
+		if o.outputFlush <= 0 {
+			return fmt.Errorf("--output-flush-interval must be positive")
+		}
+	}
+	if o.sinkPlugin != "" {
+		args := strings.Fields(o.sinkPlugin)
+		if len(args) == 0 {
+			return fmt.Errorf("--sink-plugin must be non-empty")
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return fmt.Errorf("invalid --sink-plugin: %w", err)
+		}
+		if o.output != "" || o.outputDir != "" {
+			return fmt.Errorf("--sink-plugin cannot be combined with --output or --output-dir")
+		}
+	}
+	if len(o.encrypt) > 0 {
+		if _, err := client.ParseRecipients(o.encrypt); err != nil {
+			return fmt.Errorf("invalid --encrypt: %w", err)
+		}
+		if o.output != "" || o.sinkPlugin != "" {
+			return fmt.Errorf("--encrypt cannot be combined with --output or --sink-plugin")
+		}
+	}
+	for _, by := range o.groupBy {
+		if err := assertion.ValidatePath(by); err != nil {
+			return fmt.Errorf("invalid --group-by: %w", err)
+		}
+	}
+	if len(o.groupBy) > 0 {
+		if o.outputDir != "" || o.output != "" || o.sinkPlugin != "" || o.jq != "" {
+			return fmt.Errorf("--group-by cannot be combined with --output-dir, --output, --sink-plugin, or --jq")
+		}
+	}
+	if o.settle < 0 {
+		return fmt.Errorf("--settle must be non-negative")
+	}
+	if o.grace < 0 {
+		return fmt.Errorf("--grace must be non-negative")
+	}
+	if o.heartbeat < 0 {
+		return fmt.Errorf("--heartbeat must be non-negative")
+	}
+	if o.statsInterval < 0 {
+		return fmt.Errorf("--stats-interval must be non-negative")
+	}
+	if len(o.statsdTags) > 0 && o.statsd == "" {
+		return fmt.Errorf("--statsd-tag requires --statsd")
+	}
+	if o.context < 0 {
+		return fmt.Errorf("--context must be non-negative")
+	}
+	if o.routes == "" && (o.journalDir != "" || o.deadLetter != "" || o.signSecret != "" || o.emitResponses) {
+		return fmt.Errorf("--journal-dir, --dead-letter, --sign-secret, and --emit-responses require --routes")
+	}
+	if o.routes == "" && (len(o.successOnResp) > 0 || len(o.failureOnResp) > 0) {
+		return fmt.Errorf("--success-on-response and --failure-on-response require --routes")
+	}
+	switch o.ordering {
+	case "", route.Serial, route.Parallel:
+	case route.PerKey:
+		if o.orderKey == "" {
+			return fmt.Errorf("--ordering per-key requires --key")
+		}
+	default:
+		return fmt.Errorf("--ordering must be serial, per-key, or parallel")
+	}
+	if o.routes != "" && o.concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	if o.maxRate != "" {
+		if _, err := parseRate(o.maxRate); err != nil {
+			return err
+		}
+	}
+	if o.ratePolicy != "" && o.ratePolicy != "wait" && o.ratePolicy != "drop" {
+		return fmt.Errorf("--rate-policy must be \"wait\" or \"drop\"")
+	}
+	if o.report != "" {
+		if format, path, ok := strings.Cut(o.report, "="); !ok || format != "junit" || path == "" {
+			return fmt.Errorf("--report must be junit=<file>")
+		}
+	}
+	if o.maxEventSize != "" {
+		if size, err := parseSize(o.maxEventSize); err != nil || size <= 0 {
+			return fmt.Errorf("--max-event-size must be a positive size like 512KB or 1MB")
+		}
+	} else if o.truncate {
+		return fmt.Errorf("--truncate-oversize requires --max-event-size")
+	}
+	if o.preTrigger < 0 || o.postTrigger < 0 {
+		return fmt.Errorf("--pre-trigger and --post-trigger must be non-negative")
+	}
+	if o.keepLast < 0 || o.keepLastBytes < 0 {
+		return fmt.Errorf("--keep-last and --keep-last-bytes must be non-negative")
+	}
+	if (o.keepLast > 0 || o.keepLastBytes > 0) && o.spillDir != "" {
+		return fmt.Errorf("--keep-last and --keep-last-bytes cannot be combined with --spill-dir")
+	}
+	return nil
+}
+
+func (o *runOptions) config(quiet bool) (client.Config, error) {
+	successAssertions, err := assertion.ParseAssertions(o.successOn, 0)
+	if err != nil {
+		return client.Config{}, err
+	}
+	successAll, err := assertion.ParseAssertions(o.successOnAll, 0)
+	if err != nil {
+		return client.Config{}, err
+	}
+	until, err := assertion.ParseExprs(o.until)
+	if err != nil {
+		return client.Config{}, err
+	}
+	deadlines, err := assertion.ParseDeadlines(o.deadlines)
+	if err != nil {
+		return client.Config{}, err
+	}
+	failureAssertions, err := assertion.ParseAssertions(o.failureOn, 1)
+	if err != nil {
+		return client.Config{}, err
+	}
+	trigger, err := assertion.ParseAssertions(o.trigger, 0)
+	if err != nil {
+		return client.Config{}, err
+	}
+	successOnResponse, err := assertion.ParseRecordAssertions(o.successOnResp, 0, client.ResponseFields)
+	if err != nil {
+		return client.Config{}, err
+	}
+	failureOnResponse, err := assertion.ParseRecordAssertions(o.failureOnResp, 1, client.ResponseFields)
+	if err != nil {
+		return client.Config{}, err
+	}
+	outputVars, err := parseOutputVars(o.outputVars)
+	if err != nil {
+		return client.Config{}, err
+	}
+	var chaos *sse.Chaos
+	if o.chaos != "" {
+		if chaos, err = sse.ParseChaos(o.chaos); err != nil {
+			return client.Config{}, err
+		}
+	}
+	var token string
+	if o.enrich {
+		token = github.TokenFromEnv()
+		if token == "" {
+			return client.Config{}, fmt.Errorf("--enrich requires GITHUB_TOKEN or GH_TOKEN to be set")
+		}
+	}
+	var ignore []assertion.Assertion
+	if o.ignoreFile != "" {
+		ignore, err = assertion.LoadIgnoreFile(o.ignoreFile)
+		if err != nil {
+			return client.Config{}, err
+		}
+	}
+	redactor, err := loadRedactor(o.redact, o.redactFile)
+	if err != nil {
+		return client.Config{}, err
+	}
+	verifySecret, err := loadSecret(o.secretFile, o.secretFrom, o.secretRefresh, quiet)
+	if err != nil {
+		return client.Config{}, err
+	}
+	var routes []route.Route
+	if o.routes != "" {
+		if routes, err = route.Load(o.routes, route.Ordering{Mode: o.ordering, Key: o.orderKey, Concurrency: o.concurrency}); err != nil {
+			return client.Config{}, err
+		}
+		for i := range routes {
+			if routes[i].Secret == "" {
+				routes[i].Secret = o.signSecret
+			}
+		}
+	}
+	recipients, err := client.ParseRecipients(o.encrypt)
+	if err != nil {
+		return client.Config{}, err
+	}
+	var maxRate float64
+	if o.maxRate != "" {
+		if maxRate, err = parseRate(o.maxRate); err != nil {
+			return client.Config{}, err
+		}
+	}
+	var maxEventSize int64
+	if o.maxEventSize != "" {
+		if maxEventSize, err = parseSize(o.maxEventSize); err != nil {
+			return client.Config{}, err
+		}
+	}
+	return client.Config{
+		URL:                 o.url,
+		File:                o.file,
+		Follow:              o.follow,
+		Events:              o.events,
+		Actions:             o.actions,
+		SuccessAssertions:   successAssertions,
+		SuccessAll:          successAll,
+		Until:               until,
+		UntilInputs:         o.until,
+		Deadlines:           deadlines,
+		FailureAssertions:   failureAssertions,
+		Ignore:              ignore,
+		Senders:             o.senders,
+		ExcludeBots:         o.excludeBots,
+		OnlyHuman:           o.onlyHuman,
+		VerifySecret:        o.verifySecret,
+		VerifySecretFrom:    verifySecret,
+		KeepUnverified:      o.keepUnverified,
+		FailFast:            o.failFast,
+		MaxRetries:          o.maxRetries,
+		Generic:             o.generic,
+		EventHeader:         o.eventHeader,
+		AppID:               o.appID,
+		AppKeyFile:          o.appKey,
+		AppPollInterval:     o.appPoll,
+		RedeliverFailed:     o.redeliver,
+		Enrich:              o.enrich,
+		GitHubToken:         token,
+		MaxEventSize:        maxEventSize,
+		TruncateOversize:    o.truncate,
+		Redact:              redactor,
+		Timeout:             time.Duration(o.timeoutSeconds) * time.Second,
+		Settle:              o.settle,
+		Grace:               o.grace,
+		Context:             o.context,
+		StatsInterval:       o.statsInterval,
+		Ready:               o.ready,
+		ReadyFile:           o.readyFile,
+		StatsD:              o.statsd,
+		StatsDTags:          o.statsdTags,
+		Heartbeat:           o.heartbeat,
+		MaxRate:             maxRate,
+		DropOverRate:        o.ratePolicy == "drop",
+		Result:              o.result,
+		JUnitReport:         strings.TrimPrefix(o.report, "junit="),
+		GitHubOutput:        os.Getenv("GITHUB_OUTPUT"),
+		OutputVars:          outputVars,
+		JQ:                  o.jq,
+		Script:              o.script,
+		Trigger:             trigger,
+		PreTrigger:          o.preTrigger,
+		PostTrigger:         o.postTrigger,
+		SpillDir:            o.spillDir,
+		KeepLast:            o.keepLast,
+		KeepLastBytes:       o.keepLastBytes,
+		DumpFile:            o.dumpFile,
+		Encrypt:             recipients,
+		OutputDir:           o.outputDir,
+		SplitBy:             o.splitBy,
+		Output:              o.output,
+		OutputBatchSize:     o.outputBatch,
+		OutputFlushInterval: o.outputFlush,
+		SinkPlugin:          o.sinkPlugin,
+		Routes:              routes,
+		JournalDir:          o.journalDir,
+		DeadLetter:          o.deadLetter,
+		EmitResponses:       o.emitResponses,
+		SuccessOnResponse:   successOnResponse,
+		FailureOnResponse:   failureOnResponse,
+		GroupBy:             o.groupBy,
+		Chaos:               chaos,
+		Quiet:               quiet,
+	}, nil
+}
+
+func runClient(run func(context.Context, client.Config) error, cfg client.Config) error {
+	return runWithSignals(func(ctx context.Context) error {
+		err := run(ctx, cfg)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+			return nil
+		}
+		return err
+	})
+}
+
 func validateEvents(events []string) error {
 	for _, event := range events {
 		if strings.TrimSpace(event) == "" {
@@ -274,3 +796,85 @@ func validateEvents(events []string) error {
 	}
 	return nil
 }
+
+// parseSize parses a byte count with an optional B, KB, MB, or GB suffix
+// (powers of 1024, case-insensitive).
+func parseSize(input string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+	return n * multiplier, nil
+}
+
+// parseRate parses a --max-rate of N/s, N/m, or N/h into events per second.
+func parseRate(input string) (float64, error) {
+	count, unit, ok := strings.Cut(input, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --max-rate %q (expected N/s, N/m, or N/h)", input)
+	}
+	switch strings.TrimSpace(unit) {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	}
+	return 0, fmt.Errorf("invalid --max-rate %q (expected N/s, N/m, or N/h)", input)
+}
+
+// parseOutputVars parses --output-var KEY=PATH values.
+func parseOutputVars(inputs []string) ([]client.OutputVar, error) {
+	vars := make([]client.OutputVar, 0, len(inputs))
+	for _, input := range inputs {
+		name, path, ok := strings.Cut(input, "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid --output-var %q (expected KEY=PATH)", input)
+		}
+		if strings.IndexFunc(name, func(r rune) bool {
+			return !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 {
+			return nil, fmt.Errorf("invalid --output-var %q: KEY may only contain letters, digits, - and _", input)
+		}
+		if _, err := assertion.SplitPath(path); err != nil {
+			return nil, fmt.Errorf("invalid --output-var %q: %w", input, err)
+		}
+		vars = append(vars, client.OutputVar{Name: name, Path: path})
+	}
+	return vars, nil
+}
+
+// loadSecret reads the webhook secret from --webhook-secret-file or
+// --webhook-secret-from, when either is set.
+func loadSecret(path, ref string, refresh time.Duration, quiet bool) (*secret.Secret, error) {
+	var src secret.Source
+	switch {
+	case path != "":
+		src = secret.File(path)
+	case ref != "":
+		var err error
+		if src, err = secret.Parse(ref); err != nil {
+			return nil, fmt.Errorf("invalid --webhook-secret-from: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+	var logger *log.Logger
+	if !quiet {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return secret.New(src, refresh, logger)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/spf13/cobra"
+)
+
+func newMergeCmd(quiet *bool) *cobra.Command {
+	var by string
+
+	cmd := &cobra.Command{
+		Use:   "merge <a.jsonl> <b.jsonl> [more.jsonl...]",
+		Short: "Combine captures into one chronological capture",
+		Long: `Interleave the events of several JSONL captures in received_at order and
+print them as one capture, e.g. to combine a test session observed from
+several channels or machines.
+
+Events with the same delivery_id are printed once, at their earliest
+occurrence. Events with no received_at keep their place after the event
+before them in the same file, and ties keep the order the files were given.`,
+		Example: `  gh-pulse merge runner-1.jsonl runner-2.jsonl > session.jsonl
+
+  # Then check the combined session
+  gh-pulse merge a.jsonl b.jsonl | gh-pulse filter --success-on "event=deployment_status"`,
+		Args: cobra.MinimumNArgs(2),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if by != "received_at" {
+				return usageErr(cmd, fmt.Errorf("--by must be \"received_at\""))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			var files [][]message.EventMessage
+			for _, path := range args {
+				events, err := readEvents(path, logger)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				files = append(files, events)
+			}
+
+			merged, duplicates := mergeEvents(files)
+			for _, event := range merged {
+				if err := writeJSONLine(os.Stdout, event); err != nil {
+					return err
+				}
+			}
+			if logger != nil {
+				logger.Printf("merged %d events from %d files (%d duplicates dropped)", len(merged), len(files), duplicates)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&by, "by", "received_at", "field to order events by; only \"received_at\" is supported")
+	return cmd
+}
+
+// mergeEvents orders the events of every file by received_at and drops
+// repeated delivery IDs, returning the merged events and how many were
+// dropped.
+func mergeEvents(files [][]message.EventMessage) ([]message.EventMessage, int) {
+	type entry struct {
+		event message.EventMessage
+		at    time.Time
+	}
+	var entries []entry
+	for _, events := range files {
+		var last time.Time
+		for _, event := range events {
+			if !event.ReceivedAt.IsZero() {
+				last = event.ReceivedAt
+			}
+			entries = append(entries, entry{event: event, at: last})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].at.Before(entries[j].at)
+	})
+
+	merged := make([]message.EventMessage, 0, len(entries))
+	seen := make(map[string]bool)
+	duplicates := 0
+	for _, e := range entries {
+		if id := e.event.DeliveryID; id != "" {
+			if seen[id] {
+				duplicates++
+				continue
+			}
+			seen[id] = true
+		}
+		merged = append(merged, e.event)
+	}
+	return merged, duplicates
+}
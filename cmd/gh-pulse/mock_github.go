@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/mock"
+	"github.com/kehao95/gh-pulse/internal/scenario"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+func newMockGitHubCmd(quiet *bool) *cobra.Command {
+	var host, file, target, secret, start string
+	var port int
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:   "mock-github --scenario <file|name> [--port 9000] [--target <url>]",
+		Short: "Play scripted webhooks offline, as a local channel and to a target",
+		Long: fmt.Sprintf(`Play a scenario of realistic webhook events, generated from the same
+templates as generate, so integration tests can run without GitHub.
+
+The command serves a smee.io-compatible channel on --port: any path can be
+subscribed to with stream --url http://localhost:PORT/<channel>. With
+--target, each event is also POSTed there with GitHub's headers. Deliveries
+are signed with --secret. Every played event is printed as JSONL.
+
+The scenario plays once when the first subscriber connects (--start
+subscriber, the default without --target), right away (--start now, the
+default with --target), or only on request (--start manual). POST /play
+plays it again. With --once the command exits after the first play: 0 if
+every delivery to --target was accepted, 1 otherwise.
+
+--scenario is a YAML file or a built-in scenario: %s.`, strings.Join(scenario.Builtin(), ", ")),
+		Example: `  # Offline channel for gh-pulse itself
+  gh-pulse mock-github --port 9000 --scenario pr-lifecycle &
+  gh-pulse stream --url http://localhost:9000/test --success-on 'payload.pull_request.merged=true' --timeout 60
+
+  # Drive a local handler with signed deliveries
+  gh-pulse mock-github --scenario pr-lifecycle.yaml --target http://localhost:3000/webhook --secret "$WEBHOOK_SECRET" --once`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --scenario"))
+			}
+			if port < 0 || port > 65535 {
+				return usageErr(cmd, fmt.Errorf("--port must be between 0 and 65535"))
+			}
+			switch start {
+			case "", "subscriber", "now", "manual":
+			default:
+				return usageErr(cmd, fmt.Errorf("--start must be subscriber, now, or manual"))
+			}
+			if once && start == "manual" {
+				return usageErr(cmd, fmt.Errorf("--once cannot be combined with --start manual"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if target != "" {
+				if err := client.ValidateURL(target); err != nil {
+					return err
+				}
+			}
+			s, err := scenario.Load(file)
+			if err != nil {
+				return err
+			}
+			if start == "" {
+				start = "subscriber"
+				if target != "" {
+					start = "now"
+				}
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			player := &mockPlayer{
+				scenario: s,
+				server:   mock.NewServer(secret),
+				target:   target,
+				secret:   secret,
+				stdout:   bufio.NewWriter(os.Stdout),
+				http:     &http.Client{Timeout: 30 * time.Second},
+				logger:   logger,
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				return player.run(ctx, net.JoinHostPort(host, strconv.Itoa(port)), start, once)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&file, "scenario", "", "scenario YAML file or built-in scenario name (required)")
+	cmd.Flags().IntVar(&port, "port", 9000, "port to serve the channel on (0 picks a free port)")
+	cmd.Flags().StringVar(&host, "host", "127.0.0.1", "address to listen on")
+	cmd.Flags().StringVar(&target, "target", "", "also POST each event to this URL")
+	cmd.Flags().StringVar(&secret, "secret", "", "webhook secret used to sign each delivery")
+	cmd.Flags().StringVar(&start, "start", "", "when to play: subscriber, now, or manual (POST /play)")
+	cmd.Flags().BoolVar(&once, "once", false, "exit after the scenario has played once")
+	return cmd
+}
+
+// mockPlayer plays a scenario to the channel's subscribers and the target.
+type mockPlayer struct {
+	scenario *scenario.Scenario
+	server   *mock.Server
+	target   string
+	secret   string
+	stdout   *bufio.Writer
+	http     *http.Client
+	logger   *log.Logger
+
+	// playing serializes plays, so POST /play waits for the one running.
+	playing sync.Mutex
+	failed  int
+}
+
+func (p *mockPlayer) run(ctx context.Context, addr, start string, once bool) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	p.server.OnPlay = func() { go p.play(ctx) }
+	srv := &http.Server{Handler: p.server}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+	defer srv.Close()
+	p.logf("serving scenario %s on http://%s/<channel>", p.scenario.Name, listener.Addr())
+
+	switch start {
+	case "subscriber":
+		select {
+		case <-p.server.Subscribed():
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-served:
+			return err
+		}
+		fallthrough
+	case "now":
+		if once {
+			err := p.play(ctx)
+			p.server.Close()
+			if err != nil {
+				return err
+			}
+			if p.failed > 0 {
+				return exitError{code: 1}
+			}
+			return nil
+		}
+		go p.play(ctx)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-served:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// play runs the scenario once.
+func (p *mockPlayer) play(ctx context.Context) error {
+	p.playing.Lock()
+	defer p.playing.Unlock()
+	p.logf("playing %s to %d subscribers", p.scenario.Name, p.server.Subscribers())
+	err := p.scenario.Play(ctx, func(msg message.EventMessage) error {
+		msg.ReceivedAt = time.Now().UTC()
+		if err := p.server.Publish(msg); err != nil {
+			return err
+		}
+		if err := writeJSONLine(p.stdout, msg); err != nil {
+			return err
+		}
+		if err := p.stdout.Flush(); err != nil {
+			return err
+		}
+		if p.target == "" {
+			p.logf("played %s (%s)", msg.Event, msg.DeliveryID)
+			return nil
+		}
+		status, err := webhook.Post(ctx, p.http, p.target, msg, p.secret)
+		if err != nil {
+			p.failed++
+			p.logf("%v", err)
+			return nil
+		}
+		if status < 200 || status > 299 {
+			p.failed++
+		}
+		p.logf("played %s (%s): %d %s", msg.Event, msg.DeliveryID, status, http.StatusText(status))
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		p.logf("scenario failed: %v", err)
+	}
+	return err
+}
+
+func (p *mockPlayer) logf(format string, args ...any) {
+	if p.logger != nil {
+		p.logger.Printf(format, args...)
+	}
+}
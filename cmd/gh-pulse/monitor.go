@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newMonitorCmd(quiet *bool) *cobra.Command {
+	var url string
+	var rulesFile string
+	var reportInterval time.Duration
+	var pushgateway string
+	var statsd string
+
+	cmd := &cobra.Command{
+		Use:   "monitor --url <smee-channel> --rules <rules.yaml>",
+		Short: "Continuously evaluate assertion rules and report their status",
+		Long: `Connect to a smee.io channel and evaluate named pass/fail rules against every event.
+
+Unlike stream and capture, monitor never exits on a match. Every report interval
+it prints one {"type":"status"} JSON line per rule to stdout, with the rule's
+latest status (pass, fail, or pending) and match counts. Status can also be
+pushed to a Prometheus Pushgateway or sent to StatsD.
+
+Rules file format:
+  rules:
+    - name: deploys
+      events: [deployment_status]
+      pass: ["payload.deployment_status.state=success"]
+      fail: ["payload.deployment_status.state=~(failure|error)"]
+
+Exit codes:
+  2   - Configuration error (invalid flag values)
+  130 - Interrupted (Ctrl+C)`,
+		Example: `  # Report rule status every minute
+  gh-pulse monitor --url https://smee.io/my-channel --rules rules.yaml --report-interval 1m
+
+  # Also push metrics to a Pushgateway
+  gh-pulse monitor --url https://smee.io/my-channel --rules rules.yaml --pushgateway http://localhost:9091`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --url"))
+			}
+			if rulesFile == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --rules"))
+			}
+			if reportInterval <= 0 {
+				return usageErr(cmd, fmt.Errorf("--report-interval must be positive"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := assertion.LoadRules(rulesFile)
+			if err != nil {
+				return err
+			}
+			cfg := client.MonitorConfig{
+				URL:            url,
+				Rules:          rules,
+				ReportInterval: reportInterval,
+				Pushgateway:    pushgateway,
+				StatsD:         statsd,
+				Quiet:          *quiet,
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				return client.RunMonitor(ctx, cfg)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", "", "smee.io channel URL (required)")
+	cmd.Flags().StringVar(&rulesFile, "rules", "", "YAML file of named pass/fail rules (required)")
+	cmd.Flags().DurationVar(&reportInterval, "report-interval", time.Minute, "how often to emit status lines")
+	cmd.Flags().StringVar(&pushgateway, "pushgateway", "", "Prometheus Pushgateway URL to push rule status to")
+	cmd.Flags().StringVar(&statsd, "statsd", "", "StatsD host:port to send rule counters to")
+	return cmd
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+func newRedactCmd(quiet *bool) *cobra.Command {
+	var classes []string
+	var rulesFile string
+
+	cmd := &cobra.Command{
+		Use:   "redact <capture.jsonl>",
+		Short: "Scrub emails, names, and secrets from a capture",
+		Long: `Print a JSONL capture with personal data and secrets replaced by "[redacted]",
+so it can be attached to a public bug report. Use - to read stdin.
+
+Built-in redactions (--redact, all by default):
+  emails  email addresses anywhere in the payload
+  tokens  GitHub, AWS, and Slack tokens, JWTs, private keys, credentials in
+          URLs, and the values of token, secret, and password fields
+  names   commit author, committer, pusher, and tagger names
+
+A --redact-file adds field names to remove wherever they appear, paths, and
+regular expressions:
+
+  redact: [emails, tokens]
+  keys: [access_tokens_url]
+  paths: [payload.installation.id, 'payload.commits[].message']
+  patterns: ['corp\.example\.com']
+
+stream, capture, tail, and filter take the same --redact and --redact-file
+flags to scrub events as they arrive.`,
+		Example: `  gh-pulse redact session.jsonl > session.public.jsonl
+
+  # Only secrets, plus an organization's own rules
+  gh-pulse redact session.jsonl --redact tokens --redact-file redact.yaml`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			redactor, err := loadRedactor(classes, rulesFile)
+			if err != nil {
+				return err
+			}
+			input, closeInput, err := openInput(args[0])
+			if err != nil {
+				return err
+			}
+			defer closeInput()
+
+			events, values := 0, 0
+			err = client.Replay(context.Background(), client.Config{}, input, logger, func(msg message.EventMessage) error {
+				redacted, n, err := redactor.Message(msg)
+				if err != nil {
+					return fmt.Errorf("event %s: %w", msg.DeliveryID, err)
+				}
+				events++
+				values += n
+				return writeJSONLine(os.Stdout, redacted)
+			})
+			if err != nil {
+				return err
+			}
+			if logger != nil {
+				logger.Printf("redacted %d values in %d events", values, events)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&classes, "redact", redact.Classes, "built-in redactions to apply: emails, tokens, names (comma-separated)")
+	cmd.Flags().StringVar(&rulesFile, "redact-file", "", "YAML file of additional redaction rules")
+	return cmd
+}
+
+// loadRedactor combines --redact classes and a --redact-file into a
+// Redactor, or returns nil when neither is set.
+func loadRedactor(classes []string, path string) (*redact.Redactor, error) {
+	if len(classes) == 0 && path == "" {
+		return nil, nil
+	}
+	var rules redact.Rules
+	if path != "" {
+		var err error
+		if rules, err = redact.LoadRules(path); err != nil {
+			return nil, err
+		}
+	}
+	rules.Redact = append(rules.Redact, classes...)
+	redactor, err := redact.New(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --redact: %w", err)
+	}
+	return redactor, nil
+}
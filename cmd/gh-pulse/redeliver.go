@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/spf13/cobra"
+)
+
+// redeliveryRecord is the JSON line printed for each delivery sent again.
+type redeliveryRecord struct {
+	Type        string    `json:"type"`
+	Repo        string    `json:"repo"`
+	HookID      int64     `json:"hook_id"`
+	DeliveryID  string    `json:"delivery_id"`
+	Event       string    `json:"event"`
+	Action      string    `json:"action,omitempty"`
+	StatusCode  int       `json:"status_code"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	DryRun      bool      `json:"dry_run,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func newRedeliverCmd(quiet *bool) *cobra.Command {
+	var repo string
+	var hookID int64
+	var guids []string
+	var failed bool
+	var since time.Duration
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "redeliver --repo <owner/name> (--delivery <guid> | --failed)",
+		Short: "Ask GitHub to redeliver webhook deliveries",
+		Long: `Request redelivery of repository webhook deliveries through GitHub's hook
+deliveries API, to recover events missed while a relay or handler was down.
+
+--delivery redelivers specific deliveries by GUID (the delivery_id field of
+gh-pulse output). --failed redelivers every delivery from the last --since
+whose most recent attempt did not get a 2xx response. Deliveries are searched
+on --hook, or on every hook of the repository.
+
+Each redelivery is printed as a {"type":"redelivery"} JSON line, oldest first.
+
+Requires a token with admin access to the repository in GITHUB_TOKEN (or
+GH_TOKEN).
+
+Exit codes:
+  0   - Every redelivery was requested
+  1   - A delivery was not found or a redelivery request failed`,
+		Example: `  gh-pulse redeliver --repo me/app --delivery 72d3162e-cc78-11e3-81ab-4c9367dc0958
+
+  # Recover everything that failed during the last two hours
+  gh-pulse redeliver --repo me/app --failed --since 2h
+
+  # See what would be redelivered
+  gh-pulse redeliver --repo me/app --failed --dry-run`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if (len(guids) == 0) == !failed {
+				return usageErr(cmd, fmt.Errorf("exactly one of --delivery or --failed is required"))
+			}
+			for _, guid := range guids {
+				if strings.TrimSpace(guid) == "" {
+					return usageErr(cmd, fmt.Errorf("--delivery must be non-empty"))
+				}
+			}
+			if since <= 0 {
+				return usageErr(cmd, fmt.Errorf("--since must be positive"))
+			}
+			if hookID < 0 {
+				return usageErr(cmd, fmt.Errorf("--hook must be positive"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := hookClient()
+			if err != nil {
+				return err
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+
+			return runWithSignals(func(ctx context.Context) error {
+				hookIDs := []int64{hookID}
+				if hookID == 0 {
+					hooks, err := api.ListHooks(ctx, repo)
+					if err != nil {
+						return fmt.Errorf("failed to list hooks on %s: %w", repo, err)
+					}
+					hookIDs = hookIDs[:0]
+					for _, hook := range hooks {
+						hookIDs = append(hookIDs, hook.ID)
+					}
+				}
+
+				wanted := make(map[string]bool)
+				for _, guid := range guids {
+					wanted[strings.TrimSpace(guid)] = true
+				}
+				cutoff := time.Now().Add(-since)
+				requestFailed := false
+
+				for _, id := range hookIDs {
+					deliveries := github.Deliveries{API: api, Base: fmt.Sprintf("/repos/%s/hooks/%d", repo, id)}
+					var targets []github.Delivery
+					if failed {
+						targets, err = failedDeliveries(ctx, deliveries, cutoff)
+					} else {
+						targets, err = findDeliveries(ctx, deliveries, wanted)
+					}
+					if err != nil {
+						return fmt.Errorf("failed to read deliveries of hook %d on %s: %w", id, repo, err)
+					}
+
+					for i := len(targets) - 1; i >= 0; i-- {
+						delivery := targets[i]
+						record := redeliveryRecord{
+							Type:        "redelivery",
+							Repo:        repo,
+							HookID:      id,
+							DeliveryID:  delivery.GUID,
+							Event:       delivery.Event,
+							Action:      delivery.Action,
+							StatusCode:  delivery.StatusCode,
+							DeliveredAt: delivery.DeliveredAt,
+							DryRun:      dryRun,
+						}
+						if !dryRun {
+							if err := deliveries.Redeliver(ctx, delivery.ID); err != nil {
+								if ctx.Err() != nil {
+									return ctx.Err()
+								}
+								record.Error = err.Error()
+								requestFailed = true
+							}
+						}
+						if logger != nil {
+							switch {
+							case record.Error != "":
+								logger.Printf("failed to redeliver %s (%s): %s", delivery.GUID, delivery.Event, record.Error)
+							case dryRun:
+								logger.Printf("would redeliver %s (%s, status %d)", delivery.GUID, delivery.Event, delivery.StatusCode)
+							default:
+								logger.Printf("requested redelivery of %s (%s, status %d)", delivery.GUID, delivery.Event, delivery.StatusCode)
+							}
+						}
+						if err := writeJSONLine(os.Stdout, record); err != nil {
+							return err
+						}
+					}
+				}
+
+				if len(wanted) > 0 {
+					missing := make([]string, 0, len(wanted))
+					for guid := range wanted {
+						missing = append(missing, guid)
+					}
+					sort.Strings(missing)
+					return fmt.Errorf("delivery not found on %s: %s", repo, strings.Join(missing, ", "))
+				}
+				if requestFailed {
+					return exitError{code: 1}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().Int64Var(&hookID, "hook", 0, "only search this hook's deliveries (default: every hook on the repository)")
+	cmd.Flags().StringArrayVar(&guids, "delivery", nil, "GUID of a delivery to redeliver (can repeat)")
+	cmd.Flags().BoolVar(&failed, "failed", false, "redeliver every recent delivery whose last attempt failed")
+	cmd.Flags().DurationVar(&since, "since", time.Hour, "how far back --failed looks (e.g., 30m, 6h)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the deliveries without redelivering them")
+	return cmd
+}
+
+// failedDeliveries returns, newest first, the deliveries since cutoff whose
+// most recent attempt failed. Each GUID appears once.
+func failedDeliveries(ctx context.Context, deliveries github.Deliveries, cutoff time.Time) ([]github.Delivery, error) {
+	var failed []github.Delivery
+	seen := make(map[string]bool)
+	err := deliveries.Walk(ctx, func(delivery github.Delivery) bool {
+		if delivery.DeliveredAt.Before(cutoff) {
+			return false
+		}
+		if seen[delivery.GUID] {
+			return true
+		}
+		// The log is newest first, so the first attempt seen is the latest.
+		seen[delivery.GUID] = true
+		if delivery.Failed() {
+			failed = append(failed, delivery)
+		}
+		return true
+	})
+	return failed, err
+}
+
+// findDeliveries returns the latest attempt of each wanted GUID, newest
+// first, removing found GUIDs from wanted.
+func findDeliveries(ctx context.Context, deliveries github.Deliveries, wanted map[string]bool) ([]github.Delivery, error) {
+	var found []github.Delivery
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	err := deliveries.Walk(ctx, func(delivery github.Delivery) bool {
+		if wanted[delivery.GUID] {
+			delete(wanted, delivery.GUID)
+			found = append(found, delivery)
+		}
+		return len(wanted) > 0
+	})
+	return found, err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/scenario"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+func newReplayCmd(quiet *bool) *cobra.Command {
+	var file string
+	var scenarioFile string
+	var target string
+	var secret string
+	var events []string
+	var delay time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "replay (--file <events.jsonl> | --scenario <file|name>) --target <url>",
+		Short: "POST captured or generated events to a webhook endpoint",
+		Long: `Deliver each event in a JSONL file to --target as a GitHub webhook.
+
+Requests carry the X-GitHub-Event and X-GitHub-Delivery headers, and an
+X-Hub-Signature-256 header when --secret is set. The target may be a local
+handler or a smee.io channel. Use --file - to read from stdin.
+
+With --scenario, the events of a scenario (see mock-github) are generated
+and delivered instead, waiting out each step's delay.
+
+Exit codes:
+  0   - Every delivery got a 2xx response
+  1   - At least one delivery failed`,
+		Example: `  # Replay a capture against a local handler
+  gh-pulse replay --file events.jsonl --target http://localhost:3000/webhook --secret $WEBHOOK_SECRET
+
+  # Generate and deliver in one go
+  gh-pulse generate push --repo me/app --commits 3 | gh-pulse replay --file - --target http://localhost:3000/webhook
+
+  # Play a scripted storyline against a local handler
+  gh-pulse replay --scenario pr-lifecycle --target http://localhost:3000/webhook`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" && scenarioFile == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --file or --scenario"))
+			}
+			if file != "" && scenarioFile != "" {
+				return usageErr(cmd, fmt.Errorf("--file and --scenario cannot be combined"))
+			}
+			if target == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --target"))
+			}
+			if delay < 0 {
+				return usageErr(cmd, fmt.Errorf("--delay must be non-negative"))
+			}
+			return usageErr(cmd, validateEvents(events))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.ValidateURL(target); err != nil {
+				return err
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			source := func(ctx context.Context, handle func(message.EventMessage) error) error {
+				input, closeInput, err := openInput(file)
+				if err != nil {
+					return err
+				}
+				defer closeInput()
+				return client.Replay(ctx, client.Config{Events: events}, input, logger, handle)
+			}
+			if scenarioFile != "" {
+				s, err := scenario.Load(scenarioFile)
+				if err != nil {
+					return err
+				}
+				source = func(ctx context.Context, handle func(message.EventMessage) error) error {
+					return s.Play(ctx, func(msg message.EventMessage) error {
+						if len(events) > 0 && !slices.Contains(events, msg.Event) {
+							return nil
+						}
+						return handle(msg)
+					})
+				}
+			}
+
+			httpClient := &http.Client{Timeout: 30 * time.Second}
+			failed := 0
+			return runWithSignals(func(ctx context.Context) error {
+				sent := 0
+				err := source(ctx, func(msg message.EventMessage) error {
+					if sent > 0 && delay > 0 {
+						select {
+						case <-ctx.Done():
+							return ctx.Err()
+						case <-time.After(delay):
+						}
+					}
+					sent++
+					status, err := webhook.Post(ctx, httpClient, target, msg, secret)
+					if err != nil {
+						failed++
+						if logger != nil {
+							logger.Print(err)
+						}
+						return nil
+					}
+					if status < 200 || status > 299 {
+						failed++
+					}
+					if logger != nil {
+						logger.Printf("delivered %s (%s): %d %s", msg.DeliveryID, msg.Event, status, http.StatusText(status))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				if logger != nil {
+					logger.Printf("replayed %d events, %d failed", sent, failed)
+				}
+				if failed > 0 {
+					return exitError{code: 1}
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "JSONL events to deliver (- for stdin)")
+	cmd.Flags().StringVar(&scenarioFile, "scenario", "", "generate the events of a scenario YAML file or built-in scenario instead")
+	cmd.Flags().StringVar(&target, "target", "", "URL to POST each event to (required)")
+	cmd.Flags().StringVar(&secret, "secret", "", "webhook secret used to sign each delivery")
+	cmd.Flags().StringArrayVar(&events, "event", nil, "only replay these event types (can repeat)")
+	cmd.Flags().DurationVar(&delay, "delay", 0, "pause between deliveries (e.g., 500ms)")
+	return cmd
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/sse"
+	"github.com/spf13/cobra"
+)
+
+// setupRecord is the JSON line printed once setup has verified delivery.
+type setupRecord struct {
+	Type    string   `json:"type"`
+	Repo    string   `json:"repo"`
+	Channel string   `json:"channel"`
+	HookID  int64    `json:"hook_id"`
+	Events  []string `json:"events"`
+	Command string   `json:"command"`
+}
+
+func newSetupCmd(quiet *bool) *cobra.Command {
+	var repo string
+	var server string
+	var channel string
+	var events []string
+	var secret string
+	var timeoutSeconds int
+
+	cmd := &cobra.Command{
+		Use:   "setup --repo <owner/name>",
+		Short: "Create a channel and webhook, and verify delivery end to end",
+		Long: `Bootstrap a repository for gh-pulse in one step: create a new channel on
+--server (or use --url), register a repository webhook that delivers to it,
+ask GitHub to ping the hook, and wait for the ping to arrive on the channel.
+
+Once the ping arrives, a {"type":"setup"} JSON line with the channel, hook id,
+and the stream command to run is printed to stdout. If it does not arrive,
+the hook is left in place for inspection; run gh-pulse doctor against the
+channel and check the hook's recent deliveries on GitHub.
+
+Requires a token with admin access to the repository in GITHUB_TOKEN (or
+GH_TOKEN).
+
+Exit codes:
+  0   - Ping received through the channel
+  1   - Channel or hook could not be created
+  2   - Configuration error (invalid URL)
+  124 - Ping not received within --timeout
+  130 - Interrupted (Ctrl+C)`,
+		Example: `  gh-pulse setup --repo me/app
+
+  # Self-hosted smee server, only push and pull_request events
+  gh-pulse setup --repo me/app --server https://smee.internal.example.com --events push,pull_request`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if len(events) == 0 {
+				return usageErr(cmd, fmt.Errorf("--events must name at least one event"))
+			}
+			if timeoutSeconds <= 0 {
+				return usageErr(cmd, fmt.Errorf("--timeout must be positive"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if channel != "" {
+				if err := client.ValidateURL(channel); err != nil {
+					return err
+				}
+			} else if err := client.ValidateURL(server); err != nil {
+				return err
+			}
+			api, err := hookClient()
+			if err != nil {
+				return err
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			logf := func(format string, args ...interface{}) {
+				if logger != nil {
+					logger.Printf(format, args...)
+				}
+			}
+
+			return runWithSignals(func(ctx context.Context) error {
+				if channel == "" {
+					created, err := newChannel(ctx, server)
+					if err != nil {
+						return fmt.Errorf("failed to create channel on %s: %w", server, err)
+					}
+					channel = created
+					logf("created channel %s", channel)
+				}
+
+				hook, err := api.CreateHook(ctx, repo, github.HookOptions{URL: channel, Events: events, Secret: secret})
+				if err != nil {
+					return fmt.Errorf("failed to create hook on %s: %w", repo, err)
+				}
+				logf("created hook %d on %s for %s", hook.ID, repo, strings.Join(hook.Events, ","))
+
+				logf("waiting for ping from hook %d", hook.ID)
+				if err := awaitPing(ctx, api, repo, hook.ID, channel, time.Duration(timeoutSeconds)*time.Second); err != nil {
+					if errors.Is(err, context.DeadlineExceeded) {
+						logf("ping not received within %ds; hook %d was left in place (gh-pulse doctor --url %s)", timeoutSeconds, hook.ID, channel)
+						return exitError{code: 124}
+					}
+					return err
+				}
+
+				command := "gh-pulse stream --url " + channel
+				if secret != "" {
+					command += ` --verify-secret "$WEBHOOK_SECRET"`
+				}
+				logf("ping received; start streaming with: %s", command)
+				return writeJSONLine(os.Stdout, setupRecord{
+					Type:    "setup",
+					Repo:    repo,
+					Channel: channel,
+					HookID:  hook.ID,
+					Events:  hook.Events,
+					Command: command,
+				})
+			})
+		},
+	}
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().StringVar(&server, "server", "https://smee.io", "smee server to create the channel on")
+	cmd.Flags().StringVar(&channel, "url", "", "use this existing channel instead of creating one")
+	cmd.Flags().StringSliceVar(&events, "events", []string{"*"}, "events to subscribe to, comma-separated (* for all)")
+	cmd.Flags().StringVar(&secret, "secret", "", "webhook secret GitHub signs deliveries with")
+	cmd.Flags().IntVar(&timeoutSeconds, "timeout", 60, "seconds to wait for the ping to arrive")
+	return cmd
+}
+
+// newChannel asks a smee server for a new channel; smee answers /new with a
+// redirect to it. Servers without /new get a random channel path instead.
+func newChannel(ctx context.Context, server string) (string, error) {
+	base, err := url.Parse(strings.TrimRight(server, "/"))
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String()+"/new", nil)
+	if err != nil {
+		return "", err
+	}
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != "" && resp.StatusCode >= 300 && resp.StatusCode <= 399 {
+		channel, err := base.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		return channel.String(), nil
+	}
+
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	return base.String() + "/gh-pulse-" + hex.EncodeToString(suffix), nil
+}
+
+// awaitPing subscribes to the channel and pings the hook until its ping event
+// comes back. The subscription may not be open when the first ping is sent,
+// so the ping is repeated every few seconds.
+func awaitPing(ctx context.Context, api *github.Client, repo string, hookID int64, channel string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	received := make(chan struct{})
+	stream := sse.NewClient(channel, nil)
+	go func() {
+		_ = stream.Run(ctx, func(msg message.EventMessage) error {
+			if msg.Event != "ping" {
+				return nil
+			}
+			var payload struct {
+				HookID int64 `json:"hook_id"`
+			}
+			if json.Unmarshal(msg.Payload, &payload) == nil && payload.HookID == hookID {
+				close(received)
+				return context.Canceled
+			}
+			return nil
+		})
+	}()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := api.PingHook(ctx, repo, hookID); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to ping hook %d: %w", hookID, err)
+		}
+		select {
+		case <-received:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// splitRecord is the JSON line printed for each file written by split.
+type splitRecord struct {
+	Type   string `json:"type"`
+	File   string `json:"file"`
+	Events int    `json:"events"`
+}
+
+func newSplitCmd(quiet *bool) *cobra.Command {
+	var by, outDir string
+
+	cmd := &cobra.Command{
+		Use:   "split <capture.jsonl> --out-dir <dir>",
+		Short: "Break a capture into one file per event type or repository",
+		Long: `Write the events of a JSONL capture to one <value>.jsonl file per value of an
+envelope path, like --split-by does for a live stream. Use - to read stdin.
+
+--by takes the same paths as assertions: event (the default) groups by event
+type, payload.repository.full_name by repository (owner/repo is written as
+owner_repo.jsonl). Events without a value go to unknown.jsonl. Files are
+appended to, and each is printed as a {"type":"split"} JSON line with the
+number of events written to it.`,
+		Example: `  gh-pulse split session.jsonl --out-dir ./split
+
+  # One file per repository
+  gh-pulse split session.jsonl --by payload.repository.full_name --out-dir ./by-repo`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if outDir == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --out-dir"))
+			}
+			if err := assertion.ValidatePath(by); err != nil {
+				return usageErr(cmd, fmt.Errorf("invalid --by: %w", err))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			input, closeInput, err := openInput(args[0])
+			if err != nil {
+				return err
+			}
+			defer closeInput()
+
+			results, err := client.Split(context.Background(), input, outDir, by, logger)
+			if err != nil {
+				return err
+			}
+			for _, r := range results {
+				if err := writeJSONLine(os.Stdout, splitRecord{Type: "split", File: r.File, Events: r.Events}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&by, "by", "event", "envelope path whose value names each file")
+	cmd.Flags().StringVar(&outDir, "out-dir", "", "directory to write <value>.jsonl files to (required)")
+	return cmd
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+func newStatsCmd(quiet *bool) *cobra.Command {
+	var url string
+	var file string
+	var events []string
+	var ignoreFile string
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "stats (--url <smee-channel> | --file <capture.jsonl>)",
+		Short: "Summarise webhook traffic live or from a capture file",
+		Long: `Aggregate events into counts per event type, action, and repository, payload
+size percentiles, events per minute, and delivery latency percentiles for
+events that carry a GitHub timestamp (e.g. workflow_run.updated_at).
+
+With --url, a {"type":"stats"} JSON line is printed every --interval and once
+more on exit. With --file, the capture is read once and a single report is
+printed. Use --file - to read from stdin.`,
+		Example: `  # Live stats, refreshed every 30 seconds
+  gh-pulse stats --url https://smee.io/my-channel --interval 30s
+
+  # Summarise an existing capture
+  gh-pulse stats --file events.jsonl`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if (url == "") == (file == "") {
+				return usageErr(cmd, fmt.Errorf("exactly one of --url or --file is required"))
+			}
+			if interval <= 0 {
+				return usageErr(cmd, fmt.Errorf("--interval must be positive"))
+			}
+			return usageErr(cmd, validateEvents(events))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := client.Config{URL: url, Events: events, Quiet: *quiet}
+			if ignoreFile != "" {
+				ignore, err := assertion.LoadIgnoreFile(ignoreFile)
+				if err != nil {
+					return err
+				}
+				cfg.Ignore = ignore
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			if file != "" {
+				return statsFromFile(cfg, file, logger)
+			}
+			return runWithSignals(func(ctx context.Context) error {
+				return liveStats(ctx, cfg, interval, logger)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", "", "smee.io channel URL for live stats")
+	cmd.Flags().StringVar(&file, "file", "", "JSONL capture file to summarise (- for stdin)")
+	cmd.Flags().StringArrayVar(&events, "event", nil, "filter by GitHub event type (can repeat)")
+	cmd.Flags().StringVar(&ignoreFile, "ignore-file", "", "YAML file of assertion patterns for events to exclude")
+	cmd.Flags().DurationVar(&interval, "interval", 10*time.Second, "how often to print live stats")
+	return cmd
+}
+
+func statsFromFile(cfg client.Config, file string, logger *log.Logger) error {
+	input, closeInput, err := openInput(file)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	agg := stats.NewAggregator()
+	err = client.Replay(context.Background(), cfg, input, logger, func(msg message.EventMessage) error {
+		agg.Add(msg)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report := agg.Report(time.Time{})
+	warnSkew(logger, report, 0)
+	return writeJSONLine(os.Stdout, report)
+}
+
+func liveStats(ctx context.Context, cfg client.Config, interval time.Duration, logger *log.Logger) error {
+	if err := client.ValidateURL(cfg.URL); err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	agg := stats.NewAggregator()
+	started := time.Now()
+	skewed := 0
+	report := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		r := agg.Report(started)
+		skewed = warnSkew(logger, r, skewed)
+		return writeJSONLine(os.Stdout, r)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Watch(ctx, cfg, logger, func(msg message.EventMessage) {
+			mu.Lock()
+			agg.Add(msg)
+			mu.Unlock()
+		})
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := report(); err != nil {
+				return err
+			}
+		case err := <-done:
+			if reportErr := report(); reportErr != nil {
+				return reportErr
+			}
+			return err
+		}
+	}
+}
+
+// warnSkew logs when more events than already warned about were timestamped
+// after they arrived, and returns the new count.
+func warnSkew(logger *log.Logger, report stats.Report, warned int) int {
+	if report.Latency == nil || report.Latency.Skewed <= warned {
+		return warned
+	}
+	if logger != nil {
+		logger.Printf("warning: %d events were timestamped more than %s after they arrived; the local clock may be behind", report.Latency.Skewed, stats.SkewTolerance)
+	}
+	return report.Latency.Skewed
+}
+
+// openInput opens a JSONL file, or stdin for "-".
+func openInput(path string) (io.Reader, func(), error) {
+	if path == "-" {
+		return os.Stdin, func() {}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { _ = f.Close() }, nil
+}
+
+func writeJSONLine(w io.Writer, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
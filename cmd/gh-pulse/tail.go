@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newTailCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+
+	cmd := &cobra.Command{
+		Use:   "tail --file <events.jsonl>",
+		Short: "Apply stream filters and assertions to a JSONL file",
+		Long: `Read events from a JSONL file written by stream or capture and apply the same
+filters, transforms, and exit assertions as stream. With --follow, lines
+appended to the file are read as they arrive, so one recorder process can
+feed any number of analyzers:
+
+  gh-pulse stream --url "$SMEE_URL" >> events.jsonl &
+  gh-pulse tail --file events.jsonl --follow --success-on "event=push"
+
+Lines that are not events (e.g. result records) are skipped. A followed file
+that is truncated is read again from the start.
+
+Exit codes:
+  0   - Success assertion matched, or the file ended with no success condition set
+  1   - Failure assertion matched, a deadline was missed, or the file ended
+        before a success condition was met
+  2   - Configuration error (invalid flag values, unreadable file)
+  124 - Timeout reached (--timeout)
+  130 - Interrupted (Ctrl+C)`,
+		Example: `  # Check a finished capture for a successful deployment
+  gh-pulse tail --file session.jsonl --success-on "payload.deployment_status.state=success"
+
+  # Follow a file another process is writing to, waiting up to 10 minutes
+  gh-pulse tail --file events.jsonl --follow --event check_run --failure-on "payload.check_run.conclusion=failure" --timeout 600`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.file == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --file"))
+			}
+			if opts.file == "-" && opts.follow {
+				return usageErr(cmd, fmt.Errorf("--follow cannot be used with --file -"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := opts.config(*quiet)
+			if err != nil {
+				return err
+			}
+			return runClient(client.Run, cfg)
+		},
+	}
+	opts.addPipelineFlags(cmd)
+	opts.addStreamFlags(cmd)
+	cmd.Flags().StringVar(&opts.file, "file", "", "JSONL file of events to read, or - for stdin (required)")
+	cmd.Flags().BoolVarP(&opts.follow, "follow", "f", false, "keep reading lines appended to the file")
+	return cmd
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+type validationResult struct {
+	Type       string   `json:"type"`
+	Event      string   `json:"event"`
+	DeliveryID string   `json:"delivery_id"`
+	Truncated  bool     `json:"truncated"`
+	Errors     []string `json:"errors"`
+}
+
+type validationSummary struct {
+	Type      string `json:"type"`
+	Events    int    `json:"events"`
+	Valid     int    `json:"valid"`
+	Invalid   int    `json:"invalid"`
+	Truncated int    `json:"truncated"`
+	Unchecked int    `json:"unchecked"`
+}
+
+func newValidateCmd(quiet *bool) *cobra.Command {
+	var file string
+	var schemaDir string
+	var events []string
+
+	cmd := &cobra.Command{
+		Use:   "validate --file <events.jsonl>",
+		Short: "Check captured payloads against GitHub webhook schemas",
+		Long: `Validate each event in a JSONL capture against a JSON schema for its event type.
+
+Schemas for common event types are built in; use --schema-dir to add or
+override schemas with <event>.json files. Each failing event is printed as a
+{"type":"validation"} JSON line listing the problems, followed by a
+{"type":"summary"} line. Events marked truncated are reported but counted
+separately, since truncation is expected to drop fields.
+
+Exit codes:
+  0   - All checked events conform (or failed only due to truncation)
+  1   - At least one untruncated event does not conform`,
+		Example: `  gh-pulse validate --file events.jsonl
+
+  # Use your own schemas alongside the built-in ones
+  gh-pulse validate --file events.jsonl --schema-dir ./schemas`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --file"))
+			}
+			return usageErr(cmd, validateEvents(events))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			validator, err := schema.NewValidator(schemaDir)
+			if err != nil {
+				return err
+			}
+			var logger *log.Logger
+			if !*quiet {
+				logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			input, closeInput, err := openInput(file)
+			if err != nil {
+				return err
+			}
+			defer closeInput()
+
+			summary := validationSummary{Type: "summary"}
+			unchecked := make(map[string]bool)
+			err = client.Replay(context.Background(), client.Config{Events: events}, input, logger, func(msg message.EventMessage) error {
+				summary.Events++
+				checked, problems := validator.Validate(msg.Event, msg.Payload)
+				switch {
+				case !checked:
+					summary.Unchecked++
+					unchecked[msg.Event] = true
+					return nil
+				case len(problems) == 0:
+					summary.Valid++
+					return nil
+				case msg.Truncated:
+					summary.Truncated++
+				default:
+					summary.Invalid++
+				}
+				return writeJSONLine(os.Stdout, validationResult{
+					Type:       "validation",
+					Event:      msg.Event,
+					DeliveryID: msg.DeliveryID,
+					Truncated:  msg.Truncated,
+					Errors:     problems,
+				})
+			})
+			if err != nil {
+				return err
+			}
+			if logger != nil && len(unchecked) > 0 {
+				names := make([]string, 0, len(unchecked))
+				for name := range unchecked {
+					names = append(names, name)
+				}
+				logger.Printf("no schema for event types: %s (known: %s)", strings.Join(names, ", "), strings.Join(validator.Events(), ", "))
+			}
+			if err := writeJSONLine(os.Stdout, summary); err != nil {
+				return err
+			}
+			if summary.Invalid > 0 {
+				return exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "JSONL capture to validate (- for stdin) (required)")
+	cmd.Flags().StringVar(&schemaDir, "schema-dir", "", "directory of <event>.json schemas to add or override")
+	cmd.Flags().StringArrayVar(&events, "event", nil, "only validate these event types (can repeat)")
+	return cmd
+}
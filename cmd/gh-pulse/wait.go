@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newWaitCmd(quiet *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wait",
+		Short: "Wait for a common GitHub workflow to finish",
+		Long: `Stream a channel until a common GitHub milestone is reached, without
+writing the assertions by hand. Each subcommand keeps only the events for the
+workflow, commit, pull request, or release it was given, prints them as JSONL
+like stream, reports progress on stderr, and exits like stream:
+
+  0   - The milestone was reached
+  1   - It failed (e.g. the workflow concluded with failure)
+  2   - Configuration error (invalid flag values)
+  69  - Channel unreachable (--fail-fast, --max-retries)
+  124 - Timeout reached (--timeout)
+  130 - Interrupted (Ctrl+C)`,
+	}
+	cmd.AddCommand(newWaitWorkflowCmd(quiet), newWaitChecksCmd(quiet), newWaitPRMergedCmd(quiet), newWaitDeploymentCmd(quiet), newWaitReleaseCmd(quiet), newWaitCommentCmd(quiet))
+	return cmd
+}
+
+// addWaitFlags registers the flags every wait subcommand shares: the event
+// sources and how long to wait.
+func addWaitFlags(cmd *cobra.Command, opts *runOptions) {
+	opts.addSourceFlags(cmd)
+	cmd.Flags().IntVar(&opts.timeoutSeconds, "timeout", 0, "exit 124 after N seconds (0 = no timeout)")
+	cmd.Flags().BoolVar(&opts.result, "result", false, "end output with a {\"type\":\"result\"} line naming the exit code, rule, and deciding event")
+}
+
+// waitRun is what a wait subcommand needs beyond the shared flags: the
+// events to read, the assertions that end the run, and a stage that keeps
+// only the events it is tracking. settleCancel matches the events that
+// cancel a pending --settle period.
+type waitRun struct {
+	events       []string
+	success      []string
+	failure      []string
+	settleCancel []string
+	stages       []client.Middleware
+}
+
+func runWait(opts runOptions, quiet bool, w waitRun) error {
+	opts.events = w.events
+	opts.successOn = w.success
+	opts.failureOn = w.failure
+	cfg, err := opts.config(quiet)
+	if err != nil {
+		return err
+	}
+	if cfg.SettleCancel, err = assertion.ParseAssertions(w.settleCancel, 0); err != nil {
+		return err
+	}
+	cfg.Middleware = w.stages
+	return runClient(client.Run, cfg)
+}
+
+// waitLogger returns the logger for a wait subcommand's progress lines.
+func waitLogger(quiet bool) *log.Logger {
+	if quiet {
+		return nil
+	}
+	return log.New(os.Stderr, "", log.LstdFlags)
+}
+
+// keepDocs returns a stage that drops the deliveries keep rejects.
+func keepDocs(keep func(doc assertion.Document) bool) client.Middleware {
+	return func(next client.Handler) client.Handler {
+		return client.HandlerFunc(func(d *client.Delivery) error {
+			doc, err := d.Document()
+			if err != nil || !keep(doc) {
+				return nil
+			}
+			return next.Handle(d)
+		})
+	}
+}
+
+// lookup returns the value at path, or "" when it is missing or null.
+func lookup(doc assertion.Document, path string) string {
+	value, ok := doc.Lookup(path)
+	if !ok || value == "null" {
+		return ""
+	}
+	return value
+}
+
+// shaMatches reports whether sha is the full commit SHA or an abbreviation
+// of it.
+func shaMatches(full, sha string) bool {
+	return full != "" && len(sha) >= 7 && strings.HasPrefix(full, strings.ToLower(sha))
+}
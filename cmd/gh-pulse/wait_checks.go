@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/spf13/cobra"
+)
+
+var (
+	passedConclusions = map[string]bool{"success": true, "neutral": true, "skipped": true}
+	failedConclusions = map[string]bool{"failure": true, "cancelled": true, "timed_out": true, "action_required": true, "startup_failure": true, "stale": true}
+)
+
+func newWaitChecksCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var repo, sha string
+	var expected, suites []string
+
+	cmd := &cobra.Command{
+		Use:   "checks --url <smee-channel> --repo <owner/name> --sha <sha>",
+		Short: "Wait for the checks on a commit to pass",
+		Long: `Track the check_run, check_suite, and status events for a commit and exit 1
+on the first failed check or check suite, or 0 once the checks have passed:
+every --check name and the check suite of every --suite app when given.
+Otherwise every check suite seen for the commit must have passed along with
+every check run and status seen so far, and no other check may start for
+--settle afterwards, so suites that report late are waited for too.
+
+Each event is printed with "enrichment": {"checks": {"state": ...}}, the
+commit's combined state once it is counted: pending, success, or failure.
+A summary table of the checks is printed to stderr when the run ends.`,
+		Example: `  gh-pulse wait checks --url "$SMEE_URL" --repo me/app --sha "$(git rev-parse HEAD)" --timeout 1800
+
+  # Only these checks must pass
+  gh-pulse wait checks --url "$SMEE_URL" --repo me/app --sha "$SHA" --check build --check ci/jenkins
+
+  # The check suites of these apps must pass
+  gh-pulse wait checks --url "$SMEE_URL" --repo me/app --sha "$SHA" --suite "GitHub Actions" --suite Buildkite`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if len(sha) < 7 {
+				return usageErr(cmd, fmt.Errorf("--sha must be at least 7 characters"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(expected) > 0 || len(suites) > 0 {
+				opts.settle = 0
+			}
+			tracker := newChecksTracker(repo, sha, expected, suites, waitLogger(*quiet))
+			err := runWait(opts, *quiet, waitRun{
+				events:       []string{"check_run", "check_suite", "status"},
+				success:      []string{"enrichment.checks.state=success"},
+				failure:      []string{"enrichment.checks.state=failure"},
+				settleCancel: []string{"enrichment.checks.state=pending"},
+				stages:       []client.Middleware{tracker.stage},
+			})
+			if !*quiet {
+				tracker.summary(os.Stderr)
+			}
+			return err
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().StringVar(&sha, "sha", "", "commit SHA, full or abbreviated (required)")
+	cmd.Flags().StringArrayVar(&expected, "check", nil, "check run name or status context that must pass (can repeat; default: all that report)")
+	cmd.Flags().StringArrayVar(&suites, "suite", nil, "app whose check suite must pass, e.g. 'GitHub Actions' (can repeat; default: all that report)")
+	cmd.Flags().DurationVar(&opts.settle, "settle", 30*time.Second, "without --check or --suite, how long no other check may start after the checks seen have passed")
+	return cmd
+}
+
+// checkState is the latest known outcome of one check run or status context.
+type checkState struct {
+	kind   string
+	result string
+}
+
+// checkSuite is the latest known state of one check suite.
+type checkSuite struct {
+	app string
+	// conclusion is "" until the suite has completed.
+	conclusion string
+}
+
+// checksTracker follows the checks reported for one commit.
+type checksTracker struct {
+	repo           string
+	sha            string
+	expected       []string
+	expectedSuites []string
+	checks         map[string]*checkState
+	order          []string
+	// suites maps check suite IDs to their state, and suiteOrder lists the
+	// IDs in the order they were first seen.
+	suites     map[string]*checkSuite
+	suiteOrder []string
+	logger     *log.Logger
+}
+
+func newChecksTracker(repo, sha string, expected, expectedSuites []string, logger *log.Logger) *checksTracker {
+	return &checksTracker{
+		repo:           repo,
+		sha:            sha,
+		expected:       expected,
+		expectedSuites: expectedSuites,
+		checks:         make(map[string]*checkState),
+		suites:         make(map[string]*checkSuite),
+		logger:         logger,
+	}
+}
+
+// stage keeps the events for the commit and adds the commit's combined
+// state to each, for the wait's success and failure assertions to match.
+func (t *checksTracker) stage(next client.Handler) client.Handler {
+	return client.HandlerFunc(func(d *client.Delivery) error {
+		doc, err := d.Document()
+		if err != nil || !strings.EqualFold(lookup(doc, "payload.repository.full_name"), t.repo) {
+			return nil
+		}
+		failed, ok := t.observe(doc)
+		if !ok {
+			return nil
+		}
+		state := "pending"
+		switch {
+		case failed:
+			state = "failure"
+		case t.passed():
+			state = "success"
+		}
+		msg, err := withChecksState(d.Message, state)
+		if err != nil {
+			return nil
+		}
+		d.SetMessage(msg)
+		return next.Handle(d)
+	})
+}
+
+// withChecksState adds {"checks": {"state": state}} to the message's
+// enrichment.
+func withChecksState(msg message.EventMessage, state string) (message.EventMessage, error) {
+	enrichment := make(map[string]any)
+	if len(msg.Enrichment) > 0 {
+		if err := json.Unmarshal(msg.Enrichment, &enrichment); err != nil {
+			return msg, err
+		}
+	}
+	enrichment["checks"] = map[string]string{"state": state}
+	encoded, err := json.Marshal(enrichment)
+	if err != nil {
+		return msg, err
+	}
+	msg.Enrichment = encoded
+	return msg, nil
+}
+
+// observe records an event for the commit and reports whether it is a
+// failure; ok is false for events about other commits.
+func (t *checksTracker) observe(doc assertion.Document) (failed, ok bool) {
+	switch lookup(doc, "event") {
+	case "check_run":
+		if !shaMatches(lookup(doc, "payload.check_run.head_sha"), t.sha) {
+			return false, false
+		}
+		result := lookup(doc, "payload.check_run.status")
+		if result == "completed" {
+			result = lookup(doc, "payload.check_run.conclusion")
+		}
+		t.set(lookup(doc, "payload.check_run.name"), "check_run", result)
+		return failedConclusions[result], true
+	case "check_suite":
+		if !shaMatches(lookup(doc, "payload.check_suite.head_sha"), t.sha) {
+			return false, false
+		}
+		conclusion := ""
+		if lookup(doc, "payload.check_suite.status") == "completed" {
+			conclusion = lookup(doc, "payload.check_suite.conclusion")
+		}
+		id := lookup(doc, "payload.check_suite.id")
+		if _, ok := t.suites[id]; !ok {
+			t.suiteOrder = append(t.suiteOrder, id)
+		}
+		t.suites[id] = &checkSuite{app: lookup(doc, "payload.check_suite.app.name"), conclusion: conclusion}
+		if failedConclusions[conclusion] {
+			t.logf("check suite %s of %s %s", lookup(doc, "payload.check_suite.id"), lookup(doc, "payload.check_suite.app.name"), conclusion)
+			return true, true
+		}
+		return false, true
+	case "status":
+		if !shaMatches(lookup(doc, "payload.sha"), t.sha) {
+			return false, false
+		}
+		state := lookup(doc, "payload.state")
+		t.set(lookup(doc, "payload.context"), "status", state)
+		return state == "failure" || state == "error", true
+	}
+	return false, false
+}
+
+func (t *checksTracker) set(name, kind, result string) {
+	state, ok := t.checks[name]
+	if !ok {
+		state = &checkState{kind: kind}
+		t.checks[name] = state
+		t.order = append(t.order, name)
+	}
+	state.result = result
+	t.logf("%s %s: %s", kind, name, result)
+}
+
+// passed reports whether the expected checks and suites have all passed.
+func (t *checksTracker) passed() bool {
+	if len(t.expected) > 0 || len(t.expectedSuites) > 0 {
+		for _, name := range t.expected {
+			if state, ok := t.checks[name]; !ok || !checkPassed(state.result) {
+				return false
+			}
+		}
+		for _, app := range t.expectedSuites {
+			if !t.suitePassed(app) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(t.suites) == 0 {
+		return false
+	}
+	for _, suite := range t.suites {
+		if !passedConclusions[suite.conclusion] {
+			return false
+		}
+	}
+	for _, state := range t.checks {
+		if !checkPassed(state.result) {
+			return false
+		}
+	}
+	return true
+}
+
+// suitePassed reports whether app has reported a check suite and every one
+// it reported has passed.
+func (t *checksTracker) suitePassed(app string) bool {
+	seen := false
+	for _, suite := range t.suites {
+		if suite.app != app {
+			continue
+		}
+		if !passedConclusions[suite.conclusion] {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+func checkPassed(result string) bool {
+	return passedConclusions[result]
+}
+
+// summary prints a table of every check and check suite seen, and any
+// expected check or suite that never reported.
+func (t *checksTracker) summary(w io.Writer) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CHECK\tKIND\tRESULT")
+	for _, name := range t.order {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", name, t.checks[name].kind, t.checks[name].result)
+	}
+	for _, name := range t.expected {
+		if _, ok := t.checks[name]; !ok {
+			fmt.Fprintf(table, "%s\t-\tmissing\n", name)
+		}
+	}
+	reported := make(map[string]bool)
+	for _, id := range t.suiteOrder {
+		suite := t.suites[id]
+		result := suite.conclusion
+		if result == "" {
+			result = "pending"
+		}
+		fmt.Fprintf(table, "%s\tcheck_suite\t%s\n", suite.app, result)
+		reported[suite.app] = true
+	}
+	for _, app := range t.expectedSuites {
+		if !reported[app] {
+			fmt.Fprintf(table, "%s\tcheck_suite\tmissing\n", app)
+		}
+	}
+	_ = table.Flush()
+}
+
+func (t *checksTracker) logf(format string, args ...any) {
+	if t.logger != nil {
+		t.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/spf13/cobra"
+)
+
+// teamCheckTimeout bounds each team membership lookup.
+const teamCheckTimeout = 10 * time.Second
+
+// trustedAssociations are the author associations allowed to run commands
+// when neither --from-team nor --from-user is given.
+var trustedAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+func newWaitCommentCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var repo, match string
+	var teams, users []string
+	var follow bool
+
+	cmd := &cobra.Command{
+		Use:   "comment --url <smee-channel> --repo <owner/name> --match <regex>",
+		Short: "Wait for a ChatOps command in an issue or pull request comment",
+		Long: `Watch new issue_comment events on a repository for a command: a comment
+whose first line matches --match. The command is printed as a
+{"type":"command"} JSON line with its arguments (the rest of the line split
+on whitespace), who sent it, and where, and the wait exits 0. With --follow it
+keeps listening and prints every command until --timeout or Ctrl+C.
+
+Only authorized users can run commands: members of a --from-team (a team slug
+in the repository owner's organization, or org/slug; needs GITHUB_TOKEN with
+read:org), users named with --from-user, or, when neither is given, the
+repository's owners, organization members, and collaborators. Other matching
+comments are logged on stderr and ignored.`,
+		Example: `  gh-pulse wait comment --url "$SMEE_URL" --repo me/app --match '^/deploy(\s|$)' --from-team ops
+
+  # A simple bot loop
+  gh-pulse wait comment --url "$SMEE_URL" --repo me/app --match '^/(deploy|rollback)\b' --follow |
+    while read -r cmd; do ./handle.sh "$cmd"; done`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if match == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --match"))
+			}
+			if _, err := regexp.Compile(match); err != nil {
+				return usageErr(cmd, fmt.Errorf("invalid --match: %w", err))
+			}
+			if len(teams) > 0 && github.TokenFromEnv() == "" {
+				return usageErr(cmd, fmt.Errorf("--from-team requires GITHUB_TOKEN or GH_TOKEN to be set"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			owner, _, _ := strings.Cut(repo, "/")
+			tracker := &commentTracker{
+				repo:    repo,
+				pattern: regexp.MustCompile(match),
+				users:   users,
+				follow:  follow,
+				members: make(map[string]bool),
+				logger:  waitLogger(*quiet),
+			}
+			for _, team := range teams {
+				if !strings.Contains(team, "/") {
+					team = owner + "/" + team
+				}
+				tracker.teams = append(tracker.teams, team)
+			}
+			if len(teams) > 0 {
+				tracker.api = github.NewClient("", github.TokenFromEnv())
+			}
+			return runWait(opts, *quiet, waitRun{
+				events: []string{"issue_comment"},
+				stages: []client.Middleware{tracker.stage},
+			})
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().StringVar(&match, "match", "", "regex the first line of the comment must match (required)")
+	cmd.Flags().StringArrayVar(&teams, "from-team", nil, "only accept commands from members of this team, as slug or org/slug (can repeat)")
+	cmd.Flags().StringArrayVar(&users, "from-user", nil, "only accept commands from this user (can repeat)")
+	cmd.Flags().BoolVar(&follow, "follow", false, "keep listening and print every command instead of exiting on the first")
+	return cmd
+}
+
+// commandRecord is the JSON line printed for an accepted command.
+type commandRecord struct {
+	Type        string   `json:"type"`
+	Command     string   `json:"command"`
+	Args        []string `json:"args"`
+	User        string   `json:"user"`
+	Repo        string   `json:"repo"`
+	Issue       int      `json:"issue"`
+	PullRequest bool     `json:"pull_request"`
+	CommentID   int64    `json:"comment_id"`
+	URL         string   `json:"url"`
+	DeliveryID  string   `json:"delivery_id"`
+}
+
+// commentPayload is the part of an issue_comment event the tracker reads.
+type commentPayload struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int             `json:"number"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		ID                int64  `json:"id"`
+		Body              string `json:"body"`
+		HTMLURL           string `json:"html_url"`
+		AuthorAssociation string `json:"author_association"`
+		User              struct {
+			Login string `json:"login"`
+			Type  string `json:"type"`
+		} `json:"user"`
+	} `json:"comment"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// commentTracker turns authorized comments matching a pattern into commands.
+type commentTracker struct {
+	repo    string
+	pattern *regexp.Regexp
+	teams   []string
+	users   []string
+	follow  bool
+	api     *github.Client
+	// members caches team membership by "org/slug:login".
+	members map[string]bool
+	logger  *log.Logger
+}
+
+// stage replaces each command comment's output with its command record, and
+// ends the run after the first unless following.
+func (t *commentTracker) stage(next client.Handler) client.Handler {
+	return client.HandlerFunc(func(d *client.Delivery) error {
+		var p commentPayload
+		if err := json.Unmarshal(d.Message.Payload, &p); err != nil {
+			return nil
+		}
+		if p.Action != "created" || !strings.EqualFold(p.Repository.FullName, t.repo) {
+			return nil
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(p.Comment.Body), "\n")
+		line = strings.TrimSpace(line)
+		if !t.pattern.MatchString(line) {
+			return nil
+		}
+		user := p.Comment.User.Login
+		if p.Comment.User.Type == "Bot" {
+			t.logf("ignoring command from bot %s on #%d", user, p.Issue.Number)
+			return nil
+		}
+		if ok, why := t.authorized(user, p.Comment.AuthorAssociation); !ok {
+			t.logf("ignoring command from %s on #%d: %s", user, p.Issue.Number, why)
+			return nil
+		}
+		fields := strings.Fields(line)
+		record := commandRecord{
+			Type:        "command",
+			Command:     fields[0],
+			Args:        append([]string{}, fields[1:]...),
+			User:        user,
+			Repo:        t.repo,
+			Issue:       p.Issue.Number,
+			PullRequest: len(p.Issue.PullRequest) > 0 && string(p.Issue.PullRequest) != "null",
+			CommentID:   p.Comment.ID,
+			URL:         p.Comment.HTMLURL,
+			DeliveryID:  d.Message.DeliveryID,
+		}
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		t.logf("command %s from %s on #%d", record.Command, user, record.Issue)
+		d.SetOutput([][]byte{encoded})
+		if err := next.Handle(d); err != nil {
+			return err
+		}
+		if t.follow {
+			return nil
+		}
+		return client.Exit(0)
+	})
+}
+
+// authorized reports whether user may run commands, with the reason when not.
+func (t *commentTracker) authorized(user, association string) (bool, string) {
+	if len(t.teams) == 0 && len(t.users) == 0 {
+		if slices.Contains(trustedAssociations, association) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("author association %s is not owner, member, or collaborator", association)
+	}
+	if slices.ContainsFunc(t.users, func(u string) bool { return strings.EqualFold(u, user) }) {
+		return true, ""
+	}
+	for _, team := range t.teams {
+		member, err := t.isMember(team, user)
+		if err != nil {
+			t.logf("failed to check %s membership of %s: %v", user, team, err)
+			continue
+		}
+		if member {
+			return true, ""
+		}
+	}
+	return false, "not an allowed user or team member"
+}
+
+// isMember reports whether user is an active member of team (org/slug),
+// caching the answer for the rest of the run.
+func (t *commentTracker) isMember(team, user string) (bool, error) {
+	key := team + ":" + strings.ToLower(user)
+	if member, ok := t.members[key]; ok {
+		return member, nil
+	}
+	org, slug, _ := strings.Cut(team, "/")
+	ctx, cancel := context.WithTimeout(context.Background(), teamCheckTimeout)
+	defer cancel()
+	raw, err := t.api.Get(ctx, fmt.Sprintf("/orgs/%s/teams/%s/memberships/%s", org, slug, user))
+	var apiErr *github.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		t.members[key] = false
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var membership struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(raw, &membership); err != nil {
+		return false, err
+	}
+	t.members[key] = membership.State == "active"
+	return t.members[key], nil
+}
+
+func (t *commentTracker) logf(format string, args ...any) {
+	if t.logger != nil {
+		t.logger.Printf(format, args...)
+	}
+}
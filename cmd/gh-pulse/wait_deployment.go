@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// deploymentStates are the deployment_status states --state can wait for;
+// failure and error always end the wait with exit 1.
+var deploymentStates = map[string]bool{"success": true, "inactive": true, "in_progress": true, "queued": true, "pending": true}
+
+func newWaitDeploymentCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var environment, ref, state string
+
+	cmd := &cobra.Command{
+		Use:   "deployment --url <smee-channel> (--environment <name> | --ref <ref|sha>) [--state success]",
+		Short: "Wait for a deployment to reach a state",
+		Long: `Follow the deployment and deployment_status events for an environment, a ref,
+or both, and exit 0 when a deployment status reaches --state (success by
+default) or 1 when it reports failure or error. --ref matches the deployed
+branch or tag name, or the commit SHA, full or abbreviated.`,
+		Example: `  gh-pulse wait deployment --url "$SMEE_URL" --environment production --timeout 1800
+
+  # A specific commit reaching staging
+  gh-pulse wait deployment --url "$SMEE_URL" --environment staging --ref "$(git rev-parse HEAD)"`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if environment == "" && ref == "" {
+				return usageErr(cmd, fmt.Errorf("one of --environment or --ref is required"))
+			}
+			if !deploymentStates[state] {
+				return usageErr(cmd, fmt.Errorf("--state must be success, inactive, in_progress, queued, or pending, got %q", state))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tracker := &deploymentTracker{environment: environment, ref: ref, logger: waitLogger(*quiet)}
+			return runWait(opts, *quiet, waitRun{
+				events:  []string{"deployment", "deployment_status"},
+				success: []string{"payload.deployment_status.state=" + state},
+				failure: []string{"payload.deployment_status.state=~^(failure|error)$"},
+				stages:  []client.Middleware{keepDocs(tracker.keep)},
+			})
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&environment, "environment", "", "deployment environment (e.g. production)")
+	cmd.Flags().StringVar(&ref, "ref", "", "deployed branch, tag, or commit SHA")
+	cmd.Flags().StringVar(&state, "state", "success", "deployment status state to wait for")
+	return cmd
+}
+
+// deploymentTracker keeps the deployment events for an environment and ref,
+// logging their progress.
+type deploymentTracker struct {
+	environment string
+	ref         string
+	logger      *log.Logger
+}
+
+func (t *deploymentTracker) keep(doc assertion.Document) bool {
+	environment := lookup(doc, "payload.deployment.environment")
+	if t.environment != "" && environment != t.environment {
+		return false
+	}
+	if t.ref != "" && lookup(doc, "payload.deployment.ref") != t.ref && !shaMatches(lookup(doc, "payload.deployment.sha"), t.ref) {
+		return false
+	}
+	if t.logger != nil {
+		id := lookup(doc, "payload.deployment.id")
+		switch lookup(doc, "event") {
+		case "deployment":
+			t.logger.Printf("deployment %s of %s to %s created", id, lookup(doc, "payload.deployment.ref"), environment)
+		case "deployment_status":
+			t.logger.Printf("deployment %s to %s %s", id, environment, lookup(doc, "payload.deployment_status.state"))
+		}
+	}
+	return true
+}
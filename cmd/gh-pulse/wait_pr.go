@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newWaitPRMergedCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var repo string
+	var pr int
+
+	cmd := &cobra.Command{
+		Use:   "pr-merged --url <smee-channel> --repo <owner/name> --pr <number>",
+		Short: "Wait for a pull request to be merged",
+		Long: `Follow the pull_request events of one pull request and exit 0 when it is
+closed by a merge, or 1 when it is closed without one. Other activity on the
+pull request (pushes, reviews requested, labels) is logged on stderr but not
+printed.`,
+		Example: `  gh-pulse wait pr-merged --url "$SMEE_URL" --repo me/app --pr 123 --timeout 86400`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateRepo(repo); err != nil {
+				return usageErr(cmd, err)
+			}
+			if pr <= 0 {
+				return usageErr(cmd, fmt.Errorf("--pr must be a pull request number"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tracker := &prTracker{repo: repo, number: strconv.Itoa(pr), logger: waitLogger(*quiet)}
+			return runWait(opts, *quiet, waitRun{
+				events:  []string{"pull_request"},
+				success: []string{"payload.pull_request.merged=true"},
+				failure: []string{"payload.pull_request.merged=false"},
+				stages:  []client.Middleware{keepDocs(tracker.keep)},
+			})
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (required)")
+	cmd.Flags().IntVar(&pr, "pr", 0, "pull request number (required)")
+	return cmd
+}
+
+// prTracker keeps the closed event of one pull request, logging the rest of
+// its activity.
+type prTracker struct {
+	repo   string
+	number string
+	logger *log.Logger
+}
+
+func (t *prTracker) keep(doc assertion.Document) bool {
+	if !strings.EqualFold(lookup(doc, "payload.repository.full_name"), t.repo) || lookup(doc, "payload.pull_request.number") != t.number {
+		return false
+	}
+	action := lookup(doc, "payload.action")
+	if action != "closed" {
+		if t.logger != nil {
+			t.logger.Printf("pull request #%s %s", t.number, action)
+		}
+		return false
+	}
+	return true
+}
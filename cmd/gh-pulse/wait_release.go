@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newWaitReleaseCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var repo, tag string
+	var assets int
+
+	cmd := &cobra.Command{
+		Use:   "release --url <smee-channel> --tag <tag> [--assets <n>]",
+		Short: "Wait for a release to be published",
+		Long: `Follow the release events for a tag and exit 0 once the release is published,
+or 1 if it is deleted or unpublished first. With --assets, a published
+release must also list at least that many assets, all uploaded; later release
+events (edited, released) are checked again until it does.`,
+		Example: `  gh-pulse wait release --url "$SMEE_URL" --repo me/app --tag v1.2.3 --timeout 3600
+
+  # Block until the three build artifacts are attached
+  gh-pulse wait release --url "$SMEE_URL" --tag v1.2.3 --assets 3`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if tag == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --tag"))
+			}
+			if repo != "" {
+				if err := validateRepo(repo); err != nil {
+					return usageErr(cmd, err)
+				}
+			}
+			if assets < 0 {
+				return usageErr(cmd, fmt.Errorf("--assets must be >= 0"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tracker := &releaseTracker{repo: repo, tag: tag, assets: assets, logger: waitLogger(*quiet)}
+			return runWait(opts, *quiet, waitRun{
+				events: []string{"release"},
+				stages: []client.Middleware{tracker.stage},
+			})
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&repo, "repo", "", "repository as owner/name (default: any)")
+	cmd.Flags().StringVar(&tag, "tag", "", "release tag (required)")
+	cmd.Flags().IntVar(&assets, "assets", 0, "also wait for at least N uploaded release assets")
+	return cmd
+}
+
+// releasePayload is the part of a release event the tracker reads.
+type releasePayload struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
+		Assets  []struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"assets"`
+	} `json:"release"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// releaseTracker follows the release events for one tag.
+type releaseTracker struct {
+	repo      string
+	tag       string
+	assets    int
+	published bool
+	logger    *log.Logger
+}
+
+// stage keeps the release events for the tag, passes them on to be printed,
+// and then ends the run once the release is published with its assets.
+func (t *releaseTracker) stage(next client.Handler) client.Handler {
+	return client.HandlerFunc(func(d *client.Delivery) error {
+		var p releasePayload
+		if err := json.Unmarshal(d.Message.Payload, &p); err != nil {
+			return nil
+		}
+		if p.Release.TagName != t.tag || (t.repo != "" && !strings.EqualFold(p.Repository.FullName, t.repo)) {
+			return nil
+		}
+		if err := next.Handle(d); err != nil {
+			return err
+		}
+		switch p.Action {
+		case "deleted", "unpublished":
+			t.logf("release %s %s", t.tag, p.Action)
+			return client.Exit(1)
+		case "published":
+			t.published = true
+		}
+		if !t.published || p.Release.Draft {
+			t.logf("release %s %s", t.tag, p.Action)
+			return nil
+		}
+		uploaded := 0
+		for _, asset := range p.Release.Assets {
+			if asset.State == "uploaded" {
+				uploaded++
+			}
+		}
+		if uploaded < t.assets || uploaded < len(p.Release.Assets) {
+			t.logf("release %s %s: %d of %d assets uploaded", t.tag, p.Action, uploaded, max(t.assets, len(p.Release.Assets)))
+			return nil
+		}
+		t.logf("release %s published with %d assets", t.tag, uploaded)
+		return client.Exit(0)
+	})
+}
+
+func (t *releaseTracker) logf(format string, args ...any) {
+	if t.logger != nil {
+		t.logger.Printf(format, args...)
+	}
+}
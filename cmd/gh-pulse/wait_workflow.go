@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newWaitWorkflowCmd(quiet *bool) *cobra.Command {
+	var opts runOptions
+	var workflow, headSHA string
+
+	cmd := &cobra.Command{
+		Use:   "workflow --url <smee-channel> --workflow <file|name> --head-sha <sha>",
+		Short: "Wait for a workflow run on a commit to complete",
+		Long: `Follow the workflow_run events of one workflow for a commit from requested
+through completed, and exit 0 if the run concluded with success (or neutral
+or skipped) and 1 otherwise. --workflow is the workflow file (ci.yml) or its
+name. The webhook needs the workflow_run event, and workflow_job for the
+per-job progress printed on stderr.
+
+The first run of the workflow to complete for the commit decides the exit.`,
+		Example: `  gh-pulse wait workflow --url "$SMEE_URL" --workflow ci.yml --head-sha "$(git rev-parse HEAD)" --timeout 1800`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if workflow == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --workflow"))
+			}
+			if len(headSHA) < 7 {
+				return usageErr(cmd, fmt.Errorf("--head-sha must be at least 7 characters"))
+			}
+			return usageErr(cmd, opts.validate())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tracker := &workflowTracker{workflow: workflow, sha: headSHA, runs: make(map[string]bool), logger: waitLogger(*quiet)}
+			return runWait(opts, *quiet, waitRun{
+				events:  []string{"workflow_run", "workflow_job"},
+				success: []string{"payload.workflow_run.conclusion=~^(success|neutral|skipped)$"},
+				failure: []string{"payload.workflow_run.conclusion=~^(failure|cancelled|timed_out|action_required|startup_failure|stale)$"},
+				stages:  []client.Middleware{keepDocs(tracker.keep)},
+			})
+		},
+	}
+	addWaitFlags(cmd, &opts)
+	cmd.Flags().StringVar(&workflow, "workflow", "", "workflow file (e.g. ci.yml) or name (required)")
+	cmd.Flags().StringVar(&headSHA, "head-sha", "", "commit SHA the run is for, full or abbreviated (required)")
+	return cmd
+}
+
+// workflowTracker keeps the workflow_run and workflow_job events of one
+// workflow on one commit, logging their progress.
+type workflowTracker struct {
+	workflow string
+	sha      string
+	// runs holds the IDs of the matching workflow runs, to pick out their
+	// jobs.
+	runs   map[string]bool
+	logger *log.Logger
+}
+
+func (t *workflowTracker) keep(doc assertion.Document) bool {
+	switch lookup(doc, "event") {
+	case "workflow_run":
+		if !shaMatches(lookup(doc, "payload.workflow_run.head_sha"), t.sha) {
+			return false
+		}
+		name := lookup(doc, "payload.workflow_run.name")
+		if path.Base(lookup(doc, "payload.workflow_run.path")) != t.workflow && name != t.workflow {
+			return false
+		}
+		id := lookup(doc, "payload.workflow_run.id")
+		t.runs[id] = true
+		t.logf("workflow %s run %s %s", name, id, progress(lookup(doc, "payload.workflow_run.status"), lookup(doc, "payload.workflow_run.conclusion")))
+		return true
+	case "workflow_job":
+		if !shaMatches(lookup(doc, "payload.workflow_job.head_sha"), t.sha) {
+			return false
+		}
+		if !t.runs[lookup(doc, "payload.workflow_job.run_id")] && lookup(doc, "payload.workflow_job.workflow_name") != t.workflow {
+			return false
+		}
+		t.logf("job %s %s", lookup(doc, "payload.workflow_job.name"), progress(lookup(doc, "payload.workflow_job.status"), lookup(doc, "payload.workflow_job.conclusion")))
+		return true
+	}
+	return false
+}
+
+func (t *workflowTracker) logf(format string, args ...any) {
+	if t.logger != nil {
+		t.logger.Printf(format, args...)
+	}
+}
+
+// progress describes a run or job status, with its conclusion once it has
+// completed.
+func progress(status, conclusion string) string {
+	if status == "completed" && conclusion != "" {
+		return fmt.Sprintf("completed: %s", conclusion)
+	}
+	return status
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+func newWatchCmd() *cobra.Command {
+	var url string
+	var events []string
+	var actions []string
+
+	cmd := &cobra.Command{
+		Use:   "watch --url <smee-channel>",
+		Short: "Browse GitHub webhooks interactively in a terminal UI",
+		Long: `Connect to a smee.io channel and browse events in an interactive terminal UI.
+
+The top pane lists events with their type, action, and repository; the bottom
+pane shows the selected event's payload. Keys:
+
+  j/k, up/down   move selection        ctrl+d/ctrl+u  scroll payload
+  g/G            first/last event      p, space       pause/resume
+  /              incremental search    esc            clear search
+  q, ctrl+c      quit
+
+Use stream or capture for scripting; watch does not write JSONL.`,
+		Example: `  gh-pulse watch --url https://smee.io/my-channel --event pull_request`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				return usageErr(cmd, fmt.Errorf("missing required flag: --url"))
+			}
+			return usageErr(cmd, validateEvents(events))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := client.Config{URL: url, Events: events, Actions: actions}
+			if err := client.ValidateURL(cfg.URL); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			program := tea.NewProgram(tui.NewModel(url), tea.WithAltScreen())
+			logger := log.New(statusWriter{program: program}, "", 0)
+			go func() {
+				_ = client.Watch(ctx, cfg, logger, func(msg message.EventMessage) {
+					program.Send(tui.EventMsg(msg))
+				})
+			}()
+			_, err := program.Run()
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&url, "url", "", "smee.io channel URL (required)")
+	cmd.Flags().StringArrayVar(&events, "event", nil, "filter by GitHub event type (can repeat)")
+	cmd.Flags().StringArrayVar(&actions, "action", nil, "filter by payload action (can repeat)")
+	return cmd
+}
+
+// statusWriter routes connection log lines into the UI's status bar.
+type statusWriter struct {
+	program *tea.Program
+}
+
+func (w statusWriter) Write(p []byte) (int, error) {
+	w.program.Send(tui.StatusMsg(strings.TrimSpace(string(p))))
+	return len(p), nil
+}
@@ -0,0 +1,77 @@
+package assertion
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Deadline requires that an event matching End follows each event matching
+// Start within Within. With By, start and end events are paired by the value
+// at that path, e.g. payload.workflow_run.id, so each run has its own timer.
+type Deadline struct {
+	Start  Assertion
+	End    Assertion
+	Within time.Duration
+	By     string
+}
+
+// ParseDeadline parses "START => END within DURATION [by PATH]", e.g.
+//
+//	payload.action=requested => payload.action=completed within 90s by payload.workflow_run.id
+func ParseDeadline(input string) (Deadline, error) {
+	trimmed := strings.TrimSpace(input)
+	idx := strings.LastIndex(trimmed, " within ")
+	if idx < 0 {
+		return Deadline{}, fmt.Errorf("expected 'START => END within DURATION [by PATH]'")
+	}
+	conditions, limit := trimmed[:idx], strings.TrimSpace(trimmed[idx+len(" within "):])
+
+	var deadline Deadline
+	durationText, by, hasBy := strings.Cut(limit, " by ")
+	if hasBy {
+		by = strings.TrimSpace(by)
+		if _, err := validatePath(by); err != nil {
+			return Deadline{}, fmt.Errorf("invalid by path: %w", err)
+		}
+		deadline.By = by
+	}
+	within, err := time.ParseDuration(strings.TrimSpace(durationText))
+	if err != nil || within <= 0 {
+		return Deadline{}, fmt.Errorf("invalid duration %q after 'within' (e.g., 90s, 5m)", strings.TrimSpace(durationText))
+	}
+	deadline.Within = within
+
+	start, end, ok := strings.Cut(conditions, " => ")
+	if !ok {
+		return Deadline{}, fmt.Errorf("expected 'START => END' before 'within'")
+	}
+	if deadline.Start, err = ParseAssertion(start, 0); err != nil {
+		return Deadline{}, fmt.Errorf("start: %w", err)
+	}
+	if deadline.End, err = ParseAssertion(end, 0); err != nil {
+		return Deadline{}, fmt.Errorf("end: %w", err)
+	}
+	return deadline, nil
+}
+
+// ParseDeadlines parses each input with ParseDeadline.
+func ParseDeadlines(inputs []string) ([]Deadline, error) {
+	deadlines := make([]Deadline, 0, len(inputs))
+	for _, input := range inputs {
+		deadline, err := ParseDeadline(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deadline %q: %w", input, err)
+		}
+		deadlines = append(deadlines, deadline)
+	}
+	return deadlines, nil
+}
+
+func (d Deadline) String() string {
+	s := fmt.Sprintf("%s => %s within %s", d.Start, d.End, d.Within)
+	if d.By != "" {
+		s += " by " + d.By
+	}
+	return s
+}
@@ -0,0 +1,371 @@
+package assertion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a parsed --until expression. It combines assertions with &&, ||,
+// !, and parentheses, plus two functions:
+//
+//	count(A) >= N        at least N events have matched A (also >, <, <=, ==, !=)
+//	within(D, EXPR)      EXPR holds counting only events from the last D
+//
+// A bare assertion holds once any event has matched it, so
+// "event=push && event=check_suite" holds after both have arrived in either
+// order. Assertions containing &&, ||, commas, or unbalanced parentheses can
+// be written as JSON strings: "\"payload.title=~a||b\"".
+//
+// An Expr records every event passed to Observe and is not safe for
+// concurrent use.
+type Expr struct {
+	root  exprNode
+	atoms []*exprAtom
+}
+
+type exprNode interface {
+	eval(now, since time.Time) bool
+}
+
+// exprAtom is an assertion with the receive times of the events that
+// matched it. Times are only kept while some within() can still see them.
+type exprAtom struct {
+	assertion Assertion
+	count     int
+	times     []time.Time
+	window    time.Duration
+	windowed  bool
+}
+
+func (a *exprAtom) matches(since time.Time) int {
+	if since.IsZero() {
+		return a.count
+	}
+	n := 0
+	for i := len(a.times) - 1; i >= 0 && !a.times[i].Before(since); i-- {
+		n++
+	}
+	return n
+}
+
+type seenNode struct{ atom *exprAtom }
+
+func (n seenNode) eval(now, since time.Time) bool { return n.atom.matches(since) > 0 }
+
+type countNode struct {
+	atom *exprAtom
+	op   string
+	n    int
+}
+
+func (n countNode) eval(now, since time.Time) bool {
+	got := n.atom.matches(since)
+	switch n.op {
+	case ">=":
+		return got >= n.n
+	case ">":
+		return got > n.n
+	case "<=":
+		return got <= n.n
+	case "<":
+		return got < n.n
+	case "!=":
+		return got != n.n
+	default:
+		return got == n.n
+	}
+}
+
+type withinNode struct {
+	window time.Duration
+	expr   exprNode
+}
+
+func (n withinNode) eval(now, since time.Time) bool {
+	cutoff := now.Add(-n.window)
+	if cutoff.Before(since) {
+		cutoff = since
+	}
+	return n.expr.eval(now, cutoff)
+}
+
+type notNode struct{ expr exprNode }
+
+func (n notNode) eval(now, since time.Time) bool { return !n.expr.eval(now, since) }
+
+type andNode struct{ left, right exprNode }
+
+func (n andNode) eval(now, since time.Time) bool {
+	return n.left.eval(now, since) && n.right.eval(now, since)
+}
+
+type orNode struct{ left, right exprNode }
+
+func (n orNode) eval(now, since time.Time) bool {
+	return n.left.eval(now, since) || n.right.eval(now, since)
+}
+
+// Observe records an event received at the given time and reports whether
+// the expression now holds.
+func (e *Expr) Observe(doc Document, at time.Time) bool {
+	for _, atom := range e.atoms {
+		if !doc.MatchAny([]Assertion{atom.assertion}) {
+			continue
+		}
+		atom.count++
+		if atom.windowed {
+			atom.times = append(atom.times, at)
+		}
+	}
+	for _, atom := range e.atoms {
+		if !atom.windowed {
+			continue
+		}
+		cutoff := at.Add(-atom.window)
+		drop := 0
+		for drop < len(atom.times) && atom.times[drop].Before(cutoff) {
+			drop++
+		}
+		atom.times = atom.times[drop:]
+	}
+	return e.root.eval(at, time.Time{})
+}
+
+// ParseExpr parses an --until expression.
+func ParseExpr(input string) (*Expr, error) {
+	p := &exprParser{input: input}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.input[p.pos:], p.pos)
+	}
+	return &Expr{root: root, atoms: p.atoms}, nil
+}
+
+// ParseExprs parses each input with ParseExpr.
+func ParseExprs(inputs []string) ([]*Expr, error) {
+	exprs := make([]*Expr, 0, len(inputs))
+	for _, input := range inputs {
+		expr, err := ParseExpr(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression %q: %w", input, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+	atoms []*exprAtom
+	// windows holds the durations of the within() calls being parsed.
+	windows []time.Duration
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.consume("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	p.skipSpace()
+	switch {
+	case p.pos >= len(p.input):
+		return nil, fmt.Errorf("unexpected end of expression")
+	case p.consume("("):
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		return expr, nil
+	case p.consume("count("):
+		return p.parseCount()
+	case p.consume("within("):
+		return p.parseWithin()
+	default:
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		return seenNode{atom: atom}, nil
+	}
+}
+
+func (p *exprParser) parseCount() (exprNode, error) {
+	atom, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(")") {
+		return nil, fmt.Errorf("missing ) after count( at offset %d", p.pos)
+	}
+	p.skipSpace()
+	op := ""
+	for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("count() must be compared with a number, e.g. count(event=push) >= 3")
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.input[start:p.pos])
+	if err != nil {
+		return nil, fmt.Errorf("count() must be compared with a number at offset %d", start)
+	}
+	return countNode{atom: atom, op: op, n: n}, nil
+}
+
+func (p *exprParser) parseWithin() (exprNode, error) {
+	p.skipSpace()
+	end := strings.IndexByte(p.input[p.pos:], ',')
+	if end < 0 {
+		return nil, fmt.Errorf("within() takes a duration and an expression, e.g. within(5m, event=push)")
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(p.input[p.pos : p.pos+end]))
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid within() duration %q", strings.TrimSpace(p.input[p.pos:p.pos+end]))
+	}
+	p.pos += end + 1
+	p.windows = append(p.windows, window)
+	expr, err := p.parseOr()
+	p.windows = p.windows[:len(p.windows)-1]
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(")") {
+		return nil, fmt.Errorf("missing ) after within( at offset %d", p.pos)
+	}
+	return withinNode{window: window, expr: expr}, nil
+}
+
+// parseAtom reads an assertion, either as a JSON string or as bare text up
+// to &&, ||, a comma, or a closing parenthesis it did not open.
+func (p *exprParser) parseAtom() (*exprAtom, error) {
+	p.skipSpace()
+	var text string
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		end := p.pos + 1
+		for end < len(p.input) && p.input[end] != '"' {
+			if p.input[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(p.input) {
+			return nil, fmt.Errorf("unterminated string at offset %d", p.pos)
+		}
+		if err := json.Unmarshal([]byte(p.input[p.pos:end+1]), &text); err != nil {
+			return nil, fmt.Errorf("invalid string at offset %d: %w", p.pos, err)
+		}
+		p.pos = end + 1
+	} else {
+		start, depth := p.pos, 0
+	scan:
+		for p.pos < len(p.input) {
+			switch c := p.input[p.pos]; {
+			case c == '[':
+				if end := bracketEnd(p.input, p.pos); end >= 0 {
+					p.pos = end
+				}
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth == 0 {
+					break scan
+				}
+				depth--
+			case c == ',' && depth == 0:
+				break scan
+			case strings.HasPrefix(p.input[p.pos:], "&&"), strings.HasPrefix(p.input[p.pos:], "||"):
+				break scan
+			}
+			p.pos++
+		}
+		text = strings.TrimSpace(p.input[start:p.pos])
+		if text == "" {
+			return nil, fmt.Errorf("expected an assertion at offset %d", start)
+		}
+	}
+
+	parsed, err := ParseAssertion(text, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", text, err)
+	}
+	atom := &exprAtom{assertion: parsed}
+	for _, window := range p.windows {
+		if !atom.windowed || window > atom.window {
+			atom.window = window
+		}
+		atom.windowed = true
+	}
+	p.atoms = append(p.atoms, atom)
+	return atom, nil
+}
@@ -0,0 +1,34 @@
+package assertion
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ignoreFile is the YAML layout accepted by --ignore-file:
+//
+//	ignore:
+//	  - event=ping
+//	  - payload.sender.login=~\[bot\]$
+type ignoreFile struct {
+	Ignore []string `yaml:"ignore"`
+}
+
+// LoadIgnoreFile reads assertion-style patterns from a YAML ignore file.
+func LoadIgnoreFile(path string) ([]Assertion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	var file ignoreFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid ignore file %s: %w", path, err)
+	}
+	assertions, err := ParseAssertions(file.Ignore, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore file %s: %w", path, err)
+	}
+	return assertions, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -15,15 +16,25 @@ func (a *Assertion) Match(data []byte) (bool, error) {
 	if a == nil {
 		return false, fmt.Errorf("assertion is nil")
 	}
-
-	var payload interface{}
-	decoder := json.NewDecoder(bytes.NewReader(data))
-	decoder.UseNumber()
-	if err := decoder.Decode(&payload); err != nil {
+	doc, err := Decode(data)
+	if err != nil {
 		return false, err
 	}
+	return a.match(doc.value)
+}
 
-	value, ok := valueAtPath(payload, a.Path)
+// match evaluates the assertion against a decoded document. Scalars compare
+// as their literal JSON text (numbers keep their original form) and objects
+// or arrays as compact JSON.
+func (a *Assertion) match(doc interface{}) (bool, error) {
+	keys := a.keys
+	if keys == nil {
+		var err error
+		if keys, err = SplitPath(a.Path); err != nil {
+			return false, err
+		}
+	}
+	value, ok := valueAtKeys(doc, keys)
 	switch a.Operator {
 	case "exists":
 		return ok, nil
@@ -31,54 +42,224 @@ func (a *Assertion) Match(data []byte) (bool, error) {
 		if !ok {
 			return false, nil
 		}
-		str, ok := stringifyScalar(value)
+		return stringify(value) == a.Value, nil
+	case "regex":
 		if !ok {
 			return false, nil
 		}
-		return str == a.Value, nil
-	case "regex":
+		re, err := a.regexp()
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(stringify(value)), nil
+	case "any_eq", "all_eq", "any_regex", "all_regex":
 		if !ok {
 			return false, nil
 		}
-		str, ok := stringifyScalar(value)
+		matches := func(s string) bool { return s == a.Value }
+		if strings.HasSuffix(a.Operator, "_regex") {
+			re, err := a.regexp()
+			if err != nil {
+				return false, err
+			}
+			matches = re.MatchString
+		}
+		items := elements(value)
+		all := strings.HasPrefix(a.Operator, "all_")
+		if all && len(items) == 0 {
+			// An empty list satisfies no all= gate, so missing data can't pass.
+			return false, nil
+		}
+		for _, item := range items {
+			if matches(stringify(item)) != all {
+				return !all, nil
+			}
+		}
+		return all, nil
+	case "length_eq", "length_gt", "length_ge", "length_lt", "length_le":
 		if !ok {
 			return false, nil
 		}
-		re, err := regexp.Compile(a.Value)
+		n, isList := length(value)
+		if !isList {
+			return false, nil
+		}
+		want, err := strconv.Atoi(a.Value)
 		if err != nil {
 			return false, err
 		}
-		return re.MatchString(str), nil
+		switch a.Operator {
+		case "length_eq":
+			return n == want, nil
+		case "length_gt":
+			return n > want, nil
+		case "length_ge":
+			return n >= want, nil
+		case "length_lt":
+			return n < want, nil
+		default:
+			return n <= want, nil
+		}
 	default:
 		return false, fmt.Errorf("unknown operator %q", a.Operator)
 	}
 }
 
-func valueAtPath(payload interface{}, path string) (interface{}, bool) {
-	if path == "" {
-		return nil, false
+// regexp returns the compiled regex Value.
+func (a *Assertion) regexp() (*regexp.Regexp, error) {
+	if a.re != nil {
+		return a.re, nil
 	}
+	// Built without ParseAssertion, e.g. as a struct literal.
+	return regexp.Compile(a.Value)
+}
 
-	current := payload
-	parts := strings.Split(path, ".")
-	for _, part := range parts {
-		if part == "" {
-			return nil, false
+// elements returns the items of an array, or the value itself otherwise.
+func elements(value interface{}) []interface{} {
+	if items, ok := value.([]interface{}); ok {
+		return items
+	}
+	return []interface{}{value}
+}
+
+// length returns the number of items in an array or keys in an object.
+func length(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Document is a decoded JSON message that any number of assertions can be
+// evaluated against without decoding it again.
+type Document struct {
+	value interface{}
+}
+
+// Decode parses a JSON message, keeping numbers in their original form.
+func Decode(data []byte) (Document, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return Document{}, err
+	}
+	return Document{value: value}, nil
+}
+
+// MatchAny reports whether any of the assertions matches the document.
+// Assertions that fail to evaluate count as not matching.
+func (d Document) MatchAny(assertions []Assertion) bool {
+	for i := range assertions {
+		if ok, err := assertions[i].match(d.value); err == nil && ok {
+			return true
 		}
-		node, ok := current.(map[string]interface{})
-		if !ok {
-			return nil, false
+	}
+	return false
+}
+
+// Lookup resolves path in the document. Scalars are returned as their
+// literal text and objects or arrays as compact JSON.
+func (d Document) Lookup(path string) (string, bool) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return "", false
+	}
+	value, ok := valueAtKeys(d.value, keys)
+	if !ok {
+		return "", false
+	}
+	return stringify(value), true
+}
+
+// MatchAny reports whether any of the assertions matches the JSON message.
+func MatchAny(data []byte, assertions []Assertion) bool {
+	if len(assertions) == 0 {
+		return false
+	}
+	doc, err := Decode(data)
+	if err != nil {
+		return false
+	}
+	return doc.MatchAny(assertions)
+}
+
+// Lookup resolves path in a JSON document. Scalars are returned as their
+// literal text and objects or arrays as compact JSON.
+func Lookup(data []byte, path string) (string, bool) {
+	doc, err := Decode(data)
+	if err != nil {
+		return "", false
+	}
+	return doc.Lookup(path)
+}
+
+// valueAtKeys walks the keys of a split path through objects, treating
+// numeric keys as indexes into arrays (payload.commits.0.id). A [] key maps
+// the rest of the path over an array and collects the values found, so
+// payload.commits[].id is the list of commit IDs.
+func valueAtKeys(doc interface{}, keys []string) (interface{}, bool) {
+	current := doc
+	for i, part := range keys {
+		if part == projection {
+			items, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			rest := keys[i+1:]
+			nested := slices.Contains(rest, projection)
+			collected := []interface{}{}
+			for _, item := range items {
+				value, ok := valueAtKeys(item, rest)
+				if !ok {
+					continue
+				}
+				if inner, isList := value.([]interface{}); nested && isList {
+					collected = append(collected, inner...)
+					continue
+				}
+				collected = append(collected, value)
+			}
+			return collected, true
 		}
-		child, ok := node[part]
-		if !ok {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			child, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = child
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
 			return nil, false
 		}
-		current = child
 	}
 
 	return current, true
 }
 
+// stringify renders a value for comparison: scalars as their literal text,
+// objects and arrays as compact JSON.
+func stringify(value interface{}) string {
+	if str, ok := stringifyScalar(value); ok {
+		return str
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
 func stringifyScalar(value interface{}) (string, bool) {
 	switch v := value.(type) {
 	case string:
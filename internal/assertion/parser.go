@@ -2,74 +2,231 @@ package assertion
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+// envelopeFields are the top-level fields of the JSONL envelope
+// (message.EventMessage). Assertion paths start at one of them: event=push
+// matches the envelope, payload.ref=refs/heads/main the webhook body.
+var envelopeFields = map[string]bool{
+	"type":        true,
+	"event":       true,
+	"delivery_id": true,
+	"truncated":   true,
+	"payload":     true,
+	"received_at": true,
+	"verified":    true,
+	"enrichment":  true,
+	"provider":    true,
+}
+
 type Assertion struct {
 	Path     string
 	Operator string
 	Value    string
 	ExitCode int
+	// keys and re are Path and the regex Value as parsed by ParseAssertion.
+	keys []string
+	re   *regexp.Regexp
+}
+
+// Operators that compare the length of an array, keyed by their syntax in
+// path#length<op>n.
+var lengthOperators = []struct {
+	syntax   string
+	operator string
+}{
+	{">=", "length_ge"},
+	{"<=", "length_le"},
+	{">", "length_gt"},
+	{"<", "length_lt"},
+	{"=", "length_eq"},
 }
 
 func ParseAssertion(input string, exitCode int) (Assertion, error) {
+	return parseAssertion(input, exitCode, nil)
+}
+
+// parseAssertion parses an assertion whose path starts at one of fields, or
+// at an envelope field when fields is nil.
+func parseAssertion(input string, exitCode int, fields []string) (Assertion, error) {
 	trimmed := strings.TrimSpace(input)
 	if trimmed == "" {
 		return Assertion{}, fmt.Errorf("assertion cannot be empty")
 	}
 
-	if strings.Contains(trimmed, "=") {
-		idx := strings.IndexRune(trimmed, '=')
-		if idx == 0 {
-			return Assertion{}, fmt.Errorf("missing path before '='")
+	if path, rest, ok := CutPath(trimmed, '#'); ok {
+		// A '#' after '=' belongs to the value, as in payload.title=Fix #12.
+		if _, _, hasValue := CutPath(path, '='); !hasValue {
+			return parseLength(strings.TrimSpace(path), strings.TrimSpace(rest), exitCode, fields)
+		}
+	}
+
+	if path, value, ok := CutPath(trimmed, '='); ok {
+		path = strings.TrimSpace(path)
+		value = strings.TrimSpace(value)
+		quantifier := ""
+		for _, q := range []string{"any", "all"} {
+			if head, ok := strings.CutSuffix(path, " "+q); ok {
+				path, quantifier = strings.TrimSpace(head), q
+				break
+			}
 		}
-		path := strings.TrimSpace(trimmed[:idx])
-		value := strings.TrimSpace(trimmed[idx+1:])
 		if path == "" {
 			return Assertion{}, fmt.Errorf("missing path before '='")
 		}
 		if value == "" {
 			return Assertion{}, fmt.Errorf("missing value after '='")
 		}
+		keys, err := validatePathIn(path, fields)
+		if err != nil {
+			return Assertion{}, err
+		}
 		if strings.HasPrefix(value, "~") {
 			pattern := strings.TrimSpace(value[1:])
 			if pattern == "" {
 				return Assertion{}, fmt.Errorf("missing regex pattern after '=~'")
 			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return Assertion{}, fmt.Errorf("invalid regex: %w", err)
+			}
+			operator := "regex"
+			if quantifier != "" {
+				operator = quantifier + "_regex"
+			}
 			return Assertion{
 				Path:     path,
-				Operator: "regex",
+				Operator: operator,
 				Value:    pattern,
 				ExitCode: exitCode,
+				keys:     keys,
+				re:       re,
 			}, nil
 		}
+		operator := "eq"
+		if quantifier != "" {
+			operator = quantifier + "_eq"
+		}
 		return Assertion{
 			Path:     path,
-			Operator: "eq",
+			Operator: operator,
 			Value:    value,
 			ExitCode: exitCode,
+			keys:     keys,
 		}, nil
 	}
 
-	fields := strings.Fields(trimmed)
-	if len(fields) != 2 || fields[1] != "exists" {
-		return Assertion{}, fmt.Errorf("expected 'path=value', 'path=~regex', or 'path exists'")
+	path, ok := strings.CutSuffix(trimmed, " exists")
+	if !ok {
+		return Assertion{}, fmt.Errorf("expected 'path=value', 'path=~regex', 'path exists', 'path any=value', 'path all=value', or 'path#length>=n'")
 	}
-	if fields[0] == "" {
+	path = strings.TrimSpace(path)
+	if path == "" {
 		return Assertion{}, fmt.Errorf("missing path before 'exists'")
 	}
+	keys, err := validatePathIn(path, fields)
+	if err != nil {
+		return Assertion{}, err
+	}
 	return Assertion{
-		Path:     fields[0],
+		Path:     path,
 		Operator: "exists",
 		Value:    "",
 		ExitCode: exitCode,
+		keys:     keys,
 	}, nil
 }
 
+// parseLength parses the part after '#' in path#length>=n.
+func parseLength(path, rest string, exitCode int, fields []string) (Assertion, error) {
+	comparison, ok := strings.CutPrefix(rest, "length")
+	if !ok {
+		return Assertion{}, fmt.Errorf("unknown function #%s (expected #length)", rest)
+	}
+	comparison = strings.TrimSpace(comparison)
+	for _, op := range lengthOperators {
+		value, ok := strings.CutPrefix(comparison, op.syntax)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if _, err := strconv.Atoi(value); err != nil {
+			return Assertion{}, fmt.Errorf("#length%s needs an integer, got %q", op.syntax, value)
+		}
+		if path == "" {
+			return Assertion{}, fmt.Errorf("missing path before '#length'")
+		}
+		keys, err := validatePathIn(path, fields)
+		if err != nil {
+			return Assertion{}, err
+		}
+		return Assertion{
+			Path:     path,
+			Operator: op.operator,
+			Value:    value,
+			ExitCode: exitCode,
+			keys:     keys,
+		}, nil
+	}
+	return Assertion{}, fmt.Errorf("expected a comparison after #length (=, >, >=, <, <=)")
+}
+
+// ValidatePath checks that path is well formed and rooted at an envelope
+// field, for flags that name a path outside an assertion.
+func ValidatePath(path string) error {
+	_, err := validatePath(path)
+	return err
+}
+
+// validatePath splits path and checks that it is rooted at an envelope field,
+// so a body field written without its payload. prefix is reported instead of
+// never matching.
+func validatePath(path string) ([]string, error) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !envelopeFields[keys[0]] {
+		return nil, fmt.Errorf("unknown path root %q: paths start with an envelope field (event, delivery_id, payload, ...); use payload.%s for webhook body fields", keys[0], path)
+	}
+	return keys, nil
+}
+
+// validatePathIn is validatePath for a record with the given fields, or the
+// envelope when fields is nil.
+func validatePathIn(path string, fields []string) ([]string, error) {
+	if fields == nil {
+		return validatePath(path)
+	}
+	keys, err := SplitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(fields, keys[0]) {
+		return nil, fmt.Errorf("unknown path root %q: paths start with one of %s", keys[0], strings.Join(fields, ", "))
+	}
+	return keys, nil
+}
+
 func ParseAssertions(inputs []string, exitCode int) ([]Assertion, error) {
+	return parseAssertions(inputs, exitCode, nil)
+}
+
+// ParseRecordAssertions parses assertions over a record other than the
+// envelope, such as a forwarded delivery's response, whose paths start at
+// one of fields.
+func ParseRecordAssertions(inputs []string, exitCode int, fields []string) ([]Assertion, error) {
+	return parseAssertions(inputs, exitCode, fields)
+}
+
+func parseAssertions(inputs []string, exitCode int, fields []string) ([]Assertion, error) {
 	assertions := make([]Assertion, 0, len(inputs))
 	for _, input := range inputs {
-		assertion, err := ParseAssertion(input, exitCode)
+		assertion, err := parseAssertion(input, exitCode, fields)
 		if err != nil {
 			return nil, fmt.Errorf("invalid assertion %q: %w", input, err)
 		}
@@ -77,3 +234,23 @@ func ParseAssertions(inputs []string, exitCode int) ([]Assertion, error) {
 	}
 	return assertions, nil
 }
+
+// String renders the assertion in the syntax ParseAssertion accepts.
+func (a Assertion) String() string {
+	switch a.Operator {
+	case "exists":
+		return a.Path + " exists"
+	case "regex":
+		return a.Path + "=~" + a.Value
+	case "any_eq", "all_eq":
+		return a.Path + " " + strings.TrimSuffix(a.Operator, "_eq") + "=" + a.Value
+	case "any_regex", "all_regex":
+		return a.Path + " " + strings.TrimSuffix(a.Operator, "_regex") + "=~" + a.Value
+	}
+	for _, op := range lengthOperators {
+		if a.Operator == op.operator {
+			return a.Path + "#length" + op.syntax + a.Value
+		}
+	}
+	return a.Path + "=" + a.Value
+}
@@ -0,0 +1,122 @@
+package assertion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// projection is the key SplitPath returns for [], which maps the rest of the
+// path over an array; the NUL byte keeps it apart from real keys.
+const projection = "\x00[]"
+
+// IsProjection reports whether a key returned by SplitPath is [].
+func IsProjection(key string) bool {
+	return key == projection
+}
+
+// SplitPath splits a path into its keys. Keys are separated by dots; a key
+// containing dots or other special characters is written as a quoted string
+// in brackets, and an array index may be written either way:
+//
+//	payload.head_commit.modified.0
+//	payload.files["src/main.go"].status
+//	payload.commits[0].id
+//	payload.pull_request.labels[].name
+func SplitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	var parts []string
+	i := 0
+	for i < len(path) {
+		switch {
+		case path[i] == '[':
+			end := bracketEnd(path, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in path %q", path)
+			}
+			inner := path[i+1 : end]
+			switch {
+			case strings.HasPrefix(inner, `"`):
+				var key string
+				if err := json.Unmarshal([]byte(inner), &key); err != nil {
+					return nil, fmt.Errorf("invalid quoted key %s in path %q", inner, path)
+				}
+				parts = append(parts, key)
+			case inner == "":
+				parts = append(parts, projection)
+			case isIndex(inner):
+				parts = append(parts, inner)
+			default:
+				return nil, fmt.Errorf(`invalid [%s] in path %q (expected ["key"], [index], or [])`, inner, path)
+			}
+			i = end + 1
+			if i < len(path) && path[i] != '.' && path[i] != '[' {
+				return nil, fmt.Errorf("expected . or [ after ] in path %q", path)
+			}
+			if i < len(path) && path[i] == '.' {
+				i++
+				if i == len(path) {
+					return nil, fmt.Errorf("path %q ends with .", path)
+				}
+			}
+		default:
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("empty key in path %q", path)
+			}
+			parts = append(parts, path[i:end])
+			i = end
+			if i < len(path) && path[i] == '.' {
+				i++
+				if i == len(path) {
+					return nil, fmt.Errorf("path %q ends with .", path)
+				}
+			}
+		}
+	}
+	return parts, nil
+}
+
+// CutPath splits input around the first sep that is not inside a bracketed
+// key, so payload.files["a=b"]=x cuts after the closing bracket.
+func CutPath(input string, sep byte) (path, rest string, found bool) {
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case '[':
+			if end := bracketEnd(input, i); end >= 0 {
+				i = end
+			}
+		case sep:
+			return input[:i], input[i+1:], true
+		}
+	}
+	return input, "", false
+}
+
+// bracketEnd returns the index of the ] closing the bracket at start,
+// skipping over a quoted key, or -1.
+func bracketEnd(s string, start int) int {
+	inQuote := false
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case inQuote && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func isIndex(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil && !strings.HasPrefix(s, "-") && !strings.HasPrefix(s, "+")
+}
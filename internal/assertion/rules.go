@@ -0,0 +1,73 @@
+package assertion
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a named pair of pass/fail assertions evaluated by monitor mode.
+type Rule struct {
+	Name   string
+	Events []string
+	Pass   []Assertion
+	Fail   []Assertion
+}
+
+type rulesFile struct {
+	Rules []struct {
+		Name   string   `yaml:"name"`
+		Events []string `yaml:"events"`
+		Pass   []string `yaml:"pass"`
+		Fail   []string `yaml:"fail"`
+	} `yaml:"rules"`
+}
+
+// LoadRules reads monitor rules from a YAML file:
+//
+//	rules:
+//	  - name: deploys
+//	    events: [deployment_status]
+//	    pass: ["payload.deployment_status.state=success"]
+//	    fail: ["payload.deployment_status.state=~(failure|error)"]
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	if len(file.Rules) == 0 {
+		return nil, fmt.Errorf("rules file %s defines no rules", path)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	seen := make(map[string]bool, len(file.Rules))
+	for i, raw := range file.Rules {
+		name := strings.TrimSpace(raw.Name)
+		if name == "" {
+			return nil, fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", name)
+		}
+		seen[name] = true
+		if len(raw.Pass) == 0 && len(raw.Fail) == 0 {
+			return nil, fmt.Errorf("rule %q: needs at least one pass or fail assertion", name)
+		}
+		pass, err := ParseAssertions(raw.Pass, 0)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, err)
+		}
+		fail, err := ParseAssertions(raw.Fail, 1)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, err)
+		}
+		rules = append(rules, Rule{Name: name, Events: raw.Events, Pass: pass, Fail: fail})
+	}
+	return rules, nil
+}
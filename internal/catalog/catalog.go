@@ -0,0 +1,50 @@
+// Package catalog lists the GitHub webhook event types and their actions.
+package catalog
+
+import (
+	_ "embed"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed events.yaml
+var eventsYAML []byte
+
+var actions = func() map[string][]string {
+	var parsed map[string][]string
+	if err := yaml.Unmarshal(eventsYAML, &parsed); err != nil {
+		panic("catalog: invalid events.yaml: " + err.Error())
+	}
+	return parsed
+}()
+
+// Events returns every known event type, sorted.
+func Events() []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Actions returns the actions of the given event types, or of every event
+// when none are given, sorted and without duplicates.
+func Actions(events ...string) []string {
+	if len(events) == 0 {
+		events = Events()
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, event := range events {
+		for _, action := range actions[event] {
+			if !seen[action] {
+				seen[action] = true
+				names = append(names, action)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
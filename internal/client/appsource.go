@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+const appDeliveriesPerPage = 100
+
+// source produces events until ctx ends or handle returns an error.
+type source interface {
+	Run(ctx context.Context, handle func(message.EventMessage) error) error
+}
+
+// appSource polls a GitHub App's webhook delivery log and emits deliveries
+// made after it started, for setups where hooks can't point at smee.io.
+type appSource struct {
+	deliveries github.Deliveries
+	interval   time.Duration
+	redeliver  bool
+	logger     *log.Logger
+}
+
+func (s *appSource) Run(ctx context.Context, handle func(message.EventMessage) error) error {
+	var lastID int64 = -1
+	for {
+		if err := s.poll(ctx, &lastID, handle); err != nil {
+			return err
+		}
+		wait := time.NewTimer(s.interval)
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return ctx.Err()
+		case <-wait.C:
+		}
+	}
+}
+
+// poll emits deliveries newer than lastID, oldest first. API errors are
+// logged and retried on the next poll; only handler errors are returned.
+func (s *appSource) poll(ctx context.Context, lastID *int64, handle func(message.EventMessage) error) error {
+	list, err := s.deliveries.List(ctx, appDeliveriesPerPage)
+	if err != nil {
+		if ctx.Err() == nil && s.logger != nil {
+			s.logger.Printf("failed to list app deliveries: %v", err)
+		}
+		return nil
+	}
+	if *lastID < 0 {
+		*lastID = 0
+		if len(list) > 0 {
+			*lastID = list[0].ID
+		}
+		if s.logger != nil {
+			s.logger.Printf("polling app webhook deliveries every %s", s.interval)
+		}
+		return nil
+	}
+
+	var fresh []github.Delivery
+	for _, d := range list {
+		if d.ID <= *lastID {
+			break
+		}
+		fresh = append(fresh, d)
+	}
+	if len(fresh) > 0 {
+		defer func() { *lastID = max(*lastID, fresh[0].ID) }()
+	}
+	if len(fresh) == len(list) && len(list) == appDeliveriesPerPage && s.logger != nil {
+		s.logger.Printf("more than %d app deliveries since the last poll; some may be missed", appDeliveriesPerPage)
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		summary := fresh[i]
+		if summary.Redelivery {
+			// Redeliveries repeat a GUID that was already emitted.
+			continue
+		}
+		delivery, err := s.deliveries.Get(ctx, summary.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if s.logger != nil {
+				s.logger.Printf("failed to fetch app delivery %s: %v", summary.GUID, err)
+			}
+			continue
+		}
+		if s.redeliver && delivery.Failed() {
+			if err := s.deliveries.Redeliver(ctx, delivery.ID); err != nil {
+				if s.logger != nil {
+					s.logger.Printf("failed to redeliver %s: %v", delivery.GUID, err)
+				}
+			} else if s.logger != nil {
+				s.logger.Printf("requested redelivery of %s (%s, status %d)", delivery.GUID, delivery.Event, delivery.StatusCode)
+			}
+		}
+		if delivery.Request == nil {
+			continue
+		}
+		payload := delivery.Request.Payload
+		if len(payload) == 0 {
+			payload = []byte("null")
+		}
+		msg := message.EventMessage{
+			Type:       "event",
+			Event:      delivery.Event,
+			DeliveryID: delivery.GUID,
+			Payload:    payload,
+			ReceivedAt: time.Now().UTC(),
+			Signature:  delivery.Request.Headers["X-Hub-Signature-256"],
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seenSet remembers recent delivery IDs so an event arriving from several
+// sources is emitted once.
+type seenSet struct {
+	limit int
+	ids   map[string]bool
+	order []string
+}
+
+func newSeenSet(limit int) *seenSet {
+	return &seenSet{limit: limit, ids: make(map[string]bool)}
+}
+
+// add records id and reports whether it was already present.
+func (s *seenSet) add(id string) bool {
+	if s.ids[id] {
+		return true
+	}
+	s.ids[id] = true
+	s.order = append(s.order, id)
+	if len(s.order) > s.limit {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+	return false
+}
+
+// mergeSources runs every source concurrently, handing events to handle one
+// at a time and dropping repeated delivery IDs. The first source to stop
+// ends the run.
+func mergeSources(ctx context.Context, sources []source, handle func(message.EventMessage) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	seen := newSeenSet(10000)
+	merged := func(msg message.EventMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if seen.add(msg.DeliveryID) {
+			return nil
+		}
+		return handle(msg)
+	}
+
+	errCh := make(chan error, len(sources))
+	for _, src := range sources {
+		go func() {
+			errCh <- src.Run(ctx, merged)
+		}()
+	}
+	err := <-errCh
+	cancel()
+	for range len(sources) - 1 {
+		<-errCh
+	}
+	return err
+}
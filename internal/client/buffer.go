@@ -0,0 +1,343 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+)
+
+// spillBufferBytes is how much a capture buffer holds in memory before
+// moving events to disk when a spill directory is set.
+const spillBufferBytes = 64 * 1024 * 1024
+
+type capturedEvent struct {
+	receivedAt time.Time
+	event      string
+	encoded    []byte
+}
+
+// captureBuffer holds encoded events until capture mode dumps them. With a
+// spill directory, older events move to segment files once the in-memory
+// part reaches spillBufferBytes, and dump streams them back in order.
+type captureBuffer struct {
+	events   []capturedEvent
+	bytes    int64
+	spillDir string
+	segments []spillSegment
+	cutoff   time.Time
+	// keepLast and keepBytes, when positive, make the buffer a ring that
+	// retains only the most recent events.
+	keepLast  int
+	keepBytes int64
+}
+
+// spillSegment is a file of events written as "<unix-nanos>\t<event>\t<line>"
+// so the trigger window and per-event splitting still apply when it is read
+// back.
+type spillSegment struct {
+	path string
+	last time.Time
+}
+
+func newCaptureBuffer(spillDir string) *captureBuffer {
+	return &captureBuffer{events: make([]capturedEvent, 0, 128), spillDir: spillDir}
+}
+
+func (b *captureBuffer) add(event string, encoded []byte, receivedAt time.Time) error {
+	b.events = append(b.events, capturedEvent{receivedAt: receivedAt, event: event, encoded: encoded})
+	b.bytes += int64(len(encoded))
+	b.trim()
+	if b.spillDir != "" && b.bytes >= spillBufferBytes {
+		return b.spill()
+	}
+	return nil
+}
+
+// spill moves the in-memory events to a new segment file.
+func (b *captureBuffer) spill() error {
+	f, err := os.CreateTemp(b.spillDir, "gh-pulse-capture-*.spill")
+	if err != nil {
+		return fmt.Errorf("failed to spill capture buffer: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, event := range b.events {
+		w.WriteString(strconv.FormatInt(event.receivedAt.UnixNano(), 10))
+		w.WriteByte('\t')
+		w.WriteString(event.event)
+		w.WriteByte('\t')
+		w.Write(event.encoded)
+		w.WriteByte('\n')
+	}
+	err = errors.Join(w.Flush(), f.Close())
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("failed to spill capture buffer: %w", err)
+	}
+	b.segments = append(b.segments, spillSegment{path: f.Name(), last: b.events[len(b.events)-1].receivedAt})
+	b.events = b.events[:0]
+	b.bytes = 0
+	return nil
+}
+
+// trim drops the oldest events beyond the keepLast and keepBytes limits,
+// always keeping the newest event.
+func (b *captureBuffer) trim() {
+	idx := 0
+	for idx < len(b.events)-1 {
+		overCount := b.keepLast > 0 && len(b.events)-idx > b.keepLast
+		overBytes := b.keepBytes > 0 && b.bytes > b.keepBytes
+		if !overCount && !overBytes {
+			break
+		}
+		b.bytes -= int64(len(b.events[idx].encoded))
+		idx++
+	}
+	if idx > 0 {
+		b.events = b.events[idx:]
+	}
+}
+
+// dropBefore discards events received before cutoff.
+func (b *captureBuffer) dropBefore(cutoff time.Time) {
+	b.cutoff = cutoff
+	for len(b.segments) > 0 && b.segments[0].last.Before(cutoff) {
+		_ = os.Remove(b.segments[0].path)
+		b.segments = b.segments[1:]
+	}
+	idx := 0
+	for idx < len(b.events) && b.events[idx].receivedAt.Before(cutoff) {
+		b.bytes -= int64(len(b.events[idx].encoded))
+		idx++
+	}
+	if idx > 0 {
+		b.events = append(b.events[:0], b.events[idx:]...)
+	}
+}
+
+func (b *captureBuffer) dump(stdout *bufio.Writer) error {
+	err := b.each(func(event string, encoded []byte) error {
+		if _, err := stdout.Write(encoded); err != nil {
+			return err
+		}
+		return stdout.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return stdout.Flush()
+}
+
+// dumpTo writes the buffer to split when set, or stdout.
+func (b *captureBuffer) dumpTo(stdout *bufio.Writer, split outputWriter) error {
+	if split == nil {
+		return b.dump(stdout)
+	}
+	return errors.Join(b.each(split.write), split.close())
+}
+
+// each calls fn for every buffered event in order, spilled events first.
+func (b *captureBuffer) each(fn func(event string, encoded []byte) error) error {
+	for _, segment := range b.segments {
+		if err := b.eachSpilled(segment, fn); err != nil {
+			return err
+		}
+	}
+	for _, event := range b.events {
+		if err := fn(event.event, event.encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *captureBuffer) eachSpilled(segment spillSegment, fn func(event string, encoded []byte) error) error {
+	f, err := os.Open(segment.path)
+	if err != nil {
+		return fmt.Errorf("failed to read spilled events: %w", err)
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			stamp, rest, _ := bytes.Cut(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\t'})
+			event, encoded, _ := bytes.Cut(rest, []byte{'\t'})
+			nanos, _ := strconv.ParseInt(string(stamp), 10, 64)
+			if !time.Unix(0, nanos).Before(b.cutoff) {
+				if err := fn(string(event), encoded); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read spilled events: %w", err)
+		}
+	}
+}
+
+// close removes any spilled segment files.
+func (b *captureBuffer) close() {
+	for _, segment := range b.segments {
+		_ = os.Remove(segment.path)
+	}
+	b.segments = nil
+}
+
+// captureStage buffers deliveries for capture mode, enforcing the buffer
+// limits and the --trigger window.
+type captureStage struct {
+	cfg       Config
+	buffer    *captureBuffer
+	logger    *log.Logger
+	finish    chan<- error
+	result    *runResult
+	warned    bool
+	triggered bool
+	// mu guards buffer against on-demand dumps from the signal handler, and
+	// is held by everything else that writes to stdout while the run lasts.
+	mu sync.Mutex
+}
+
+func (c *captureStage) middleware(next Handler) Handler {
+	windowed := len(c.cfg.Trigger) > 0
+	return HandlerFunc(func(d *Delivery) error {
+		doc, err := d.Document()
+		if err != nil {
+			return nil
+		}
+		lines, err := d.Output()
+		if err != nil {
+			return nil
+		}
+		if err := c.store(splitKey(c.cfg.SplitBy, d), lines, d.ReceivedAt, windowed && !c.triggered); err != nil {
+			return err
+		}
+
+		if trigger, ok := c.matchTrigger(doc, windowed); ok {
+			c.triggered = true
+			c.result.note(0, "trigger", trigger.String(), c.result.ref(doc))
+			c.result.match("trigger", trigger.String(), c.result.ref(doc), "")
+			if c.logger != nil {
+				c.logger.Printf("trigger matched, capturing for %s", c.cfg.PostTrigger)
+			}
+			if c.cfg.PostTrigger == 0 {
+				return exitError{code: 0}
+			}
+			time.AfterFunc(c.cfg.PostTrigger, func() {
+				finishWith(c.finish, exitError{code: 0})
+			})
+		}
+		return next.Handle(d)
+	})
+}
+
+// matchTrigger returns the trigger assertion the event matches, if the
+// trigger is still armed.
+func (c *captureStage) matchTrigger(doc assertion.Document, windowed bool) (assertion.Assertion, bool) {
+	if !windowed || c.triggered {
+		return assertion.Assertion{}, false
+	}
+	return firstMatch(doc, c.cfg.Trigger)
+}
+
+// store buffers the lines and applies the pre-trigger window and size limits.
+func (c *captureStage) store(event string, lines [][]byte, receivedAt time.Time, windowed bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range lines {
+		if err := c.buffer.add(event, line, receivedAt); err != nil {
+			return fatalError{err: err}
+		}
+	}
+	if windowed {
+		c.buffer.dropBefore(receivedAt.Add(-c.cfg.PreTrigger))
+	}
+	if c.buffer.spillDir == "" && !c.warned && c.buffer.bytes >= warnBufferBytes {
+		if c.logger != nil {
+			c.logger.Printf("capture buffer exceeded 100MB")
+		}
+		c.warned = true
+	}
+	if c.buffer.bytes >= maxBufferBytes {
+		return fatalError{err: fmt.Errorf("capture buffer exceeded 500MB")}
+	}
+	return nil
+}
+
+// usage reports how many events and bytes the buffer holds in memory.
+func (c *captureStage) usage() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.buffer.events), c.buffer.bytes
+}
+
+// dumpOnSignal writes a snapshot of the buffer each time the process gets
+// SIGUSR1, to cfg.DumpFile or stdout, without clearing it. The returned
+// function stops listening, once a snapshot being written has finished.
+func (c *captureStage) dumpOnSignal(stdout *bufio.Writer) func() {
+	sigCh := make(chan os.Signal, 1)
+	if !notifyDump(sigCh) {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-sigCh:
+				if err := c.snapshot(stdout); err != nil && c.logger != nil {
+					c.logger.Printf("failed to dump capture buffer: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+		<-stopped
+	}
+}
+
+func (c *captureStage) snapshot(stdout *bufio.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.DumpFile == "" {
+		if err := c.buffer.dump(stdout); err != nil {
+			return err
+		}
+	} else {
+		f, err := os.Create(c.cfg.DumpFile)
+		if err != nil {
+			return err
+		}
+		enc := encryptTo(f, c.cfg.Encrypt)
+		w := bufio.NewWriter(enc)
+		if err := errors.Join(c.buffer.dump(w), enc.Close(), f.Close()); err != nil {
+			return err
+		}
+	}
+	if c.logger != nil {
+		target := c.cfg.DumpFile
+		if target == "" {
+			target = "stdout"
+		}
+		c.logger.Printf("dumped capture buffer to %s", target)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batching defaults for ClickHouse, which prefers few large inserts to many
+// small ones.
+const (
+	defaultClickHouseBatch    = 1000
+	defaultClickHouseInterval = 5 * time.Second
+	// clickhouseBacklog is how many batches may pile up while ClickHouse is
+	// unreachable before the run fails instead of buffering more.
+	clickhouseBacklog = 10
+)
+
+// clickhouseSchema is the table created when it doesn't exist: the indexed
+// columns as low-cardinality strings, ordered for per-repository queries.
+const clickhouseSchema = `CREATE TABLE IF NOT EXISTS %s (
+	delivery_id String,
+	event LowCardinality(String),
+	action LowCardinality(String),
+	repo LowCardinality(String),
+	received_at DateTime64(3, 'UTC'),
+	payload String
+) ENGINE = MergeTree ORDER BY (repo, event, received_at)`
+
+// clickhouseTime is the DateTime64 format ClickHouse parses by default.
+const clickhouseTime = "2006-01-02 15:04:05.000"
+
+// clickhouseWriter batches output lines and inserts them over ClickHouse's
+// HTTP interface as JSONEachRow, when a batch fills up or every interval.
+type clickhouseWriter struct {
+	endpoint *url.URL
+	table    string
+	http     *http.Client
+	batch    int
+
+	// mu guards the pending rows against the interval flush.
+	mu      sync.Mutex
+	rows    bytes.Buffer
+	pending int
+	err     error
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type clickhouseRow struct {
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event"`
+	Action     string `json:"action"`
+	Repo       string `json:"repo"`
+	ReceivedAt string `json:"received_at"`
+	Payload    string `json:"payload"`
+}
+
+func newClickHouseWriter(location string, batch int, interval time.Duration) (*clickhouseWriter, error) {
+	endpoint, table, err := clickhouseEndpoint(location)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --output: %v", err)}
+	}
+	if batch <= 0 {
+		batch = defaultClickHouseBatch
+	}
+	if interval <= 0 {
+		interval = defaultClickHouseInterval
+	}
+	w := &clickhouseWriter{
+		endpoint: endpoint,
+		table:    table,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		batch:    batch,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if err := w.exec(fmt.Sprintf(clickhouseSchema, table), nil); err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --output: %v", err)}
+	}
+	go w.flushEvery(interval)
+	return w, nil
+}
+
+func (w *clickhouseWriter) key(d *Delivery) string {
+	return d.Message.Event
+}
+
+func (w *clickhouseWriter) write(key string, line []byte) error {
+	row, err := decodeRow(line)
+	if err != nil {
+		return err
+	}
+	receivedAt := row.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	encoded, err := json.Marshal(clickhouseRow{
+		DeliveryID: row.DeliveryID,
+		Event:      row.Event,
+		Action:     row.Action,
+		Repo:       row.Repo,
+		ReceivedAt: receivedAt.UTC().Format(clickhouseTime),
+		Payload:    string(row.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rows.Write(encoded)
+	w.rows.WriteByte('\n')
+	w.pending++
+	if w.pending >= w.batch {
+		w.insertLocked()
+	}
+	return w.backlogLocked()
+}
+
+// flush leaves sending to the batch size and interval; it only reports an
+// insert failure once too many rows are waiting on it.
+func (w *clickhouseWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.backlogLocked()
+}
+
+func (w *clickhouseWriter) close() error {
+	close(w.stop)
+	<-w.stopped
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending > 0 {
+		w.insertLocked()
+	}
+	if w.pending > 0 {
+		return fmt.Errorf("clickhouse: %d events not inserted: %w", w.pending, w.err)
+	}
+	return nil
+}
+
+func (w *clickhouseWriter) flushEvery(interval time.Duration) {
+	defer close(w.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.pending > 0 {
+				w.insertLocked()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// insertLocked sends the pending rows, keeping them to retry with the next
+// batch when the insert fails.
+func (w *clickhouseWriter) insertLocked() {
+	query := fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", w.table)
+	if w.err = w.exec(query, w.rows.Bytes()); w.err == nil {
+		w.rows.Reset()
+		w.pending = 0
+	}
+}
+
+func (w *clickhouseWriter) backlogLocked() error {
+	if w.err != nil && w.pending >= clickhouseBacklog*w.batch {
+		return fmt.Errorf("clickhouse: %d events waiting to be inserted: %w", w.pending, w.err)
+	}
+	return nil
+}
+
+// exec runs query with body as its data, over ClickHouse's HTTP interface.
+func (w *clickhouseWriter) exec(query string, body []byte) error {
+	endpoint := *w.endpoint
+	params := endpoint.Query()
+	params.Set("query", query)
+	endpoint.RawQuery = params.Encode()
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := w.http.Do(req)
+	if err != nil {
+		// The request URL carries the query and any credentials, so only
+		// the cause is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("clickhouse %s: %w", w.endpoint.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("clickhouse: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -3,29 +3,186 @@ package client
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
+	"filippo.io/age"
 	"github.com/kehao95/gh-pulse/internal/assertion"
-	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/github"
+	"github.com/kehao95/gh-pulse/internal/redact"
+	"github.com/kehao95/gh-pulse/internal/route"
+	"github.com/kehao95/gh-pulse/internal/secret"
 	"github.com/kehao95/gh-pulse/internal/sse"
 )
 
 type Config struct {
 	URL               string
 	Events            []string
+	Actions           []string
 	SuccessAssertions []assertion.Assertion
+	// SuccessAll must each match some event, in any order, for the run to
+	// succeed.
+	SuccessAll []assertion.Assertion
+	// Deadlines fail the run when an end event does not follow its start
+	// event in time.
+	Deadlines []assertion.Deadline
+	// Until ends the run successfully once any of the expressions holds;
+	// UntilInputs holds their source text for logging.
+	Until             []*assertion.Expr
+	UntilInputs       []string
 	FailureAssertions []assertion.Assertion
-	Timeout           time.Duration
-	Quiet             bool
+	Ignore            []assertion.Assertion
+	Senders           []string
+	ExcludeBots       bool
+	OnlyHuman         bool
+	VerifySecret      string
+	// VerifySecretFrom, when set, replaces VerifySecret with a secret read
+	// from a file or secret manager and refreshed while the run lasts.
+	VerifySecretFrom *secret.Secret
+	KeepUnverified   bool
+	Timeout          time.Duration
+	Settle           time.Duration
+	// SettleCancel cancels a pending settle period when an event matches,
+	// so the run waits for a success condition to match again.
+	SettleCancel []assertion.Assertion
+	// Grace keeps the run going for this long after the first success
+	// match, so trailing related events are still emitted.
+	Grace time.Duration
+	// Context, in stream mode, is how many preceding events to look back
+	// over when an assertion ends the run; those the filters dropped are
+	// printed marked "context": true.
+	Context     int
+	Trigger     []assertion.Assertion
+	PreTrigger  time.Duration
+	PostTrigger time.Duration
+	// Result prints a final {"type":"result"} line naming the exit code and
+	// the rule and event that decided it.
+	Result bool
+	// JUnitReport, when set, is a file to write the exit rules to as JUnit
+	// test cases when the run ends.
+	JUnitReport string
+	// GitHubOutput is the GITHUB_OUTPUT file of a GitHub Actions step; when
+	// set, the outcome and OutputVars read from the deciding event are
+	// appended to it as step outputs.
+	GitHubOutput string
+	OutputVars   []OutputVar
+	// SpillDir, when set, lets capture mode move buffered events to disk
+	// instead of failing at the in-memory limit.
+	SpillDir string
+	// KeepLast and KeepLastBytes, when positive, limit capture mode to the
+	// most recent events.
+	KeepLast      int
+	KeepLastBytes int64
+	// DumpFile receives on-demand SIGUSR1 dumps in capture mode instead of
+	// stdout.
+	DumpFile string
+	// Encrypt, in capture mode, encrypts stdout, OutputDir files, and
+	// DumpFile to these age recipients.
+	Encrypt []age.Recipient
+	// OutputDir, when set, replaces stdout with one <value>.jsonl file per
+	// value of the SplitBy path (the event type when empty) in that
+	// directory.
+	OutputDir string
+	SplitBy   string
+	// Output, when set, replaces stdout with a database, named as
+	// kind:location (e.g. sqlite:events.db). ClickHouse inserts are
+	// batched by OutputBatchSize events or OutputFlushInterval, whichever
+	// comes first.
+	Output              string
+	OutputBatchSize     int
+	OutputFlushInterval time.Duration
+	// SinkPlugin, when set, is a command that replaces stdout: it is
+	// started once and fed the output as JSONL on stdin, and its exit code
+	// decides the run.
+	SinkPlugin string
+	// File, when set, reads events from a JSONL file instead of a channel;
+	// with Follow, lines appended to it are read as they arrive.
+	File   string
+	Follow bool
+	// FailFast and MaxRetries make the client give up with exit code 69
+	// after MaxRetries consecutive failed reconnects instead of retrying
+	// forever. MaxRetries alone also enables this.
+	FailFast   bool
+	MaxRetries int
+	// Generic accepts non-GitHub webhooks from the channel, naming each
+	// event by the EventHeader request header.
+	Generic     bool
+	EventHeader string
+	// Chaos, when set, injects delays, duplicate deliveries, and dropped
+	// connections into the channel stream, for testing.
+	Chaos *sse.Chaos
+	// AppID and AppKeyFile add a GitHub App's webhook delivery log as an
+	// event source, polled every AppPollInterval. With RedeliverFailed,
+	// deliveries the App's endpoint rejected are redelivered.
+	AppID           string
+	AppKeyFile      string
+	AppPollInterval time.Duration
+	RedeliverFailed bool
+	// Enrich attaches related GitHub API resources to supported events,
+	// authenticated with GitHubToken.
+	Enrich      bool
+	GitHubToken string
+	// StatsInterval, when positive, logs event and byte rates, drops,
+	// buffer usage, and reconnects to stderr this often, even with Quiet.
+	StatsInterval time.Duration
+	// StatsD, when set, sends event, output, and reconnect counters to this
+	// StatsD host:port, with StatsDTags in DogStatsD format.
+	StatsD     string
+	StatsDTags []string
+	// Ready writes a {"type":"ready"} line to stdout, and ReadyFile writes
+	// it to a file, once the channel confirms the subscription.
+	Ready     bool
+	ReadyFile string
+	// Heartbeat, in stream mode, writes a {"type":"heartbeat"} line this
+	// often while the channel is connected.
+	Heartbeat time.Duration
+	// MaxRate, in stream mode, limits output to this many events per
+	// second. Events over the rate wait for their turn, or with DropOverRate
+	// are left out of the output; assertions still see every event.
+	MaxRate      float64
+	DropOverRate bool
+	// MaxEventSize drops events whose payload is larger than this many
+	// bytes, after the filters and signature check; with TruncateOversize
+	// they are cut down and marked truncated instead.
+	MaxEventSize     int64
+	TruncateOversize bool
+	// Redact, when set, scrubs each event after the filters and enrichment,
+	// so output, assertions, and dumps all see the redacted event.
+	Redact *redact.Redactor
+	// GroupBy, in stream mode, replaces the event output with one
+	// {"type":"group"} summary per value of the first of these paths an
+	// event has, written when the group reaches a terminal state or the run
+	// ends.
+	GroupBy []string
+	// Routes, in stream mode, forward each event to the targets of the
+	// routes it matches, alongside the normal output.
+	Routes []route.Route
+	// JournalDir keeps each route's undelivered events on disk, so they
+	// wait out a down target and survive a restart.
+	JournalDir string
+	// DeadLetter is a JSONL file for the events a route gave up on.
+	DeadLetter string
+	// EmitResponses writes a {"type":"response"} line with the status and
+	// latency of each forwarded delivery.
+	EmitResponses bool
+	// SuccessOnResponse and FailureOnResponse end the run when a forwarded
+	// delivery's outcome, as written by EmitResponses, matches.
+	SuccessOnResponse []assertion.Assertion
+	FailureOnResponse []assertion.Assertion
+	// Script is a Lua file whose transform function changes, drops, or
+	// adds to each event after redaction.
+	Script string
+	// Middleware stages run after the built-in filters and before output.
+	Middleware []Middleware
+	// JQ is a jq query applied to each event's output.
+	JQ    string
+	Quiet bool
 }
 
 const (
@@ -45,6 +202,97 @@ func (e exitError) ExitCode() int {
 	return e.code
 }
 
+// exitCode returns the process exit code err stands for: 0 for nil or a
+// successful exit, 1 for errors without their own code.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var coded interface{ ExitCode() int }
+	if errors.As(err, &coded) {
+		return coded.ExitCode()
+	}
+	return 1
+}
+
+// connectionError reports that the channel was unreachable, so callers can
+// tell relay outages apart from assertion timeouts.
+type connectionError struct {
+	err error
+}
+
+func (e connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e connectionError) Unwrap() error {
+	return e.err
+}
+
+func (e connectionError) ExitCode() int {
+	return 69
+}
+
+// newSSEClient builds the channel client with the reconnect limit from cfg.
+func newSSEClient(cfg Config, logger *log.Logger) *sse.Client {
+	client := sse.NewClient(cfg.URL, logger)
+	client.Generic = cfg.Generic
+	client.EventHeader = cfg.EventHeader
+	client.Chaos = cfg.Chaos
+	if cfg.FailFast || cfg.MaxRetries > 0 {
+		client.MaxAttempts = cfg.MaxRetries + 1
+	}
+	return client
+}
+
+// newSources returns the smee channel and, when an App is configured, the
+// App's webhook delivery log. The channel is optional in App mode.
+func newSources(cfg Config, logger *log.Logger) ([]source, error) {
+	var sources []source
+	if cfg.File != "" {
+		return []source{&fileSource{path: cfg.File, follow: cfg.Follow, poll: 250 * time.Millisecond, logger: logger}}, nil
+	}
+	if cfg.URL != "" || cfg.AppID == "" {
+		if err := ValidateURL(cfg.URL); err != nil {
+			return nil, err
+		}
+		sources = append(sources, newSSEClient(cfg, logger))
+	}
+	if cfg.AppID != "" {
+		app, err := github.LoadApp(cfg.AppID, cfg.AppKeyFile)
+		if err != nil {
+			return nil, configError{err: err}
+		}
+		interval := cfg.AppPollInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		sources = append(sources, &appSource{
+			deliveries: github.Deliveries{API: github.NewAppClient("", app), Base: "/app/hook"},
+			interval:   interval,
+			redeliver:  cfg.RedeliverFailed,
+			logger:     logger,
+		})
+	}
+	return sources, nil
+}
+
+// runSources delivers events from every source and maps giving up on the
+// channel to exit code 69.
+func runSources(ctx context.Context, sources []source, handler Handler) error {
+	var err error
+	if len(sources) == 1 {
+		err = sources[0].Run(ctx, deliver(handler))
+	} else {
+		err = mergeSources(ctx, sources, deliver(handler))
+	}
+	var connectErr *sse.ConnectError
+	if errors.As(err, &connectErr) {
+		return connectionError{err: connectErr}
+	}
+	return err
+}
+
 type configError struct {
 	err error
 }
@@ -57,7 +305,9 @@ func (e configError) ExitCode() int {
 	return 2
 }
 
-func validateURL(raw string) error {
+// ValidateURL checks that raw is an http(s) channel URL, or a unix:// URL
+// naming a local socket.
+func ValidateURL(raw string) error {
 	parsed, err := url.Parse(raw)
 	if err != nil {
 		return configError{err: fmt.Errorf("invalid URL: %v", err)}
@@ -65,6 +315,12 @@ func validateURL(raw string) error {
 	if parsed.Scheme == "" {
 		return configError{err: fmt.Errorf("invalid URL: missing scheme (expected https://...)")}
 	}
+	if parsed.Scheme == "unix" {
+		if parsed.Path == "" {
+			return configError{err: fmt.Errorf("invalid URL: missing socket path (expected unix:///path/to.sock)")}
+		}
+		return nil
+	}
 	if parsed.Scheme != "http" && parsed.Scheme != "https" {
 		return configError{err: fmt.Errorf("invalid URL: unsupported scheme %q (expected https://...)", parsed.Scheme)}
 	}
@@ -72,108 +328,260 @@ func validateURL(raw string) error {
 }
 
 func Run(ctx context.Context, cfg Config) error {
-	if err := validateURL(cfg.URL); err != nil {
-		return err
-	}
 	var logger *log.Logger
 	if !cfg.Quiet {
 		logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
 	stdout := bufio.NewWriter(os.Stdout)
-	client := sse.NewClient(cfg.URL, logger)
-	return runWithTimeout(ctx, cfg.Timeout, func(runCtx context.Context) error {
-		return client.Run(runCtx, func(msg message.EventMessage) error {
-			if !eventAllowed(cfg.Events, msg.Event) {
-				return nil
+	sources, err := newSources(cfg, logger)
+	if err != nil {
+		return err
+	}
+	finish := make(chan error, 1)
+
+	var window *contextWindow
+	var unselected Handler
+	if cfg.Context > 0 {
+		window = newContextWindow(cfg, stdout, logger)
+		unselected = HandlerFunc(window.remember)
+	}
+	stages, err := pipeline(cfg, logger, unselected)
+	if err != nil {
+		return err
+	}
+	split, err := newOutputWriter(cfg)
+	if err != nil {
+		return err
+	}
+	outputClosed := false
+	defer func() {
+		if split != nil && !outputClosed {
+			split.close()
+		}
+	}()
+	if window != nil {
+		window.split = split
+	}
+	var stats *throughput
+	if cfg.StatsInterval > 0 {
+		stats = newThroughput(sources, statsLogger(logger))
+		stages = append([]Middleware{stats.count}, stages...)
+		defer stats.start(cfg.StatsInterval)()
+	}
+	var statsd *statsdReporter
+	if cfg.StatsD != "" {
+		if statsd, err = newStatsdReporter(cfg.StatsD, cfg.StatsDTags, "gh_pulse.stream", sources); err != nil {
+			return err
+		}
+		stages = append([]Middleware{statsd.count}, stages...)
+		defer statsd.start()()
+	}
+	var limiter *rateLimiter
+	if cfg.MaxRate > 0 {
+		limiter = newRateLimiter(cfg.MaxRate, cfg.DropOverRate, logger)
+	}
+	sink := writeStage(stdout, logger)
+	if split != nil {
+		sink = splitStage(split, logger)
+	}
+	conds := newExitConditions(cfg, finish, logger)
+	defer conds.deadlines.stop()
+
+	ready := newReadySignal(cfg, stdout, logger)
+	stopHeartbeat := func() {}
+	// outputMu, when set, is held while a delivery writes to stdout, so
+	// lines written from other goroutines don't interleave with it.
+	var outputMu *sync.Mutex
+	if cfg.Heartbeat > 0 {
+		hb := newHeartbeat(stdout, sources, logger)
+		outputMu = &hb.mu
+		ready.mu = &hb.mu
+		stages = append([]Middleware{hb.guard}, stages...)
+		stopHeartbeat = hb.start(cfg.Heartbeat)
+	}
+	if cfg.Ready || cfg.ReadyFile != "" {
+		ready.watch(sources)
+	}
+	var grouped *groups
+	if len(cfg.GroupBy) > 0 {
+		grouped = newGroups(cfg.GroupBy, logger)
+	}
+	var forward *forwarder
+	var responses *responseLog
+	if len(cfg.Routes) > 0 {
+		if forward, err = newForwarder(cfg, logger); err != nil {
+			return err
+		}
+		if cfg.EmitResponses {
+			if outputMu == nil {
+				outputMu = &sync.Mutex{}
+				stages = append([]Middleware{lockStage(outputMu)}, stages...)
 			}
-			encoded, err := json.Marshal(msg)
-			if err != nil {
-				if logger != nil {
-					logger.Printf("failed to encode event: %v", err)
+			responses = newResponseLog(stdout, outputMu, logger)
+			forward.respond = responses.add
+		}
+		if conds.responses.enabled() {
+			emit := forward.respond
+			forward.respond = func(resp forwardResponse) {
+				if emit != nil {
+					emit(resp)
 				}
-				return nil
-			}
-			if _, err := stdout.Write(encoded); err != nil {
-				return err
-			}
-			if err := stdout.WriteByte('\n'); err != nil {
-				return err
-			}
-			if err := stdout.Flush(); err != nil {
-				return err
+				conds.responses.observe(resp)
 			}
+		}
+		forward.start(ctx)
+		stages = append(stages, forward.stage)
+	}
 
-			if matchesAssertions(encoded, cfg.SuccessAssertions) {
-				return exitError{code: 0}
-			}
-			if matchesAssertions(encoded, cfg.FailureAssertions) {
-				return exitError{code: 1}
-			}
-			return nil
-		})
+	err = runWithTimeout(ctx, cfg.Timeout, finish, func(runCtx context.Context) error {
+		// The rate limiter waits on runCtx, so a timeout or exit condition
+		// isn't held up by an event waiting for its turn.
+		if limiter != nil {
+			stages = append(stages, limiter.stage(runCtx))
+		}
+		if stats != nil {
+			stages = append(stages, stats.pass)
+		}
+		if statsd != nil {
+			stages = append(stages, statsd.pass)
+		}
+		if grouped != nil {
+			stages = append(stages, grouped.stage)
+		}
+		if window != nil {
+			stages = append(stages, window.hold)
+		}
+		handler := Chain(assertHandler(conds), append(stages, sink)...)
+		return runSources(runCtx, sources, handler)
 	})
+	stopHeartbeat()
+	if forward != nil {
+		forward.stop()
+	}
+	if responses != nil {
+		responses.close()
+	}
+	if limiter != nil {
+		limiter.report()
+	}
+	if grouped != nil {
+		if flushErr := grouped.flush(stdout); flushErr != nil {
+			return flushErr
+		}
+	}
+	err = conds.finish(err)
+	if split != nil {
+		// Closing the output can fail late: a database's last insert, or a
+		// sink plugin's exit code.
+		outputClosed = true
+		if closeErr := split.close(); closeErr != nil && exitCode(err) == 0 {
+			err = closeErr
+		}
+	}
+	if reportErr := conds.report(cfg, stdout, "gh-pulse stream", err); reportErr != nil {
+		return reportErr
+	}
+	return err
 }
 
 func RunCapture(ctx context.Context, cfg Config) error {
-	if err := validateURL(cfg.URL); err != nil {
-		return err
+	out := encryptTo(os.Stdout, cfg.Encrypt)
+	err := runCapture(ctx, cfg, out)
+	// The last chunk is written even when the run failed, so what was
+	// captured can still be decrypted.
+	if closeErr := out.Close(); closeErr != nil && exitCode(err) == 0 {
+		err = closeErr
 	}
+	return err
+}
+
+func runCapture(ctx context.Context, cfg Config, out io.Writer) error {
 	var logger *log.Logger
 	if !cfg.Quiet {
 		logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
-	stdout := bufio.NewWriter(os.Stdout)
-	buffer := make([][]byte, 0, 128)
-	var bufferBytes int64
-	warned := false
-	client := sse.NewClient(cfg.URL, logger)
+	stdout := bufio.NewWriter(out)
+	sources, err := newSources(cfg, logger)
+	if err != nil {
+		return err
+	}
+	finish := make(chan error, 1)
+	conds := newExitConditions(cfg, finish, logger)
+	defer conds.deadlines.stop()
+	if cfg.SpillDir != "" {
+		if info, err := os.Stat(cfg.SpillDir); err != nil || !info.IsDir() {
+			return configError{err: fmt.Errorf("invalid --spill-dir: %s is not a directory", cfg.SpillDir)}
+		}
+	}
+	split, err := newOutputWriter(cfg)
+	if err != nil {
+		return err
+	}
+	buffer := newCaptureBuffer(cfg.SpillDir)
+	buffer.keepLast = cfg.KeepLast
+	buffer.keepBytes = cfg.KeepLastBytes
+	capture := &captureStage{cfg: cfg, buffer: buffer, logger: logger, finish: finish, result: conds.result}
+	stopDumps := capture.dumpOnSignal(stdout)
+	defer capture.buffer.close()
 
-	err := runWithTimeout(ctx, cfg.Timeout, func(runCtx context.Context) error {
-		return client.Run(runCtx, func(msg message.EventMessage) error {
-			if !eventAllowed(cfg.Events, msg.Event) {
-				return nil
-			}
-			encoded, err := json.Marshal(msg)
-			if err != nil {
-				if logger != nil {
-					logger.Printf("failed to encode event: %v", err)
-				}
-				return nil
-			}
-			buffer = append(buffer, encoded)
-			bufferBytes += int64(len(encoded))
-			if !warned && bufferBytes >= warnBufferBytes {
-				if logger != nil {
-					logger.Printf("capture buffer exceeded 100MB")
-				}
-				warned = true
-			}
-			if bufferBytes >= maxBufferBytes {
-				return fatalError{err: fmt.Errorf("capture buffer exceeded 500MB")}
-			}
+	stages, err := pipeline(cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.StatsInterval > 0 {
+		stats := newThroughput(sources, statsLogger(logger))
+		stats.buffer = capture.usage
+		stages = append([]Middleware{stats.count}, stages...)
+		stages = append(stages, stats.pass)
+		defer stats.start(cfg.StatsInterval)()
+	}
+	if cfg.StatsD != "" {
+		statsd, err := newStatsdReporter(cfg.StatsD, cfg.StatsDTags, "gh_pulse.capture", sources)
+		if err != nil {
+			return err
+		}
+		stages = append([]Middleware{statsd.count}, stages...)
+		stages = append(stages, statsd.pass)
+		defer statsd.start()()
+	}
+	stages = append(stages, capture.middleware)
+	handler := Chain(assertHandler(conds), stages...)
+	if cfg.Ready || cfg.ReadyFile != "" {
+		ready := newReadySignal(cfg, stdout, logger)
+		ready.mu = &capture.mu
+		ready.watch(sources)
+	}
 
-			if matchesAssertions(encoded, cfg.SuccessAssertions) {
-				return exitError{code: 0}
-			}
-			if matchesAssertions(encoded, cfg.FailureAssertions) {
-				return exitError{code: 1}
-			}
-			return nil
-		})
+	err = runWithTimeout(ctx, cfg.Timeout, finish, func(runCtx context.Context) error {
+		return runSources(runCtx, sources, handler)
 	})
+	stopDumps()
+	err = conds.finish(err)
+	var timeoutErr exitError
+	if capture.triggered && errors.As(err, &timeoutErr) && timeoutErr.code == 124 {
+		err = exitError{code: 0}
+	}
+	dumped := false
 	if err != nil {
+		// Partial captures are still useful, so the buffer is also dumped
+		// when the run is interrupted or fails fatally.
 		var exitErr interface{ ExitCode() int }
-		if errors.As(err, &exitErr) {
-			if dumpErr := dumpBuffer(stdout, buffer); dumpErr != nil {
+		var fatalErr fatalError
+		if errors.As(err, &exitErr) || errors.As(err, &fatalErr) || errors.Is(err, context.Canceled) {
+			dumped = true
+			if dumpErr := capture.buffer.dumpTo(stdout, split); dumpErr != nil && exitCode(dumpErr) != 0 {
 				return dumpErr
 			}
-			return err
 		}
-		var fatalErr fatalError
-		if errors.As(err, &fatalErr) {
-			return fatalErr
+	}
+	if split != nil && !dumped {
+		if closeErr := split.close(); closeErr != nil && exitCode(err) == 0 {
+			err = closeErr
 		}
 	}
+	if reportErr := conds.report(cfg, stdout, "gh-pulse capture", err); reportErr != nil {
+		return reportErr
+	}
 	return err
 }
 
@@ -189,130 +597,253 @@ func (e fatalError) Unwrap() error {
 	return e.err
 }
 
-func runWithTimeout(ctx context.Context, timeout time.Duration, run func(context.Context) error) error {
-	runCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// exitConditions holds the state behind the exit assertions of one run.
+type exitConditions struct {
+	settle    *settler
+	all       *allOf
+	until     *untilTracker
+	deadlines *deadlineTracker
+	responses *responseTracker
+	result    *runResult
+	failure   []assertion.Assertion
+	success   []assertion.Assertion
+	// settleCancel matches the events that cancel a pending settle period.
+	settleCancel []assertion.Assertion
+	logger       *log.Logger
+}
 
-	done := make(chan error, 1)
-	go func() {
-		done <- run(runCtx)
-	}()
+func newExitConditions(cfg Config, finish chan<- error, logger *log.Logger) *exitConditions {
+	result := newRunResult(cfg.OutputVars)
+	return &exitConditions{
+		settle:       newSettler(cfg.Settle, cfg.Grace, finish),
+		all:          newAllOf(cfg.SuccessAll, result, logger),
+		until:        newUntilTracker(cfg.Until, cfg.UntilInputs, result, logger),
+		deadlines:    newDeadlineTracker(cfg.Deadlines, finish, result, logger),
+		responses:    newResponseTracker(cfg.SuccessOnResponse, cfg.FailureOnResponse, finish, result),
+		result:       result,
+		failure:      cfg.FailureAssertions,
+		success:      cfg.SuccessAssertions,
+		settleCancel: cfg.SettleCancel,
+		logger:       logger,
+	}
+}
 
-	var timeoutCh <-chan time.Time
-	var timer *time.Timer
-	if timeout > 0 {
-		timer = time.NewTimer(timeout)
-		defer timer.Stop()
-		timeoutCh = timer.C
+// evaluate decides whether an emitted event ends the run. While a settle or
+// grace period is pending, events only extend it unless a failure matches or
+// they cancel it, which takes back the success it was holding.
+func (c *exitConditions) evaluate(doc assertion.Document, at time.Time) error {
+	if c.settle.pending() {
+		if c.failed(doc) {
+			return exitError{code: 1}
+		}
+		if _, ok := firstMatch(doc, c.settleCancel); ok {
+			c.settle.cancel()
+			c.result.retractSuccess()
+			return nil
+		}
+		c.settle.reset()
+		return nil
 	}
+	allMet := c.all.observe(doc)
+	untilMet := c.until.observe(doc, at)
+	rule := ""
+	switch {
+	case allMet:
+		rule = c.all.String()
+	case untilMet:
+		rule = c.until.met
+	default:
+		matched, ok := firstMatch(doc, c.success)
+		if !ok {
+			if c.failed(doc) {
+				return exitError{code: 1}
+			}
+			return nil
+		}
+		rule = matched.String()
+		c.result.match("success-on", rule, c.result.ref(doc), "")
+	}
+	c.result.note(0, "success", rule, c.result.ref(doc))
+	if c.settle.enabled() {
+		c.settle.reset()
+		return nil
+	}
+	return exitError{code: 0}
+}
 
-	select {
-	case <-ctx.Done():
-		cancel()
-		<-done
-		return ctx.Err()
-	case err := <-done:
-		return err
-	case <-timeoutCh:
-		cancel()
-		<-done
-		return exitError{code: 124}
+// failed reports whether a failure assertion matches, noting it for the
+// result record.
+func (c *exitConditions) failed(doc assertion.Document) bool {
+	matched, ok := firstMatch(doc, c.failure)
+	if ok {
+		c.result.note(1, "failure", matched.String(), c.result.ref(doc))
+		c.result.match("failure-on", matched.String(), c.result.ref(doc), "")
 	}
+	return ok
 }
 
-func dumpBuffer(stdout *bufio.Writer, buffer [][]byte) error {
-	for _, message := range buffer {
-		if _, err := stdout.Write(message); err != nil {
-			return err
+// finish logs unmet conditions and resolves the run's final error once it
+// has ended. When a file runs out of events, a response rule that matched
+// decides, a pending settle or grace period succeeds, and otherwise the run
+// fails if it was waiting for a success condition. A failure response seen
+// while the forwarder drained also fails a run that had succeeded.
+func (c *exitConditions) finish(err error) error {
+	if decided := c.responses.outcome(); decided != nil {
+		var exitErr exitError
+		if errors.Is(err, errEndOfInput) || (errors.As(err, &exitErr) && exitErr.code == 0 && exitCode(decided) == 1) {
+			err = decided
 		}
-		if err := stdout.WriteByte('\n'); err != nil {
-			return err
+	}
+	if errors.Is(err, errEndOfInput) {
+		switch {
+		case c.settle.pending():
+			err = exitError{code: 0}
+		case len(c.success) > 0 || c.all.enabled() || len(c.until.exprs) > 0 || len(c.responses.success) > 0:
+			if c.logger != nil {
+				c.logger.Printf("input ended before a success condition was met")
+			}
+			err = exitError{code: 1}
+		default:
+			err = nil
 		}
 	}
-	return stdout.Flush()
+	c.all.logUnmet(err)
+	return c.settle.result(err)
 }
 
-func matchesAssertions(message []byte, assertions []assertion.Assertion) bool {
-	if len(assertions) == 0 {
-		return false
+// report writes the --result record, the GitHub Actions outputs, and the
+// --report file once the run has ended with err.
+func (c *exitConditions) report(cfg Config, stdout *bufio.Writer, suite string, err error) error {
+	if cfg.Result {
+		if writeErr := c.result.write(stdout, err); writeErr != nil {
+			return writeErr
+		}
 	}
-
-	var payload interface{}
-	if err := json.Unmarshal(message, &payload); err != nil {
-		return false
+	if cfg.GitHubOutput != "" {
+		if writeErr := c.result.writeGitHubOutput(cfg.GitHubOutput, err); writeErr != nil {
+			return writeErr
+		}
 	}
+	if cfg.JUnitReport != "" {
+		return c.result.writeJUnit(cfg.JUnitReport, suite, cfg, err)
+	}
+	return nil
+}
 
-	for _, rule := range assertions {
-		value, ok := valueAtPath(payload, rule.Path)
-		switch rule.Operator {
-		case "exists":
-			if ok {
-				return true
-			}
-		case "eq":
-			if ok && stringifyJSON(value) == rule.Value {
-				return true
-			}
-		case "regex":
-			if ok {
-				re, err := regexp.Compile(rule.Value)
-				if err != nil {
-					continue
-				}
-				if re.MatchString(stringifyJSON(value)) {
-					return true
-				}
-			}
-		}
+// settler delays a successful exit until no further events have arrived for
+// the settle duration (--settle) and at least the grace period has passed
+// since the first success (--grace).
+type settler struct {
+	duration time.Duration
+	grace    time.Duration
+	finish   chan<- error
+	mu       sync.Mutex
+	timer    *time.Timer
+	graceEnd time.Time
+}
+
+func newSettler(duration, grace time.Duration, finish chan<- error) *settler {
+	return &settler{duration: duration, grace: grace, finish: finish}
+}
+
+func (s *settler) enabled() bool {
+	return s.duration > 0 || s.grace > 0
+}
+
+func (s *settler) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timer != nil
+}
+
+// reset starts the settle timer, or restarts it if it is already running.
+// The timer never fires before the grace period ends.
+func (s *settler) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.timer == nil {
+		s.graceEnd = now.Add(s.grace)
+	}
+	wait := s.duration
+	if remaining := s.graceEnd.Sub(now); remaining > wait {
+		wait = remaining
 	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(wait, func() {
+			finishWith(s.finish, exitError{code: 0})
+		})
+		return
+	}
+	s.timer.Reset(wait)
+}
 
-	return false
+// cancel stops a pending settle period.
+func (s *settler) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
 }
 
-func valueAtPath(payload interface{}, path string) (interface{}, bool) {
-	current := payload
-	for _, part := range strings.Split(path, ".") {
-		switch node := current.(type) {
-		case map[string]interface{}:
-			child, ok := node[part]
-			if !ok {
-				return nil, false
-			}
-			current = child
-		case []interface{}:
-			idx, err := strconv.Atoi(part)
-			if err != nil || idx < 0 || idx >= len(node) {
-				return nil, false
-			}
-			current = node[idx]
-		default:
-			return nil, false
-		}
+// result keeps a matched success from being reported as a timeout when the
+// global timeout expires mid-settle or mid-grace.
+func (s *settler) result(err error) error {
+	if !s.pending() {
+		return err
+	}
+	s.mu.Lock()
+	s.timer.Stop()
+	s.mu.Unlock()
+	var exitErr exitError
+	if errors.As(err, &exitErr) && exitErr.code == 124 {
+		return exitError{code: 0}
 	}
-	return current, true
+	return err
 }
 
-func stringifyJSON(value interface{}) string {
-	switch v := value.(type) {
-	case string:
-		return v
+// finishWith ends a run from outside the event handler, e.g. from a timer.
+// Only the first call has an effect.
+func finishWith(finish chan<- error, err error) {
+	select {
+	case finish <- err:
 	default:
-		encoded, err := json.Marshal(v)
-		if err != nil {
-			return fmt.Sprint(v)
-		}
-		return string(encoded)
 	}
 }
 
-func eventAllowed(events []string, candidate string) bool {
-	if len(events) == 0 {
-		return true
+func runWithTimeout(ctx context.Context, timeout time.Duration, finish <-chan error, run func(context.Context) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(runCtx)
+	}()
+
+	var timeoutCh <-chan time.Time
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
 	}
-	for _, event := range events {
-		if event == candidate {
-			return true
-		}
+
+	select {
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	case err := <-done:
+		return err
+	case <-timeoutCh:
+		cancel()
+		<-done
+		return exitError{code: 124}
+	case err := <-finish:
+		cancel()
+		<-done
+		return err
 	}
-	return false
 }
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+)
+
+// allOf tracks assertions that must each match some event, in any order,
+// before the run succeeds (--success-on-all).
+type allOf struct {
+	conditions []assertion.Assertion
+	met        []bool
+	remaining  int
+	result     *runResult
+	logger     *log.Logger
+}
+
+func newAllOf(conditions []assertion.Assertion, result *runResult, logger *log.Logger) *allOf {
+	return &allOf{
+		conditions: conditions,
+		met:        make([]bool, len(conditions)),
+		remaining:  len(conditions),
+		result:     result,
+		logger:     logger,
+	}
+}
+
+func (a *allOf) enabled() bool {
+	return len(a.conditions) > 0
+}
+
+// observe records which conditions the event matches and reports whether
+// every condition has now been met.
+func (a *allOf) observe(doc assertion.Document) bool {
+	if !a.enabled() {
+		return false
+	}
+	for i := range a.conditions {
+		if a.met[i] || !doc.MatchAny(a.conditions[i:i+1]) {
+			continue
+		}
+		a.met[i] = true
+		a.remaining--
+		a.result.match("success-on-all", a.conditions[i].String(), a.result.ref(doc), "")
+		if a.logger != nil {
+			a.logger.Printf("condition met (%d/%d): %s", len(a.conditions)-a.remaining, len(a.conditions), a.conditions[i])
+		}
+	}
+	return a.remaining == 0
+}
+
+// String lists the conditions, for the result record.
+func (a *allOf) String() string {
+	parts := make([]string, len(a.conditions))
+	for i, condition := range a.conditions {
+		parts[i] = condition.String()
+	}
+	return strings.Join(parts, " && ")
+}
+
+// logUnmet lists the conditions still outstanding when the run times out.
+func (a *allOf) logUnmet(err error) {
+	var exitErr exitError
+	if a.logger == nil || a.remaining == 0 || !errors.As(err, &exitErr) || exitErr.code != 124 {
+		return
+	}
+	for i, condition := range a.conditions {
+		if !a.met[i] {
+			a.logger.Printf("timed out waiting for: %s", condition)
+		}
+	}
+}
+
+// untilTracker evaluates --until expressions against every event and
+// reports when one of them holds.
+type untilTracker struct {
+	exprs  []*assertion.Expr
+	inputs []string
+	result *runResult
+	logger *log.Logger
+	// met is the first expression that held.
+	met string
+}
+
+func newUntilTracker(exprs []*assertion.Expr, inputs []string, result *runResult, logger *log.Logger) *untilTracker {
+	return &untilTracker{exprs: exprs, inputs: inputs, result: result, logger: logger}
+}
+
+// observe feeds the event to every expression, so counts stay accurate even
+// after one of them holds, and reports whether any holds.
+func (u *untilTracker) observe(doc assertion.Document, at time.Time) bool {
+	held := false
+	for i, expr := range u.exprs {
+		if !expr.Observe(doc, at) {
+			continue
+		}
+		input := ""
+		if i < len(u.inputs) {
+			input = u.inputs[i]
+			u.result.match("until", input, u.result.ref(doc), "")
+		}
+		if !held {
+			held = true
+			u.met = input
+			if u.logger != nil && input != "" {
+				u.logger.Printf("until condition met: %s", input)
+			}
+		}
+	}
+	return held
+}
+
+// deadlineTracker runs a timer for every event that starts a deadline
+// (--deadline) and fails the run with exit 1 if the matching end event does
+// not arrive in time.
+type deadlineTracker struct {
+	rules   []assertion.Deadline
+	pending []map[string]*time.Timer
+	finish  chan<- error
+	result  *runResult
+	logger  *log.Logger
+	mu      sync.Mutex
+}
+
+func newDeadlineTracker(rules []assertion.Deadline, finish chan<- error, result *runResult, logger *log.Logger) *deadlineTracker {
+	pending := make([]map[string]*time.Timer, len(rules))
+	for i := range pending {
+		pending[i] = make(map[string]*time.Timer)
+	}
+	return &deadlineTracker{rules: rules, pending: pending, finish: finish, result: result, logger: logger}
+}
+
+// observe stops the timers the event ends and starts those it begins. An
+// event whose start is already pending for the same key does not restart it.
+func (t *deadlineTracker) observe(doc assertion.Document) {
+	if len(t.rules) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, rule := range t.rules {
+		key := ""
+		if rule.By != "" {
+			var ok bool
+			if key, ok = doc.Lookup(rule.By); !ok {
+				continue
+			}
+		}
+		if timer, ok := t.pending[i][key]; ok && doc.MatchAny([]assertion.Assertion{rule.End}) {
+			timer.Stop()
+			delete(t.pending[i], key)
+			continue
+		}
+		if _, ok := t.pending[i][key]; ok || !doc.MatchAny([]assertion.Assertion{rule.Start}) {
+			continue
+		}
+		start := t.result.ref(doc)
+		t.pending[i][key] = time.AfterFunc(rule.Within, func() {
+			t.mu.Lock()
+			delete(t.pending[i], key)
+			t.mu.Unlock()
+			if t.logger != nil {
+				if key != "" {
+					t.logger.Printf("deadline missed for %s=%s: %s", rule.By, key, rule)
+				} else {
+					t.logger.Printf("deadline missed: %s", rule)
+				}
+			}
+			t.result.note(1, "deadline", rule.String(), start)
+			detail := "no end event within " + rule.Within.String()
+			if key != "" {
+				detail += " for " + rule.By + "=" + key
+			}
+			t.result.match("deadline", rule.String(), start, detail)
+			finishWith(t.finish, exitError{code: 1})
+		})
+	}
+}
+
+// stop cancels every pending timer once the run is over.
+func (t *deadlineTracker) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, pending := range t.pending {
+		for key, timer := range pending {
+			timer.Stop()
+			delete(pending, key)
+		}
+	}
+}
+
+// responseTracker ends the run on the responses forwarded deliveries get
+// (--success-on-response, --failure-on-response). The first response a rule
+// matches decides, except that a failure overrides an earlier success.
+type responseTracker struct {
+	success []assertion.Assertion
+	failure []assertion.Assertion
+	finish  chan<- error
+	result  *runResult
+	mu      sync.Mutex
+	decided error
+}
+
+func newResponseTracker(success, failure []assertion.Assertion, finish chan<- error, result *runResult) *responseTracker {
+	return &responseTracker{success: success, failure: failure, finish: finish, result: result}
+}
+
+func (t *responseTracker) enabled() bool {
+	return len(t.success) > 0 || len(t.failure) > 0
+}
+
+// observe checks a delivery's outcome against the rules. Route workers call
+// it concurrently.
+func (t *responseTracker) observe(resp forwardResponse) {
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	doc, err := assertion.Decode(encoded)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if exitCode(t.decided) == 1 {
+		return
+	}
+	if matched, ok := firstMatch(doc, t.failure); ok {
+		t.result.note(1, "failure", matched.String(), t.result.ref(doc))
+		t.result.match("failure-on-response", matched.String(), t.result.ref(doc), "")
+		t.decided = exitError{code: 1}
+	} else if matched, ok := firstMatch(doc, t.success); ok && t.decided == nil {
+		t.result.note(0, "success", matched.String(), t.result.ref(doc))
+		t.result.match("success-on-response", matched.String(), t.result.ref(doc), "")
+		t.decided = exitError{code: 0}
+	} else {
+		return
+	}
+	finishWith(t.finish, t.decided)
+}
+
+// outcome returns the exit the responses decided, or nil.
+func (t *responseTracker) outcome() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.decided
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/redact"
+)
+
+// contextLine is an event printed for context rather than because it passed
+// the filters.
+type contextLine struct {
+	message.EventMessage
+	Context bool `json:"context"`
+}
+
+type contextEntry struct {
+	key     string
+	line    []byte
+	written bool
+}
+
+// contextWindow remembers the last events received in stream mode and, when
+// an assertion ends the run, prints the ones the event and action filters
+// dropped ahead of the matching event (--context), like grep -B. Events the
+// sender, signature, or ignore filters reject are never shown, and the rest
+// are redacted as the output is.
+type contextWindow struct {
+	size     int
+	redactor *redact.Redactor
+	stdout   *bufio.Writer
+	// split is the output writer, when output doesn't go to stdout.
+	split   outputWriter
+	logger  *log.Logger
+	entries []contextEntry
+}
+
+func newContextWindow(cfg Config, stdout *bufio.Writer, logger *log.Logger) *contextWindow {
+	return &contextWindow{size: cfg.Context, redactor: cfg.Redact, stdout: stdout, logger: logger}
+}
+
+// remember receives the events the event and action filters dropped, once
+// the other filters have passed them.
+func (w *contextWindow) remember(d *Delivery) error {
+	if d.Message.Verified != nil && !*d.Message.Verified {
+		return nil
+	}
+	msg := d.Message
+	if w.redactor != nil {
+		var err error
+		if msg, _, err = w.redactor.Message(msg); err != nil {
+			return nil
+		}
+	}
+	line, err := json.Marshal(contextLine{EventMessage: msg, Context: true})
+	if err != nil {
+		if w.logger != nil {
+			w.logger.Printf("failed to encode event: %v", err)
+		}
+		return nil
+	}
+	key := msg.Event
+	if w.split != nil {
+		key = w.split.key(&Delivery{Message: msg})
+	}
+	w.add(contextEntry{key: key, line: line})
+	return nil
+}
+
+// hold runs just ahead of the sink and writes each delivery's output itself,
+// after the assertions have seen it, so the context for a match is written
+// before the match.
+func (w *contextWindow) hold(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		lines, err := d.Output()
+		if err != nil {
+			return next.Handle(d)
+		}
+		key := d.Message.Event
+		if w.split != nil {
+			key = w.split.key(d)
+		}
+		d.SetOutput(nil)
+		err = next.Handle(d)
+
+		var exitErr exitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return err
+		}
+		if err != nil && (exitErr.code == 0 || exitErr.code == 1) {
+			if writeErr := w.flush(); writeErr != nil {
+				return writeErr
+			}
+		}
+		for _, line := range lines {
+			if writeErr := w.write(key, line); writeErr != nil {
+				return writeErr
+			}
+		}
+		if writeErr := w.flushOutput(); writeErr != nil {
+			return writeErr
+		}
+		w.add(contextEntry{written: true})
+		return err
+	})
+}
+
+func (w *contextWindow) add(entry contextEntry) {
+	w.entries = append(w.entries, entry)
+	if len(w.entries) > w.size {
+		w.entries = w.entries[len(w.entries)-w.size:]
+	}
+}
+
+// flush writes the remembered events that were not already written, oldest
+// first.
+func (w *contextWindow) flush() error {
+	for _, entry := range w.entries {
+		if entry.written {
+			continue
+		}
+		if err := w.write(entry.key, entry.line); err != nil {
+			return err
+		}
+	}
+	w.entries = nil
+	return nil
+}
+
+func (w *contextWindow) write(key string, line []byte) error {
+	if w.split != nil {
+		return w.split.write(key, line)
+	}
+	if _, err := w.stdout.Write(line); err != nil {
+		return err
+	}
+	return w.stdout.WriteByte('\n')
+}
+
+func (w *contextWindow) flushOutput() error {
+	if w.split != nil {
+		return w.split.flush()
+	}
+	return w.stdout.Flush()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package client
+
+import "os"
+
+// notifyDump is a no-op on platforms without SIGUSR1.
+func notifyDump(ch chan<- os.Signal) bool {
+	return false
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ParseRecipients reads --encrypt values, each age:<recipient> with an age
+// X25519 public key (age1...).
+func ParseRecipients(specs []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, spec := range specs {
+		kind, key, ok := strings.Cut(spec, ":")
+		if !ok || kind != "age" {
+			return nil, fmt.Errorf("expected age:<recipient>, got %q", spec)
+		}
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// encryptTo returns a writer that encrypts what is written to w for
+// recipients, or w itself when there are none. Nothing reaches w until the
+// first write, so an output left unused stays empty. Closing the writer
+// writes the last chunk, without which w can't be decrypted; it doesn't
+// close w.
+func encryptTo(w io.Writer, recipients []age.Recipient) io.WriteCloser {
+	if len(recipients) == 0 {
+		return nopWriteCloser{w}
+	}
+	return &encryptWriter{w: w, recipients: recipients}
+}
+
+type encryptWriter struct {
+	w          io.Writer
+	recipients []age.Recipient
+	enc        io.WriteCloser
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.enc == nil {
+		enc, err := age.Encrypt(e.w, e.recipients...)
+		if err != nil {
+			return 0, err
+		}
+		e.enc = enc
+	}
+	return e.enc.Write(p)
+}
+
+func (e *encryptWriter) Close() error {
+	if e.enc == nil {
+		return nil
+	}
+	return e.enc.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/github"
+)
+
+const enrichTimeout = 30 * time.Second
+
+// enrichPayload holds the payload fields used to locate related resources.
+type enrichPayload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	CheckRun struct {
+		ID int64 `json:"id"`
+	} `json:"check_run"`
+	WorkflowRun struct {
+		ID int64 `json:"id"`
+	} `json:"workflow_run"`
+}
+
+// enrichers maps event types to the API lookups attached to them. Each lookup
+// returns the key to store the resource under in the enrichment object.
+var enrichers = map[string]func(ctx context.Context, api *github.Client, p enrichPayload) (string, json.RawMessage, error){
+	"pull_request": func(ctx context.Context, api *github.Client, p enrichPayload) (string, json.RawMessage, error) {
+		// The webhook's mergeable fields are usually null because GitHub
+		// computes them lazily; the API returns the computed values.
+		pr, err := api.Get(ctx, fmt.Sprintf("/repos/%s/pulls/%d", p.Repository.FullName, p.PullRequest.Number))
+		return "pull_request", pr, err
+	},
+	"check_run": func(ctx context.Context, api *github.Client, p enrichPayload) (string, json.RawMessage, error) {
+		annotations, err := api.Get(ctx, fmt.Sprintf("/repos/%s/check-runs/%d/annotations", p.Repository.FullName, p.CheckRun.ID))
+		return "annotations", annotations, err
+	},
+	"workflow_run": func(ctx context.Context, api *github.Client, p enrichPayload) (string, json.RawMessage, error) {
+		raw, err := api.Get(ctx, fmt.Sprintf("/repos/%s/actions/runs/%d/jobs", p.Repository.FullName, p.WorkflowRun.ID))
+		if err != nil {
+			return "jobs", nil, err
+		}
+		var resp struct {
+			Jobs json.RawMessage `json:"jobs"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return "jobs", nil, err
+		}
+		return "jobs", resp.Jobs, nil
+	},
+}
+
+// enrichStage fetches related resources for supported events and attaches
+// them under the envelope's enrichment key. Lookup failures are logged and
+// the event is passed on unenriched.
+func enrichStage(cfg Config, logger *log.Logger) Middleware {
+	api := github.NewClient("", cfg.GitHubToken)
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			lookup, ok := enrichers[d.Message.Event]
+			if !ok {
+				return next.Handle(d)
+			}
+			var payload enrichPayload
+			if err := json.Unmarshal(d.Message.Payload, &payload); err != nil || payload.Repository.FullName == "" {
+				return next.Handle(d)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), enrichTimeout)
+			key, resource, err := lookup(ctx, api, payload)
+			cancel()
+			if err != nil {
+				if logger != nil {
+					logger.Printf("failed to enrich event %s (%s): %v", d.Message.DeliveryID, d.Message.Event, err)
+				}
+				return next.Handle(d)
+			}
+			enrichment, err := json.Marshal(map[string]json.RawMessage{key: resource})
+			if err != nil {
+				return next.Handle(d)
+			}
+			msg := d.Message
+			msg.Enrichment = enrichment
+			d.SetMessage(msg)
+			return next.Handle(d)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// errEndOfInput is returned by a file source that reached the end of its
+// input without following it.
+var errEndOfInput = errors.New("end of input")
+
+// fileSource reads events from a JSONL file written by stream or capture,
+// or from stdin when path is "-". With follow set it keeps polling the file
+// for appended lines, like tail -f, and starts over if it is truncated.
+type fileSource struct {
+	path   string
+	follow bool
+	poll   time.Duration
+	logger *log.Logger
+}
+
+func (s *fileSource) Run(ctx context.Context, handle func(message.EventMessage) error) error {
+	if s.path == "-" {
+		return s.readStdin(ctx, handle)
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return configError{err: err}
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var partial []byte
+	var offset int64
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		offset += int64(len(chunk))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if errors.Is(err, io.EOF) {
+			partial = append(partial, chunk...)
+			if !s.follow {
+				if err := s.handleLine(partial, handle); err != nil {
+					return err
+				}
+				return errEndOfInput
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.poll):
+			}
+			if info, statErr := f.Stat(); statErr == nil && info.Size() < offset {
+				if s.logger != nil {
+					s.logger.Printf("%s was truncated, reading from the start", s.path)
+				}
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				reader.Reset(f)
+				partial, offset = nil, 0
+			}
+			continue
+		}
+		line := append(partial, chunk...)
+		partial = nil
+		if err := s.handleLine(line, handle); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// readStdin reads lines on a separate goroutine, since a read from a pipe
+// cannot be interrupted when the run is cancelled or times out.
+func (s *fileSource) readStdin(ctx context.Context, handle func(message.EventMessage) error) error {
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line := <-lines:
+			if err := s.handleLine(line, handle); err != nil {
+				return err
+			}
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return errEndOfInput
+			}
+			return err
+		}
+	}
+}
+
+// handleLine passes on event lines and skips blank lines and other records.
+func (s *fileSource) handleLine(line []byte, handle func(message.EventMessage) error) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+	var msg message.EventMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		if s.logger != nil {
+			s.logger.Printf("skipping invalid line in %s: %v", s.path, err)
+		}
+		return nil
+	}
+	if msg.Type != "event" {
+		return nil
+	}
+	return handle(msg)
+}
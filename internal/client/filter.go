@@ -0,0 +1,47 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type senderPayload struct {
+	Sender *struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	} `json:"sender"`
+}
+
+// senderAllowed applies the --sender, --exclude-bots, and --only-human
+// filters.
+func senderAllowed(cfg Config, payload json.RawMessage) bool {
+	if len(cfg.Senders) == 0 && !cfg.ExcludeBots && !cfg.OnlyHuman {
+		return true
+	}
+	var decoded senderPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.Sender == nil {
+		return len(cfg.Senders) == 0 && !cfg.OnlyHuman
+	}
+	if len(cfg.Senders) > 0 && !containsFold(cfg.Senders, decoded.Sender.Login) {
+		return false
+	}
+	bot := decoded.Sender.Type == "Bot" || strings.HasSuffix(decoded.Sender.Login, "[bot]")
+	if bot && (cfg.ExcludeBots || cfg.OnlyHuman) {
+		return false
+	}
+	if cfg.OnlyHuman {
+		return decoded.Sender.Type == "User"
+	}
+	return true
+}
+
+// containsFold reports whether candidate is in values, ignoring case as
+// GitHub logins do.
+func containsFold(values []string, candidate string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
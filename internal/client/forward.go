@@ -0,0 +1,391 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/route"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+)
+
+// routeQueueSize is how many events may wait for one route before the
+// stream waits for it to catch up.
+const routeQueueSize = 1000
+
+// drainTimeout is how long stop waits for the routes to deliver the events
+// still queued when the run ends, before it abandons them.
+const drainTimeout = 10 * time.Second
+
+// laneSize is how many events may wait for one lane of a per-key route, so
+// a slow key holds up the keys sharing its lane only once the lane is full.
+const laneSize = 100
+
+// forwarder POSTs events to the routes they match (--routes). Each route has
+// its own queue and worker, so a slow or failing target doesn't hold up the
+// others. A route delivers one event at a time in arrival order, unless its
+// ordering (--ordering) lets deliveries overlap: per key, where events with
+// the same key still arrive in order, or fully in parallel.
+//
+// With a journal (--journal-dir), a route's queue is kept on disk: while its
+// target is unreachable events wait there, without using up their retries,
+// and are delivered in order once it is back, in this run or the next.
+type forwarder struct {
+	routes     []*routeQueue
+	journaled  bool
+	deadLetter *deadLetters
+	// respond, if set, is told how each delivery ended (--emit-responses).
+	respond func(forwardResponse)
+	logger  *log.Logger
+	wg      sync.WaitGroup
+	// cancel abandons the deliveries still in progress or queued.
+	cancel context.CancelFunc
+	// stopping is closed once the stream has ended, so deliveries waiting
+	// on a down target stop waiting and stay in the journal.
+	stopping chan struct{}
+}
+
+type routeQueue struct {
+	route     route.Route
+	http      *http.Client
+	queue     eventQueue
+	delivered atomic.Int64
+	failed    atomic.Int64
+	// dropped counts the events abandoned when the run ended, without a
+	// journal to keep them in.
+	dropped atomic.Int64
+	// down is set while the target is unreachable, so its workers log the
+	// outage once.
+	down atomic.Bool
+}
+
+func newForwarder(cfg Config, logger *log.Logger) (*forwarder, error) {
+	f := &forwarder{journaled: cfg.JournalDir != "", logger: logger, stopping: make(chan struct{}), cancel: func() {}}
+	if f.journaled {
+		if err := os.MkdirAll(cfg.JournalDir, 0o755); err != nil {
+			return nil, configError{err: fmt.Errorf("invalid --journal-dir: %v", err)}
+		}
+		// Each route's journal is named after it, so two routes can't
+		// share a name.
+		names := make(map[string]bool, len(cfg.Routes))
+		for _, r := range cfg.Routes {
+			if names[r.Name] {
+				return nil, configError{err: fmt.Errorf("invalid --journal-dir: more than one route is named %q; give each its own name", r.Name)}
+			}
+			names[r.Name] = true
+		}
+	}
+	for _, r := range cfg.Routes {
+		var queue eventQueue = make(memoryQueue, routeQueueSize)
+		if f.journaled {
+			j, err := openJournal(cfg.JournalDir, r.Name)
+			if err != nil {
+				f.release()
+				return nil, err
+			}
+			if n := j.pending(); n > 0 {
+				f.logf("route %s: resuming %d journaled events", r.Name, n)
+			}
+			queue = j
+		}
+		f.routes = append(f.routes, &routeQueue{
+			route: r,
+			http:  &http.Client{Timeout: r.Timeout},
+			queue: queue,
+		})
+	}
+	if cfg.DeadLetter != "" {
+		file, err := os.OpenFile(cfg.DeadLetter, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			f.release()
+			return nil, configError{err: fmt.Errorf("invalid --dead-letter: %v", err)}
+		}
+		f.deadLetter = &deadLetters{file: file}
+	}
+	return f, nil
+}
+
+// start runs each route's workers until stop. Retries are abandoned once
+// ctx is done.
+func (f *forwarder) start(ctx context.Context) {
+	ctx, f.cancel = context.WithCancel(ctx)
+	for _, q := range f.routes {
+		f.startRoute(ctx, q)
+	}
+}
+
+// startRoute hands the route's queued events to its workers over lanes: one
+// lane and worker for a serial route, Concurrency lanes with a worker each
+// for a per-key route, events spread over them by key, and one lane shared
+// by Concurrency workers for a parallel route.
+func (f *forwarder) startRoute(ctx context.Context, q *routeQueue) {
+	ordering := q.route.Ordering
+	lanes, workers := 1, 1
+	switch ordering.Mode {
+	case route.PerKey:
+		lanes = ordering.Concurrency
+	case route.Parallel:
+		workers = ordering.Concurrency
+	}
+	queues := make([]chan queuedEvent, lanes)
+	for i := range queues {
+		queues[i] = make(chan queuedEvent, laneSize)
+		for range workers {
+			f.wg.Add(1)
+			go func() {
+				defer f.wg.Done()
+				f.work(ctx, q, queues[i])
+			}()
+		}
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for {
+			e, ok := q.queue.next()
+			if !ok {
+				break
+			}
+			lane := 0
+			if lanes > 1 {
+				lane = laneFor(q.route, e.msg, lanes)
+			}
+			queues[lane] <- e
+		}
+		for _, lane := range queues {
+			close(lane)
+		}
+	}()
+}
+
+// laneFor picks the lane of a per-key route that msg's key is delivered on.
+func laneFor(r route.Route, msg message.EventMessage, lanes int) int {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(r.OrderKey(data)))
+	return int(h.Sum32() % uint32(lanes))
+}
+
+func (f *forwarder) work(ctx context.Context, q *routeQueue, lane <-chan queuedEvent) {
+	abandoned := false
+	for e := range lane {
+		if abandoned {
+			continue
+		}
+		if !f.deliver(ctx, q, e.msg) && f.journaled {
+			// This and the rest of the lane stay in the journal for the
+			// next run.
+			abandoned = true
+			continue
+		}
+		if err := q.queue.done(e); err != nil {
+			f.logf("route %s: %v", q.route.Name, err)
+		}
+	}
+}
+
+// stage queues each delivery for the routes it matches, then passes it on.
+func (f *forwarder) stage(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		doc, err := d.Document()
+		if err != nil {
+			return next.Handle(d)
+		}
+		for _, q := range f.routes {
+			if q.route.Matches(d.Message.Event, doc) {
+				if err := q.queue.push(d.Message); err != nil {
+					return err
+				}
+			}
+		}
+		return next.Handle(d)
+	})
+}
+
+// stop waits up to drainTimeout for the queued events to be delivered, or
+// journaled, then abandons the rest, so a down target doesn't hold up the
+// exit, and logs each route's totals.
+func (f *forwarder) stop() {
+	close(f.stopping)
+	for _, q := range f.routes {
+		q.queue.close()
+	}
+	drained := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		f.logf("routes still delivering after %s; abandoning the events left", drainTimeout)
+		f.cancel()
+		<-drained
+	}
+	f.cancel()
+	for _, q := range f.routes {
+		switch {
+		case f.journaled:
+			f.logf("route %s: %d delivered, %d failed, %d left in journal", q.route.Name, q.delivered.Load(), q.failed.Load(), q.queue.pending())
+		case q.dropped.Load() > 0:
+			f.logf("route %s: %d delivered, %d failed, %d dropped", q.route.Name, q.delivered.Load(), q.failed.Load(), q.dropped.Load())
+		default:
+			f.logf("route %s: %d delivered, %d failed", q.route.Name, q.delivered.Load(), q.failed.Load())
+		}
+	}
+	f.release()
+}
+
+func (f *forwarder) release() {
+	for _, q := range f.routes {
+		if err := q.queue.release(); err != nil {
+			f.logf("route %s: %v", q.route.Name, err)
+		}
+	}
+	if f.deadLetter != nil {
+		if err := f.deadLetter.file.Close(); err != nil {
+			f.logf("failed to close dead letter file: %v", err)
+		}
+	}
+}
+
+// deliver sends msg to the route's target, retrying as its policy allows. It
+// returns false when the delivery was abandoned instead of finished: the run
+// ended first, or, with a journal, it did while the target was down.
+func (f *forwarder) deliver(ctx context.Context, q *routeQueue, msg message.EventMessage) bool {
+	retry := q.route.Retry
+	attempt, down, tries := 0, 0, 0
+	for {
+		if ctx.Err() != nil {
+			if !f.journaled {
+				q.dropped.Add(1)
+			}
+			return false
+		}
+		start := time.Now()
+		status, err := webhook.Post(ctx, q.http, q.route.Target, msg, q.route.Secret)
+		latency := time.Since(start)
+		tries++
+		if err != nil && ctx.Err() != nil {
+			// Abandoned mid-request; not the target's fault.
+			continue
+		}
+		if err == nil && status >= 200 && status <= 299 {
+			if q.down.CompareAndSwap(true, false) {
+				f.logf("route %s: target is back, delivering journaled events", q.route.Name)
+			}
+			q.delivered.Add(1)
+			f.responded(q.route, msg, status, nil, latency, tries)
+			return true
+		}
+		if err != nil && f.journaled {
+			select {
+			case <-f.stopping:
+				return false
+			default:
+			}
+			down++
+			if q.down.CompareAndSwap(false, true) {
+				f.logf("route %s: target unreachable (%s); journaling events until it is back", q.route.Name, deliveryError(status, err))
+			}
+			select {
+			case <-ctx.Done():
+			case <-f.stopping:
+			case <-time.After(retry.Delay(down)):
+			}
+			continue
+		}
+		attempt++
+		if !route.Retryable(status, err) || attempt >= retry.Attempts {
+			q.failed.Add(1)
+			reason := deliveryError(status, err)
+			f.logf("route %s: giving up on %s (%s): %s (attempt %d of %d)", q.route.Name, msg.DeliveryID, msg.Event, reason, attempt, retry.Attempts)
+			if f.deadLetter != nil {
+				if err := f.deadLetter.write(q.route, msg, reason, attempt); err != nil {
+					f.logf("route %s: failed to write dead letter: %v", q.route.Name, err)
+				}
+			}
+			f.responded(q.route, msg, status, err, latency, tries)
+			return true
+		}
+		delay := retry.Delay(attempt)
+		f.logf("route %s: %s (%s) failed: %s; retrying in %s", q.route.Name, msg.DeliveryID, msg.Event, deliveryError(status, err), delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+}
+
+// responded reports how a delivery ended to respond, if it is set.
+func (f *forwarder) responded(r route.Route, msg message.EventMessage, status int, err error, latency time.Duration, tries int) {
+	if f.respond != nil {
+		f.respond(newForwardResponse(r, msg, status, err, latency, tries))
+	}
+}
+
+func (f *forwarder) logf(format string, args ...any) {
+	if f.logger != nil {
+		f.logger.Printf(format, args...)
+	}
+}
+
+// deadLetters appends the events routes gave up on to a JSONL file
+// (--dead-letter). Each line is the event with a "dead_letter" field saying
+// why, so the file can be replayed once the cause is fixed.
+type deadLetters struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+type deadLetter struct {
+	message.EventMessage
+	DeadLetter deadLetterInfo `json:"dead_letter"`
+}
+
+type deadLetterInfo struct {
+	Route    string    `json:"route"`
+	Target   string    `json:"target"`
+	Reason   string    `json:"reason"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func (d *deadLetters) write(r route.Route, msg message.EventMessage, reason string, attempts int) error {
+	line, err := json.Marshal(deadLetter{
+		EventMessage: msg,
+		DeadLetter: deadLetterInfo{
+			Route:    r.Name,
+			Target:   r.Target,
+			Reason:   reason,
+			Attempts: attempts,
+			FailedAt: time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err = d.file.Write(append(line, '\n'))
+	return err
+}
+
+// deliveryError describes a failed attempt.
+func deliveryError(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OutputVar names a value read from the event that decided the run, written
+// as a GitHub Actions step output (--output-var).
+type OutputVar struct {
+	Name string
+	Path string
+}
+
+// writeGitHubOutput appends the run's outcome to the GITHUB_OUTPUT file so
+// later workflow steps can read it as steps.<id>.outputs.<name>.
+func (r *runResult) writeGitHubOutput(path string, err error) error {
+	record, ok := r.outcome(err)
+	if !ok {
+		return nil
+	}
+	var b strings.Builder
+	writeOutput(&b, "exit_code", strconv.Itoa(record.ExitCode))
+	writeOutput(&b, "reason", record.Reason)
+	writeOutput(&b, "rule", record.Rule)
+	writeOutput(&b, "delivery_id", record.DeliveryID)
+	writeOutput(&b, "event", record.Event)
+	for _, v := range r.outputVars {
+		writeOutput(&b, v.Name, record.vars[v.Name])
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write GITHUB_OUTPUT: %w", err)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write GITHUB_OUTPUT: %w", err)
+	}
+	return f.Close()
+}
+
+// writeOutput writes name=value, or the heredoc form for multi-line values.
+func writeOutput(b *strings.Builder, name, value string) {
+	if !strings.ContainsAny(value, "\r\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, value)
+		return
+	}
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	delimiter := "ghadelimiter_" + hex.EncodeToString(suffix)
+	fmt.Fprintf(b, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+)
+
+// groupRecord is the {"type":"group"} line written for each group of events
+// sharing a --group-by value.
+type groupRecord struct {
+	Type   string         `json:"type"`
+	By     string         `json:"by"`
+	Key    string         `json:"key"`
+	Events int            `json:"events"`
+	Counts map[string]int `json:"counts"`
+	First  time.Time      `json:"first_at"`
+	Last   time.Time      `json:"last_at"`
+	// State is the latest state the events reported: open, closed, or
+	// merged for pull requests, a status or conclusion for checks, runs,
+	// deployments, and commit statuses.
+	State string `json:"state,omitempty"`
+	// Terminal is set when the group ended with a final state, rather than
+	// being flushed when the run ended.
+	Terminal bool `json:"terminal"`
+}
+
+// groups buffers a summary per value of the group paths, writing each one
+// when its events reach a terminal state and the rest when the run ends.
+type groups struct {
+	by     []string
+	mu     sync.Mutex
+	open   map[string]*groupRecord
+	logger *log.Logger
+}
+
+func newGroups(by []string, logger *log.Logger) *groups {
+	return &groups{by: by, open: make(map[string]*groupRecord), logger: logger}
+}
+
+// stage folds each delivery into its group in place of its own output, and
+// outputs the group's record instead once it is terminal. The first group
+// path with a value names the group; events with none are left out.
+func (g *groups) stage(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		doc, err := d.Document()
+		if err != nil {
+			return nil
+		}
+		by, key := g.key(doc)
+		if key == "" {
+			d.SetOutput(nil)
+			return next.Handle(d)
+		}
+		record := g.add(by, key, doc, d.ReceivedAt)
+		if record == nil {
+			d.SetOutput(nil)
+			return next.Handle(d)
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		d.SetOutput([][]byte{line})
+		return next.Handle(d)
+	})
+}
+
+// key returns the first group path with a value in doc, and the value.
+func (g *groups) key(doc assertion.Document) (string, string) {
+	for _, by := range g.by {
+		if key := lookupString(doc, by); key != "" {
+			return by, key
+		}
+	}
+	return "", ""
+}
+
+// add records an event, returning the group's record if the event ended it.
+func (g *groups) add(by, key string, doc assertion.Document, at time.Time) *groupRecord {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	record, ok := g.open[key]
+	if !ok {
+		record = &groupRecord{Type: "group", By: by, Key: key, Counts: make(map[string]int), First: at}
+		g.open[key] = record
+	}
+	record.Events++
+	name := lookupString(doc, "event")
+	if action := lookupString(doc, "payload.action"); action != "" {
+		name += "." + action
+	}
+	record.Counts[name]++
+	if at.Before(record.First) {
+		record.First = at
+	}
+	if at.After(record.Last) {
+		record.Last = at
+	}
+	state, terminal := groupState(doc)
+	if state != "" {
+		record.State = state
+	}
+	if !terminal {
+		return nil
+	}
+	record.Terminal = true
+	delete(g.open, key)
+	return record
+}
+
+// flush writes the records of the groups still open, oldest first.
+func (g *groups) flush(stdout *bufio.Writer) error {
+	g.mu.Lock()
+	records := make([]*groupRecord, 0, len(g.open))
+	for _, record := range g.open {
+		records = append(records, record)
+	}
+	g.open = make(map[string]*groupRecord)
+	g.mu.Unlock()
+	sort.Slice(records, func(i, j int) bool { return records[i].First.Before(records[j].First) })
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := stdout.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return stdout.Flush()
+}
+
+// groupState returns the state an event reports for the thing it is about,
+// and whether that state is final: a closed or merged pull request, or a
+// completed check suite or workflow run.
+func groupState(doc assertion.Document) (string, bool) {
+	switch lookupString(doc, "event") {
+	case "pull_request":
+		switch action := lookupString(doc, "payload.action"); action {
+		case "closed":
+			if lookupString(doc, "payload.pull_request.merged") == "true" {
+				return "merged", true
+			}
+			return "closed", true
+		case "opened", "reopened":
+			return "open", false
+		}
+	case "check_suite", "workflow_run":
+		object := "payload." + lookupString(doc, "event")
+		status := lookupString(doc, object+".status")
+		if status == "completed" {
+			return lookupString(doc, object+".conclusion"), true
+		}
+		return status, false
+	case "check_run", "workflow_job":
+		object := "payload." + lookupString(doc, "event")
+		if status := lookupString(doc, object+".status"); status != "completed" {
+			return status, false
+		}
+		return lookupString(doc, object+".conclusion"), false
+	case "deployment_status":
+		return lookupString(doc, "payload.deployment_status.state"), false
+	case "status":
+		return lookupString(doc, "payload.state"), false
+	}
+	return "", false
+}
+
+// lookupString returns the value at path, or "" when it is missing or null.
+func lookupString(doc assertion.Document, path string) string {
+	value, ok := doc.Lookup(path)
+	if !ok || value == "null" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/sse"
+)
+
+// heartbeatRecord is the line written every --heartbeat interval.
+type heartbeatRecord struct {
+	Type string    `json:"type"`
+	TS   time.Time `json:"ts"`
+}
+
+// heartbeat writes a heartbeat line to stdout every interval while the
+// stream is up, so consumers can tell a quiet channel from a dead one. No
+// heartbeats are written while the channel is disconnected.
+type heartbeat struct {
+	mu      sync.Mutex
+	stdout  *bufio.Writer
+	sources []source
+	logger  *log.Logger
+}
+
+func newHeartbeat(stdout *bufio.Writer, sources []source, logger *log.Logger) *heartbeat {
+	return &heartbeat{stdout: stdout, sources: sources, logger: logger}
+}
+
+// guard is the outermost stage, so heartbeats never interleave with the
+// lines a delivery writes.
+func (h *heartbeat) guard(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return next.Handle(d)
+	})
+}
+
+// start writes heartbeats until the returned function is called.
+func (h *heartbeat) start(interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				h.beat(now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (h *heartbeat) beat(now time.Time) {
+	if !h.live() {
+		return
+	}
+	line, err := json.Marshal(heartbeatRecord{Type: "heartbeat", TS: now.UTC()})
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, _ = h.stdout.Write(append(line, '\n'))
+	if err := h.stdout.Flush(); err != nil && h.logger != nil {
+		h.logger.Printf("failed to write heartbeat: %v", err)
+	}
+}
+
+// live reports whether some source is delivering: any source that isn't a
+// channel, or a channel that is connected.
+func (h *heartbeat) live() bool {
+	for _, src := range h.sources {
+		client, ok := src.(*sse.Client)
+		if !ok || client.Connected() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// eventQueue holds a route's events until its workers have delivered them.
+type eventQueue interface {
+	push(msg message.EventMessage) error
+	// next blocks until an event is queued and returns it, or false once
+	// the queue is closed and empty. Only one goroutine calls it.
+	next() (queuedEvent, bool)
+	// done removes an event next returned, once it was delivered or
+	// dead-lettered. Events may be done in any order.
+	done(e queuedEvent) error
+	// close stops next from waiting for more events.
+	close()
+	// pending returns how many events have not been done.
+	pending() int
+	release() error
+}
+
+// memoryQueue is the default queue: events are lost if gh-pulse exits
+// before delivering them.
+type memoryQueue chan message.EventMessage
+
+// queuedEvent is an event taken from a queue and, in a journal, the offset
+// just past its line.
+type queuedEvent struct {
+	msg message.EventMessage
+	end int64
+}
+
+func (q memoryQueue) push(msg message.EventMessage) error {
+	q <- msg
+	return nil
+}
+
+func (q memoryQueue) next() (queuedEvent, bool) {
+	msg, ok := <-q
+	return queuedEvent{msg: msg}, ok
+}
+
+func (q memoryQueue) done(queuedEvent) error { return nil }
+func (q memoryQueue) close()                 { close(q) }
+func (q memoryQueue) pending() int           { return len(q) }
+func (q memoryQueue) release() error         { return nil }
+
+// journal is a route's queue kept on disk (--journal-dir), so events the
+// target hasn't accepted survive a restart. Events are appended to
+// <route>-<hash>.jsonl and <route>-<hash>.jsonl.offset records how far
+// delivery has got; once every event is done the journal is emptied. Events done out of order
+// only move the offset once those before them are done, so a restart may
+// deliver some of them again.
+type journal struct {
+	path string
+	file *os.File
+	// reader reads events in order from the undelivered offset; only the
+	// route's worker uses it.
+	reader *bufio.Reader
+	input  *os.File
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	acked int64
+	read  int64
+	// inflight holds the end offsets of the events next returned that are
+	// not done yet, in journal order, and finished those done early.
+	inflight []int64
+	finished map[int64]bool
+	queued   int
+	undone   int
+	closed   bool
+}
+
+func openJournal(dir, name string) (*journal, error) {
+	path := filepath.Join(dir, journalFileName(name))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --journal-dir: %v", err)}
+	}
+	j := &journal{path: path, file: file, finished: make(map[int64]bool)}
+	j.cond = sync.NewCond(&j.mu)
+	if err := j.resume(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// journalFileName is the route name made safe for a file name plus a hash
+// of the name, so names that differ only in the characters replaced, such
+// as a.b and a:b, still get a journal each.
+func journalFileName(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%s-%08x.jsonl", strings.TrimSuffix(splitFileName(name), ".jsonl"), h.Sum32())
+}
+
+// resume counts the events a previous run left undelivered, dropping a
+// last line cut short by a crash.
+func (j *journal) resume() error {
+	if data, err := os.ReadFile(j.path + ".offset"); err == nil {
+		j.acked, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		return err
+	}
+	if j.acked < 0 || j.acked > int64(len(data)) {
+		j.acked = 0
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := j.file.Truncate(int64(complete)); err != nil {
+			return err
+		}
+	}
+	j.queued = bytes.Count(data[j.acked:complete], []byte{'\n'})
+	j.undone = j.queued
+	j.read = j.acked
+	if j.input, err = os.Open(j.path); err != nil {
+		return err
+	}
+	if _, err := j.input.Seek(j.acked, io.SeekStart); err != nil {
+		j.input.Close()
+		return err
+	}
+	j.reader = bufio.NewReader(j.input)
+	return nil
+}
+
+func (j *journal) push(msg message.EventMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal %s: %w", j.path, err)
+	}
+	j.queued++
+	j.undone++
+	j.cond.Signal()
+	return nil
+}
+
+func (j *journal) next() (queuedEvent, bool) {
+	for {
+		j.mu.Lock()
+		for j.queued == 0 && !j.closed {
+			j.cond.Wait()
+		}
+		if j.queued == 0 {
+			j.mu.Unlock()
+			return queuedEvent{}, false
+		}
+		j.queued--
+		j.mu.Unlock()
+
+		line, err := j.reader.ReadBytes('\n')
+		j.read += int64(len(line))
+		if err != nil {
+			return queuedEvent{}, false
+		}
+		e := queuedEvent{end: j.read}
+		j.mu.Lock()
+		j.inflight = append(j.inflight, e.end)
+		j.mu.Unlock()
+		if err := json.Unmarshal(line, &e.msg); err == nil {
+			return e, true
+		}
+		// A line that isn't an event can't be delivered; skip it.
+		if err := j.done(e); err != nil {
+			return queuedEvent{}, false
+		}
+	}
+}
+
+func (j *journal) done(e queuedEvent) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished[e.end] = true
+	for len(j.inflight) > 0 && j.finished[j.inflight[0]] {
+		j.acked = j.inflight[0]
+		delete(j.finished, j.acked)
+		j.inflight = j.inflight[1:]
+	}
+	j.undone--
+	if j.undone == 0 {
+		if err := j.file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := j.input.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		j.reader.Reset(j.input)
+		j.acked, j.read = 0, 0
+	}
+	tmp := j.path + ".offset.tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(j.acked, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path+".offset")
+}
+
+func (j *journal) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.closed = true
+	j.cond.Broadcast()
+}
+
+func (j *journal) pending() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.undone
+}
+
+func (j *journal) release() error {
+	inputErr := j.input.Close()
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	return inputErr
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/itchyny/gojq"
+)
+
+// jqStage replaces each delivery's output with the results of a jq query
+// over the envelope. Queries that produce no values suppress output; the
+// envelope itself is still used for assertions.
+func jqStage(query string, logger *log.Logger) (Middleware, error) {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --jq: %w", err)}
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --jq: %w", err)}
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			lines, err := runJQ(code, d)
+			if err != nil {
+				if logger != nil {
+					logger.Printf("jq failed on event %s: %v", d.Message.DeliveryID, err)
+				}
+				lines = nil
+			}
+			d.SetOutput(lines)
+			return next.Handle(d)
+		})
+	}, nil
+}
+
+func runJQ(code *gojq.Code, d *Delivery) ([][]byte, error) {
+	encoded, err := d.Encoded()
+	if err != nil {
+		return nil, err
+	}
+	var input any
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&input); err != nil {
+		return nil, err
+	}
+
+	lines := [][]byte{}
+	iter := code.Run(input)
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			return lines, nil
+		}
+		if err, ok := value.(error); ok {
+			return nil, err
+		}
+		line, err := gojq.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/filter"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/metrics"
+	"github.com/kehao95/gh-pulse/internal/sse"
+)
+
+type MonitorConfig struct {
+	URL            string
+	Rules          []assertion.Rule
+	ReportInterval time.Duration
+	Pushgateway    string
+	StatsD         string
+	Quiet          bool
+}
+
+// StatusMessage is the JSONL record monitor mode emits for each rule on
+// every report interval.
+type StatusMessage struct {
+	Type           string    `json:"type"`
+	Rule           string    `json:"rule"`
+	Status         string    `json:"status"`
+	Passed         int64     `json:"passed"`
+	Failed         int64     `json:"failed"`
+	TotalPassed    int64     `json:"total_passed"`
+	TotalFailed    int64     `json:"total_failed"`
+	LastDeliveryID string    `json:"last_delivery_id,omitempty"`
+	Timestamp      time.Time `json:"ts"`
+}
+
+type ruleState struct {
+	status         string
+	passed         int64
+	failed         int64
+	totalPassed    int64
+	totalFailed    int64
+	lastDeliveryID string
+}
+
+// RunMonitor evaluates rules against every event without ever exiting on a
+// match, reporting per-rule status until ctx is cancelled.
+func RunMonitor(ctx context.Context, cfg MonitorConfig) error {
+	if err := ValidateURL(cfg.URL); err != nil {
+		return err
+	}
+	var logger *log.Logger
+	if !cfg.Quiet {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	var statsd *metrics.StatsD
+	if cfg.StatsD != "" {
+		var err error
+		statsd, err = metrics.NewStatsD(cfg.StatsD, "gh_pulse.monitor")
+		if err != nil {
+			return configError{err: err}
+		}
+		defer statsd.Close()
+	}
+
+	stdout := bufio.NewWriter(os.Stdout)
+	var mu sync.Mutex
+	states := make([]ruleState, len(cfg.Rules))
+	for i := range states {
+		states[i].status = "pending"
+	}
+
+	report := func() error {
+		mu.Lock()
+		now := time.Now().UTC()
+		statuses := make([]StatusMessage, len(cfg.Rules))
+		for i, rule := range cfg.Rules {
+			state := &states[i]
+			statuses[i] = StatusMessage{
+				Type:           "status",
+				Rule:           rule.Name,
+				Status:         state.status,
+				Passed:         state.passed,
+				Failed:         state.failed,
+				TotalPassed:    state.totalPassed,
+				TotalFailed:    state.totalFailed,
+				LastDeliveryID: state.lastDeliveryID,
+				Timestamp:      now,
+			}
+			state.passed = 0
+			state.failed = 0
+		}
+		mu.Unlock()
+
+		for _, status := range statuses {
+			encoded, err := json.Marshal(status)
+			if err != nil {
+				return err
+			}
+			if _, err := stdout.Write(encoded); err != nil {
+				return err
+			}
+			if err := stdout.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		if err := stdout.Flush(); err != nil {
+			return err
+		}
+		publishStatuses(ctx, cfg, statsd, statuses, logger)
+		return nil
+	}
+
+	client := sse.NewClient(cfg.URL, logger)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(runCtx, func(msg message.EventMessage) error {
+			encoded, err := json.Marshal(msg)
+			if err != nil {
+				if logger != nil {
+					logger.Printf("failed to encode event: %v", err)
+				}
+				return nil
+			}
+			doc, err := assertion.Decode(encoded)
+			if err != nil {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for i, rule := range cfg.Rules {
+				if !filter.Event(rule.Events, msg.Event) {
+					continue
+				}
+				state := &states[i]
+				switch {
+				case doc.MatchAny(rule.Fail):
+					state.status = "fail"
+					state.failed++
+					state.totalFailed++
+					state.lastDeliveryID = msg.DeliveryID
+				case doc.MatchAny(rule.Pass):
+					state.status = "pass"
+					state.passed++
+					state.totalPassed++
+					state.lastDeliveryID = msg.DeliveryID
+				}
+			}
+			return nil
+		})
+	}()
+
+	ticker := time.NewTicker(cfg.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := report(); err != nil {
+				cancel()
+				<-done
+				return err
+			}
+		case err := <-done:
+			if reportErr := report(); reportErr != nil {
+				return reportErr
+			}
+			return err
+		}
+	}
+}
+
+func publishStatuses(ctx context.Context, cfg MonitorConfig, statsd *metrics.StatsD, statuses []StatusMessage, logger *log.Logger) {
+	if statsd != nil {
+		for _, status := range statuses {
+			name := metrics.SanitizeName(status.Rule)
+			_ = statsd.Count(name+".passed", status.Passed)
+			_ = statsd.Count(name+".failed", status.Failed)
+			if value, ok := statusValue(status.Status); ok {
+				_ = statsd.Gauge(name+".status", value)
+			}
+		}
+	}
+	if cfg.Pushgateway != "" {
+		samples := make([]metrics.Sample, 0, len(statuses)*3)
+		for _, status := range statuses {
+			labels := map[string]string{"rule": status.Rule}
+			samples = append(samples,
+				metrics.Sample{Name: "gh_pulse_monitor_passed_total", Type: "counter", Labels: labels, Value: float64(status.TotalPassed)},
+				metrics.Sample{Name: "gh_pulse_monitor_failed_total", Type: "counter", Labels: labels, Value: float64(status.TotalFailed)},
+			)
+			if value, ok := statusValue(status.Status); ok {
+				samples = append(samples, metrics.Sample{Name: "gh_pulse_monitor_status", Type: "gauge", Labels: labels, Value: value})
+			}
+		}
+		pushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := metrics.Push(pushCtx, cfg.Pushgateway, "gh_pulse_monitor", samples); err != nil && logger != nil {
+			logger.Printf("%v", err)
+		}
+	}
+}
+
+// statusValue maps a rule status to a gauge value: 1 for pass, 0 for fail.
+func statusValue(status string) (float64, bool) {
+	switch status {
+	case "pass":
+		return 1, true
+	case "fail":
+		return 0, true
+	default:
+		return 0, false
+	}
+}
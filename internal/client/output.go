@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// clickhouseTable matches a table name, optionally qualified by database.
+var clickhouseTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ParseOutput checks an --output destination, returning its kind and
+// location.
+func ParseOutput(spec string) (kind, location string, err error) {
+	kind, location, ok := strings.Cut(spec, ":")
+	if !ok || location == "" {
+		return "", "", fmt.Errorf("expected kind:location, e.g. sqlite:events.db")
+	}
+	switch kind {
+	case "sqlite":
+		return kind, location, nil
+	case "clickhouse":
+		if _, _, err := clickhouseEndpoint(location); err != nil {
+			return "", "", err
+		}
+		return kind, location, nil
+	default:
+		return "", "", fmt.Errorf("unsupported output %q (supported: sqlite, clickhouse)", kind)
+	}
+}
+
+// openOutput opens the --output destination.
+func openOutput(cfg Config) (outputWriter, error) {
+	kind, location, err := ParseOutput(cfg.Output)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --output: %v", err)}
+	}
+	switch kind {
+	case "sqlite":
+		db, err := newSQLiteWriter(location)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "clickhouse":
+		db, err := newClickHouseWriter(location, cfg.OutputBatchSize, cfg.OutputFlushInterval)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	return nil, nil
+}
+
+// clickhouseEndpoint splits a ClickHouse location such as
+// http://localhost:8123/events into the HTTP endpoint and the table.
+func clickhouseEndpoint(location string) (*url.URL, string, error) {
+	endpoint, err := url.Parse(location)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, "", fmt.Errorf("expected clickhouse:http(s)://host:port/table")
+	}
+	table := strings.Trim(endpoint.Path, "/")
+	if table == "" {
+		table = "github_events"
+	}
+	if !clickhouseTable.MatchString(table) {
+		return nil, "", fmt.Errorf("invalid ClickHouse table %q", table)
+	}
+	endpoint.Path = "/"
+	return endpoint, table, nil
+}
+
+// outputRow is the indexed columns a database output stores for each event,
+// and its payload.
+type outputRow struct {
+	DeliveryID string
+	Event      string
+	Action     string
+	Repo       string
+	ReceivedAt time.Time
+	Payload    json.RawMessage
+}
+
+func decodeRow(line []byte) (outputRow, error) {
+	var msg message.EventMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return outputRow{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	var payload struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	_ = json.Unmarshal(msg.Payload, &payload)
+	return outputRow{
+		DeliveryID: msg.DeliveryID,
+		Event:      msg.Event,
+		Action:     payload.Action,
+		Repo:       payload.Repository.FullName,
+		ReceivedAt: msg.ReceivedAt,
+		Payload:    msg.Payload,
+	}, nil
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/filter"
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// Delivery is a webhook event moving through a handler chain.
+type Delivery struct {
+	Message    message.EventMessage
+	ReceivedAt time.Time
+	encoded    []byte
+	doc        *assertion.Document
+	output     [][]byte
+	hasOutput  bool
+}
+
+// Encoded returns the JSONL encoding of the message. The result is cached
+// until the message is replaced with SetMessage.
+func (d *Delivery) Encoded() ([]byte, error) {
+	if d.encoded != nil {
+		return d.encoded, nil
+	}
+	encoded, err := json.Marshal(d.Message)
+	if err != nil {
+		return nil, err
+	}
+	d.encoded = encoded
+	return encoded, nil
+}
+
+// Document returns the decoded envelope that assertions are evaluated
+// against, cached like Encoded.
+func (d *Delivery) Document() (assertion.Document, error) {
+	if d.doc != nil {
+		return *d.doc, nil
+	}
+	encoded, err := d.Encoded()
+	if err != nil {
+		return assertion.Document{}, err
+	}
+	doc, err := assertion.Decode(encoded)
+	if err != nil {
+		return assertion.Document{}, err
+	}
+	d.doc = &doc
+	return doc, nil
+}
+
+// SetMessage replaces the message, e.g. from a transform stage.
+func (d *Delivery) SetMessage(msg message.EventMessage) {
+	d.Message = msg
+	d.encoded = nil
+	d.doc = nil
+}
+
+// SetOutput overrides the JSON lines sinks write for this delivery without
+// changing the envelope that assertions see. An empty slice suppresses output.
+func (d *Delivery) SetOutput(lines [][]byte) {
+	d.output = lines
+	d.hasOutput = true
+}
+
+// Output returns the JSON lines to write: the override from SetOutput, or the
+// encoded envelope.
+func (d *Delivery) Output() ([][]byte, error) {
+	if d.hasOutput {
+		return d.output, nil
+	}
+	encoded, err := d.Encoded()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{encoded}, nil
+}
+
+// Handler processes a delivery. Returning an error ends the run; exit codes
+// are reported with errors that implement ExitCode() int.
+type Handler interface {
+	Handle(d *Delivery) error
+}
+
+type HandlerFunc func(d *Delivery) error
+
+func (f HandlerFunc) Handle(d *Delivery) error {
+	return f(d)
+}
+
+// Exit returns the error a stage returns to end the run with code, as an exit
+// assertion would: 0 for success, 1 for failure.
+func Exit(code int) error {
+	return exitError{code: code}
+}
+
+// Middleware wraps the rest of a chain with a stage. A stage drops a
+// delivery by returning nil without calling next.
+type Middleware func(next Handler) Handler
+
+// Chain builds a handler that runs stages in order and then final.
+func Chain(final Handler, stages ...Middleware) Handler {
+	handler := final
+	for i := len(stages) - 1; i >= 0; i-- {
+		handler = stages[i](handler)
+	}
+	return handler
+}
+
+// deliver adapts a handler to the SSE client's callback.
+func deliver(handler Handler) func(message.EventMessage) error {
+	return func(msg message.EventMessage) error {
+		receivedAt := msg.ReceivedAt
+		if receivedAt.IsZero() {
+			receivedAt = time.Now()
+		}
+		return handler.Handle(&Delivery{Message: msg, ReceivedAt: receivedAt})
+	}
+}
+
+// pipeline returns the stages every mode runs ahead of its sink: the
+// built-in filters, the size guard, enrichment, redaction, the script,
+// caller-supplied middleware, and output transforms. If unselected is set,
+// it receives the events only the event and action filters dropped.
+func pipeline(cfg Config, logger *log.Logger, unselected Handler) ([]Middleware, error) {
+	stages := []Middleware{filterStage(cfg, logger, unselected)}
+	if cfg.MaxEventSize > 0 {
+		stages = append(stages, sizeStage(cfg.MaxEventSize, cfg.TruncateOversize, logger))
+	}
+	if cfg.Enrich {
+		stages = append(stages, enrichStage(cfg, logger))
+	}
+	if cfg.Redact != nil {
+		stages = append(stages, redactStage(cfg.Redact, logger))
+	}
+	if cfg.Script != "" {
+		stage, err := scriptStage(cfg.Script, logger)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	stages = append(stages, cfg.Middleware...)
+	if cfg.JQ != "" {
+		stage, err := jqStage(cfg.JQ, logger)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// filterStage drops events rejected by the event, action, sender, signature,
+// and ignore filters. Events that pass all but the event and action filters
+// go to unselected, if set.
+func filterStage(cfg Config, logger *log.Logger, unselected Handler) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			selected := filter.Event(cfg.Events, d.Message.Event) && filter.Action(cfg.Actions, d.Message.Payload)
+			if !selected && unselected == nil {
+				return nil
+			}
+			if !senderAllowed(cfg, d.Message.Payload) {
+				return nil
+			}
+			if !verifyEvent(cfg, &d.Message, logger) {
+				return nil
+			}
+			if len(cfg.Ignore) > 0 {
+				doc, err := d.Document()
+				if err != nil {
+					if logger != nil {
+						logger.Printf("failed to encode event: %v", err)
+					}
+					return nil
+				}
+				if doc.MatchAny(cfg.Ignore) {
+					return nil
+				}
+			}
+			if !selected {
+				return unselected.Handle(d)
+			}
+			return next.Handle(d)
+		})
+	}
+}
+
+// writeStage writes each delivery to stdout as a JSON line.
+func writeStage(stdout *bufio.Writer, logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			lines, err := d.Output()
+			if err != nil {
+				if logger != nil {
+					logger.Printf("failed to encode event: %v", err)
+				}
+				return nil
+			}
+			for _, line := range lines {
+				if _, err := stdout.Write(line); err != nil {
+					return err
+				}
+				if err := stdout.WriteByte('\n'); err != nil {
+					return err
+				}
+			}
+			if err := stdout.Flush(); err != nil {
+				return err
+			}
+			return next.Handle(d)
+		})
+	}
+}
+
+// assertHandler ends the chain by evaluating the exit assertions.
+func assertHandler(conds *exitConditions) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		doc, err := d.Document()
+		if err != nil {
+			return nil
+		}
+		conds.deadlines.observe(doc)
+		return conds.evaluate(doc, d.ReceivedAt)
+	})
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pluginProtocol is passed to sink plugins as GH_PULSE_SINK_PROTOCOL, so
+// they can tell which contract they are being run under.
+const pluginProtocol = "1"
+
+// pluginExitWait is how long a failed write waits for the plugin to exit
+// before reporting the write error instead of its exit code.
+const pluginExitWait = 5 * time.Second
+
+// pluginWriter runs a sink plugin (--sink-plugin) and writes output lines
+// to its stdin as JSONL. Closing stdin tells the plugin the run is over.
+//
+// The plugin's exit code decides the run: exiting 0 early ends it
+// successfully, as a reader closing a pipe would, and any other code fails
+// it.
+type pluginWriter struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	buf   *bufio.Writer
+	done  chan struct{}
+	err   error
+}
+
+// newPluginWriter starts command, split on spaces into the binary and its
+// arguments. The plugin's stdout and stderr go to stderr, so gh-pulse's
+// stdout stays JSONL.
+func newPluginWriter(command string) (*pluginWriter, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, configError{err: fmt.Errorf("invalid --sink-plugin: empty command")}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GH_PULSE_SINK_PROTOCOL="+pluginProtocol)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --sink-plugin: %v", err)}
+	}
+	w := &pluginWriter{
+		name:  args[0],
+		cmd:   cmd,
+		stdin: stdin,
+		buf:   bufio.NewWriter(stdin),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		w.err = cmd.Wait()
+		close(w.done)
+	}()
+	return w, nil
+}
+
+func (w *pluginWriter) key(d *Delivery) string {
+	return d.Message.Event
+}
+
+func (w *pluginWriter) write(key string, line []byte) error {
+	if err := w.exited(); err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(line); err != nil {
+		return w.writeFailed(err)
+	}
+	if err := w.buf.WriteByte('\n'); err != nil {
+		return w.writeFailed(err)
+	}
+	return nil
+}
+
+func (w *pluginWriter) flush() error {
+	if err := w.exited(); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return w.writeFailed(err)
+	}
+	return nil
+}
+
+func (w *pluginWriter) close() error {
+	select {
+	case <-w.done:
+	default:
+		flushErr := w.buf.Flush()
+		closeErr := w.stdin.Close()
+		<-w.done
+		if w.err == nil && flushErr != nil && !errors.Is(flushErr, os.ErrClosed) {
+			return fmt.Errorf("sink plugin %s: %w", w.name, errors.Join(flushErr, closeErr))
+		}
+	}
+	return w.result()
+}
+
+// exited reports the plugin's result once it has exited.
+func (w *pluginWriter) exited() error {
+	select {
+	case <-w.done:
+		if err := w.result(); err != nil {
+			return err
+		}
+		return exitError{code: 0}
+	default:
+		return nil
+	}
+}
+
+// writeFailed reports why stdin could not be written: usually the plugin
+// has exited, so its exit code is what matters.
+func (w *pluginWriter) writeFailed(err error) error {
+	select {
+	case <-w.done:
+		return w.exited()
+	case <-time.After(pluginExitWait):
+		return fmt.Errorf("sink plugin %s: %w", w.name, err)
+	}
+}
+
+// result turns the plugin's exit status into the run's error.
+func (w *pluginWriter) result() error {
+	var exitErr *exec.ExitError
+	if errors.As(w.err, &exitErr) {
+		return fmt.Errorf("sink plugin %s exited with code %d", w.name, exitErr.ExitCode())
+	}
+	if w.err != nil {
+		return fmt.Errorf("sink plugin %s: %w", w.name, w.err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// rateLimiter is a token bucket that limits how fast events are written
+// (--max-rate). The bucket holds one second's worth of events, so short
+// bursts under the rate pass without waiting.
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	drop    bool
+	dropped int
+	logger  *log.Logger
+}
+
+func newRateLimiter(rate float64, drop bool, logger *log.Logger) *rateLimiter {
+	burst := max(rate, 1)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, drop: drop, logger: logger}
+}
+
+// stage delays each delivery until the bucket has a token, or with drop
+// passes it on with its output suppressed.
+func (l *rateLimiter) stage(ctx context.Context) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			for {
+				wait := l.take(time.Now())
+				if wait == 0 {
+					break
+				}
+				if l.drop {
+					l.dropped++
+					d.SetOutput(nil)
+					return next.Handle(d)
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+			l.report()
+			return next.Handle(d)
+		})
+	}
+}
+
+// take refills the bucket and spends a token, returning zero, or returns
+// how long until a token is available.
+func (l *rateLimiter) take(now time.Time) time.Duration {
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// report logs how many events were left out of the output since the last
+// report.
+func (l *rateLimiter) report() {
+	if l.dropped > 0 && l.logger != nil {
+		l.logger.Printf("dropped %d events over --max-rate", l.dropped)
+	}
+	l.dropped = 0
+}
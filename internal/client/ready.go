@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/sse"
+)
+
+// readyRecord is the line written once the channel is subscribed.
+type readyRecord struct {
+	Type string    `json:"type"`
+	TS   time.Time `json:"ts"`
+}
+
+// readySignal tells a test harness when it is safe to trigger the action
+// that produces a webhook: once the channel confirms the subscription, it
+// writes a ready line to stdout (--ready) and/or to a file (--ready-file).
+// Runs without a channel are ready as soon as they start.
+type readySignal struct {
+	once   sync.Once
+	stdout *bufio.Writer
+	file   string
+	// mu serializes the stdout write with heartbeats and capture dumps.
+	mu     *sync.Mutex
+	logger *log.Logger
+}
+
+func newReadySignal(cfg Config, stdout *bufio.Writer, logger *log.Logger) *readySignal {
+	r := &readySignal{file: cfg.ReadyFile, mu: &sync.Mutex{}, logger: logger}
+	if cfg.Ready {
+		r.stdout = stdout
+	}
+	return r
+}
+
+// watch signals when the first channel source is subscribed, or right away
+// when there is none.
+func (r *readySignal) watch(sources []source) {
+	for _, src := range sources {
+		if client, ok := src.(*sse.Client); ok {
+			client.OnReady = r.signal
+			return
+		}
+	}
+	r.signal()
+}
+
+func (r *readySignal) signal() {
+	r.once.Do(func() {
+		line, err := json.Marshal(readyRecord{Type: "ready", TS: time.Now().UTC()})
+		if err != nil {
+			return
+		}
+		line = append(line, '\n')
+		if r.logger != nil {
+			r.logger.Printf("subscribed, ready for events")
+		}
+		if r.file != "" {
+			if err := os.WriteFile(r.file, line, 0o644); err != nil && r.logger != nil {
+				r.logger.Printf("failed to write ready file: %v", err)
+			}
+		}
+		if r.stdout != nil {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			_, _ = r.stdout.Write(line)
+			_ = r.stdout.Flush()
+		}
+	})
+}
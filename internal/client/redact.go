@@ -0,0 +1,25 @@
+package client
+
+import (
+	"log"
+
+	"github.com/kehao95/gh-pulse/internal/redact"
+)
+
+// redactStage replaces each delivery's message with its redacted form. An
+// event that cannot be redacted is dropped rather than passed on unscrubbed.
+func redactStage(r *redact.Redactor, logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			msg, _, err := r.Message(d.Message)
+			if err != nil {
+				if logger != nil {
+					logger.Printf("dropping event %s: failed to redact: %v", d.Message.DeliveryID, err)
+				}
+				return nil
+			}
+			d.SetMessage(msg)
+			return next.Handle(d)
+		})
+	}
+}
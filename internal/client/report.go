@@ -0,0 +1,128 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the configured rules as JUnit test cases (--report).
+// Rules that must match (success, until, trigger) pass when they matched,
+// fail when the run timed out first, and are skipped when the run ended for
+// another reason. Rules that must not match (failure, deadline) fail when
+// they matched and pass otherwise.
+func (r *runResult) writeJUnit(path, name string, cfg Config, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ended := time.Now()
+	timedOut := false
+	var exitErr exitError
+	if errors.As(err, &exitErr) && exitErr.code == 124 {
+		timedOut = true
+	}
+
+	suite := junitSuite{Name: name, Time: seconds(ended.Sub(r.started))}
+	expect := func(kind, rule string) {
+		c := junitCase{Name: rule, ClassName: kind}
+		if m, ok := r.matched[ruleKey{kind: kind, rule: rule}]; ok {
+			c.Time = seconds(m.at.Sub(r.started))
+		} else if timedOut {
+			c.Time = seconds(ended.Sub(r.started))
+			c.Failure = &junitMessage{Message: "timed out before it matched", Text: fmt.Sprintf("no match within %s", cfg.Timeout)}
+		} else {
+			c.Time = seconds(ended.Sub(r.started))
+			c.Skipped = &junitMessage{Message: "run ended before it matched"}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	forbid := func(kind, rule string) {
+		c := junitCase{Name: rule, ClassName: kind, Time: seconds(ended.Sub(r.started))}
+		if m, ok := r.matched[ruleKey{kind: kind, rule: rule}]; ok {
+			c.Time = seconds(m.at.Sub(r.started))
+			failure := &junitMessage{Message: "matched", Text: fmt.Sprintf("delivery %s (%s)", m.ref.deliveryID, m.ref.event)}
+			if m.detail != "" {
+				failure.Message = "missed"
+				failure.Text = fmt.Sprintf("%s after %s", m.detail, failure.Text)
+			}
+			c.Failure = failure
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for _, a := range cfg.SuccessAssertions {
+		expect("success-on", a.String())
+	}
+	for _, a := range cfg.SuccessAll {
+		expect("success-on-all", a.String())
+	}
+	for _, input := range cfg.UntilInputs {
+		expect("until", input)
+	}
+	for _, a := range cfg.Trigger {
+		expect("trigger", a.String())
+	}
+	for _, a := range cfg.SuccessOnResponse {
+		expect("success-on-response", a.String())
+	}
+	for _, a := range cfg.FailureAssertions {
+		forbid("failure-on", a.String())
+	}
+	for _, d := range cfg.Deadlines {
+		forbid("deadline", d.String())
+	}
+	for _, a := range cfg.FailureOnResponse {
+		forbid("failure-on-response", a.String())
+	}
+	for _, c := range suite.Cases {
+		suite.Tests++
+		if c.Failure != nil {
+			suite.Failures++
+		}
+		if c.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+
+	encoded, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data := append([]byte(xml.Header), encoded...)
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/route"
+)
+
+// ResponseFields are the fields of a response line, which
+// --success-on-response and --failure-on-response paths start at.
+var ResponseFields = []string{"type", "route", "target", "delivery_id", "event", "status", "error", "latency_ms", "attempts"}
+
+// forwardResponse is how a delivery to a route ended: the target's last
+// response, or the error that kept it from answering. With --emit-responses
+// it is written as a {"type":"response"} line.
+type forwardResponse struct {
+	Type       string `json:"type"`
+	Route      string `json:"route"`
+	Target     string `json:"target"`
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Attempts   int    `json:"attempts"`
+}
+
+func newForwardResponse(r route.Route, msg message.EventMessage, status int, err error, latency time.Duration, attempts int) forwardResponse {
+	resp := forwardResponse{
+		Type:       "response",
+		Route:      r.Name,
+		Target:     r.Target,
+		DeliveryID: msg.DeliveryID,
+		Event:      msg.Event,
+		Status:     status,
+		LatencyMS:  latency.Milliseconds(),
+		Attempts:   attempts,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// responseLog writes response lines to stdout from its own goroutine. Route
+// workers only queue them, so they never wait for the output lock, which a
+// delivery can hold while it waits for room in their queues.
+type responseLog struct {
+	stdout *bufio.Writer
+	// mu serializes the writes with the stream's own output.
+	mu     *sync.Mutex
+	logger *log.Logger
+
+	pendingMu sync.Mutex
+	pending   [][]byte
+	wake      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func newResponseLog(stdout *bufio.Writer, mu *sync.Mutex, logger *log.Logger) *responseLog {
+	l := &responseLog{
+		stdout:  stdout,
+		mu:      mu,
+		logger:  logger,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+func (l *responseLog) add(resp forwardResponse) {
+	line, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	l.pendingMu.Lock()
+	l.pending = append(l.pending, line)
+	l.pendingMu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *responseLog) run() {
+	defer close(l.stopped)
+	for {
+		select {
+		case <-l.wake:
+			l.write()
+		case <-l.stop:
+			l.write()
+			return
+		}
+	}
+}
+
+func (l *responseLog) write() {
+	l.pendingMu.Lock()
+	lines := l.pending
+	l.pending = nil
+	l.pendingMu.Unlock()
+	if len(lines) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range lines {
+		_, _ = l.stdout.Write(append(line, '\n'))
+	}
+	if err := l.stdout.Flush(); err != nil && l.logger != nil {
+		l.logger.Printf("failed to write response: %v", err)
+	}
+}
+
+// close writes the lines still queued, once the forwarder has stopped.
+func (l *responseLog) close() {
+	close(l.stop)
+	<-l.stopped
+}
+
+// lockStage holds mu while the rest of the chain handles a delivery, so
+// lines written from other goroutines never interleave with its output.
+func lockStage(mu *sync.Mutex) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return next.Handle(d)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+)
+
+// resultRecord is the final JSON line printed with --result, naming what
+// ended the run.
+type resultRecord struct {
+	Type       string `json:"type"`
+	ExitCode   int    `json:"exit_code"`
+	Reason     string `json:"reason"`
+	Rule       string `json:"rule,omitempty"`
+	DeliveryID string `json:"delivery_id,omitempty"`
+	Event      string `json:"event,omitempty"`
+	vars       map[string]string
+}
+
+// runResult remembers the first event that decided each exit code, since a
+// success can be held back by --settle or --grace and then overridden by a
+// failure or a missed deadline. It also records when each rule first
+// matched, for --report.
+type runResult struct {
+	mu         sync.Mutex
+	started    time.Time
+	decided    map[int]resultRecord
+	matched    map[ruleKey]ruleMatch
+	outputVars []OutputVar
+}
+
+// ruleKey names a rule by its flag and text, e.g. {"failure-on", "event=push"}.
+type ruleKey struct {
+	kind string
+	rule string
+}
+
+// ruleMatch is when a rule first matched and the event that matched it.
+type ruleMatch struct {
+	at     time.Time
+	ref    eventRef
+	detail string
+}
+
+func newRunResult(outputVars []OutputVar) *runResult {
+	return &runResult{
+		started:    time.Now(),
+		decided:    make(map[int]resultRecord),
+		matched:    make(map[ruleKey]ruleMatch),
+		outputVars: outputVars,
+	}
+}
+
+// eventRef identifies the event that decided an outcome.
+type eventRef struct {
+	deliveryID string
+	event      string
+	// vars holds the --output-var values read from the event.
+	vars map[string]string
+}
+
+// ref identifies the event in doc, reading the --output-var values from it.
+func (r *runResult) ref(doc assertion.Document) eventRef {
+	var ref eventRef
+	ref.deliveryID, _ = doc.Lookup("delivery_id")
+	ref.event, _ = doc.Lookup("event")
+	for _, v := range r.outputVars {
+		if value, ok := doc.Lookup(v.Path); ok {
+			if ref.vars == nil {
+				ref.vars = make(map[string]string)
+			}
+			ref.vars[v.Name] = value
+		}
+	}
+	return ref
+}
+
+// note records that the event matched rule, leading to the exit code.
+func (r *runResult) note(code int, reason, rule string, ref eventRef) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.decided[code]; ok {
+		return
+	}
+	r.decided[code] = resultRecord{
+		Type:       "result",
+		ExitCode:   code,
+		Reason:     reason,
+		Rule:       rule,
+		DeliveryID: ref.deliveryID,
+		Event:      ref.event,
+		vars:       ref.vars,
+	}
+}
+
+// retractSuccess forgets the success a cancelled settle period held back,
+// so the success that ends the run is the one reported.
+func (r *runResult) retractSuccess() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.decided, 0)
+	for key := range r.matched {
+		switch key.kind {
+		case "success-on", "success-on-all", "until":
+			delete(r.matched, key)
+		}
+	}
+}
+
+// match records that a rule matched the event, unless it already had.
+func (r *runResult) match(kind, rule string, ref eventRef, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := ruleKey{kind: kind, rule: rule}
+	if _, ok := r.matched[key]; ok {
+		return
+	}
+	r.matched[key] = ruleMatch{at: time.Now(), ref: ref, detail: detail}
+}
+
+// outcome returns the record for the run's final outcome. Runs that end
+// without an exit code of 0, 1, or 124 (interrupts, fatal errors) have none.
+func (r *runResult) outcome(err error) (resultRecord, bool) {
+	var exitErr exitError
+	if !errors.As(err, &exitErr) {
+		return resultRecord{}, false
+	}
+	r.mu.Lock()
+	record, ok := r.decided[exitErr.code]
+	r.mu.Unlock()
+	if ok {
+		return record, true
+	}
+	record = resultRecord{Type: "result", ExitCode: exitErr.code}
+	switch exitErr.code {
+	case 0:
+		record.Reason = "success"
+	case 1:
+		record.Reason = "failure"
+	case 124:
+		record.Reason = "timeout"
+	default:
+		return resultRecord{}, false
+	}
+	return record, true
+}
+
+// write prints the record for the run's final outcome (--result).
+func (r *runResult) write(stdout *bufio.Writer, err error) error {
+	record, ok := r.outcome(err)
+	if !ok {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := stdout.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return stdout.Flush()
+}
+
+// firstMatch returns the first of the assertions that matches doc.
+func firstMatch(doc assertion.Document, assertions []assertion.Assertion) (assertion.Assertion, bool) {
+	for _, a := range assertions {
+		if doc.MatchAny([]assertion.Assertion{a}) {
+			return a, true
+		}
+	}
+	return assertion.Assertion{}, false
+}
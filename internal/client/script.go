@@ -0,0 +1,303 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// scriptTimeout limits how long a script may spend on one event.
+const scriptTimeout = 5 * time.Second
+
+// scriptStage runs each delivery through the transform function of a Lua
+// script (--script). transform gets the envelope as a table and returns it,
+// changed or not, or nil to drop it; emit(table) sends a derived event on
+// after it. JSON null is the global null, and print writes to stderr.
+//
+// Events the script fails on are logged and, as with --jq, kept for
+// assertions without being written out.
+func scriptStage(path string, logger *log.Logger) (Middleware, error) {
+	s, err := newScript(path, logger)
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --script: %v", err)}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			kept, emitted, err := s.run(d.Message)
+			if err != nil {
+				if logger != nil {
+					logger.Printf("script failed on event %s: %v", d.Message.DeliveryID, err)
+				}
+				d.SetOutput(nil)
+				return next.Handle(d)
+			}
+			if kept != nil {
+				d.SetMessage(*kept)
+				if err := next.Handle(d); err != nil {
+					return err
+				}
+			}
+			for _, msg := range emitted {
+				if msg.ReceivedAt.IsZero() {
+					msg.ReceivedAt = d.Message.ReceivedAt
+				}
+				if err := next.Handle(&Delivery{Message: msg, ReceivedAt: d.ReceivedAt}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, nil
+}
+
+// script is a loaded --script. A Lua state runs one call at a time, so
+// deliveries from several sources take turns.
+type script struct {
+	mu        sync.Mutex
+	state     *lua.LState
+	transform *lua.LFunction
+	// null stands for JSON null, and arrays marks tables decoded from JSON
+	// arrays so that empty ones stay arrays.
+	null    *lua.LUserData
+	arrays  *lua.LTable
+	emitted []*lua.LTable
+}
+
+func newScript(path string, logger *log.Logger) (*script, error) {
+	// Only the libraries without file or process access are opened.
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	s := &script{state: L, null: L.NewUserData(), arrays: L.NewTable()}
+	L.SetGlobal("null", s.null)
+	L.SetGlobal("emit", L.NewFunction(func(L *lua.LState) int {
+		s.emitted = append(s.emitted, L.CheckTable(1))
+		return 0
+	}))
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		if logger != nil {
+			logger.Print(strings.Join(parts, "\t"))
+		}
+		return 0
+	}))
+
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return nil, err
+	}
+	transform, ok := L.GetGlobal("transform").(*lua.LFunction)
+	if !ok {
+		L.Close()
+		return nil, fmt.Errorf("%s does not define a transform(event) function", path)
+	}
+	s.transform = transform
+	return s, nil
+}
+
+// run calls transform on msg, returning the event it kept, if any, and the
+// events it emitted.
+func (s *script) run(msg message.EventMessage) (*message.EventMessage, []message.EventMessage, error) {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	s.state.SetContext(ctx)
+	defer s.state.RemoveContext()
+	s.emitted = nil
+	if err := s.state.CallByParam(lua.P{Fn: s.transform, NRet: 1, Protect: true}, s.toLua(value)); err != nil {
+		// The message alone, without Lua's stack traceback, keeps the log
+		// to one line.
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			return nil, nil, errors.New(apiErr.Object.String())
+		}
+		return nil, nil, err
+	}
+	result := s.state.Get(-1)
+	s.state.Pop(1)
+
+	var kept *message.EventMessage
+	switch result := result.(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		if result {
+			kept = &msg
+		}
+	case *lua.LTable:
+		out, err := s.toMessage(result)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Signature, out.Token = msg.Signature, msg.Token
+		kept = &out
+	default:
+		return nil, nil, fmt.Errorf("transform returned a %s, not an event table or nil", result.Type())
+	}
+	var emitted []message.EventMessage
+	for _, table := range s.emitted {
+		out, err := s.toMessage(table)
+		if err != nil {
+			return nil, nil, fmt.Errorf("emit: %w", err)
+		}
+		emitted = append(emitted, out)
+	}
+	return kept, emitted, nil
+}
+
+func (s *script) toMessage(table *lua.LTable) (message.EventMessage, error) {
+	value, err := s.fromLua(table, 0)
+	if err != nil {
+		return message.EventMessage{}, err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return message.EventMessage{}, err
+	}
+	var msg message.EventMessage
+	if err := json.Unmarshal(encoded, &msg); err != nil {
+		return message.EventMessage{}, fmt.Errorf("not an event: %w", err)
+	}
+	if msg.Type == "" {
+		msg.Type = "event"
+	}
+	if len(msg.Payload) == 0 || string(msg.Payload) == "null" {
+		msg.Payload = json.RawMessage("{}")
+	}
+	return msg, nil
+}
+
+func (s *script) toLua(value any) lua.LValue {
+	switch value := value.(type) {
+	case nil:
+		return s.null
+	case bool:
+		return lua.LBool(value)
+	case json.Number:
+		f, _ := value.Float64()
+		return lua.LNumber(f)
+	case string:
+		return lua.LString(value)
+	case []any:
+		table := s.state.CreateTable(len(value), 0)
+		for _, item := range value {
+			table.Append(s.toLua(item))
+		}
+		s.state.SetMetatable(table, s.arrays)
+		return table
+	case map[string]any:
+		table := s.state.CreateTable(0, len(value))
+		for key, item := range value {
+			table.RawSetString(key, s.toLua(item))
+		}
+		return table
+	}
+	return lua.LNil
+}
+
+// fromLua converts a Lua value back to JSON. Tables are arrays when they came
+// from one, or their keys are exactly 1..n; otherwise they are objects.
+func (s *script) fromLua(value lua.LValue, depth int) (any, error) {
+	if depth > 100 {
+		return nil, fmt.Errorf("table nested too deeply (a cycle?)")
+	}
+	switch value := value.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(value), nil
+	case lua.LNumber:
+		f := float64(value)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%v can't be encoded as JSON", f)
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+	case lua.LString:
+		return string(value), nil
+	case *lua.LUserData:
+		if value == s.null {
+			return nil, nil
+		}
+	case *lua.LTable:
+		n := value.Len()
+		keys := 0
+		value.ForEach(func(lua.LValue, lua.LValue) { keys++ })
+		if s.state.GetMetatable(value) == s.arrays || (n > 0 && keys == n) {
+			items := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := s.fromLua(value.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return items, nil
+		}
+		object := make(map[string]any, keys)
+		var err error
+		value.ForEach(func(key, item lua.LValue) {
+			if err != nil {
+				return
+			}
+			var name string
+			switch key := key.(type) {
+			case lua.LString:
+				name = string(key)
+			case lua.LNumber:
+				name = strconv.FormatFloat(float64(key), 'f', -1, 64)
+			default:
+				err = fmt.Errorf("object keys must be strings, got a %s", key.Type())
+				return
+			}
+			object[name], err = s.fromLua(item, depth+1)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+	return nil, fmt.Errorf("a %s can't be encoded as JSON", value.Type())
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+)
+
+// sizeStage guards against oversized payloads (--max-event-size). Events
+// whose payload exceeds limit bytes are dropped, or with truncate kept with
+// as many top-level payload fields as fit and marked "truncated": true.
+func sizeStage(limit int64, truncate bool, logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			size := int64(len(d.Message.Payload))
+			if size <= limit {
+				return next.Handle(d)
+			}
+			if !truncate {
+				if logger != nil {
+					logger.Printf("dropping event %s (%s): payload is %d bytes, over --max-event-size %d", d.Message.DeliveryID, d.Message.Event, size, limit)
+				}
+				return nil
+			}
+			if logger != nil {
+				logger.Printf("truncating event %s (%s): payload is %d bytes, over --max-event-size %d", d.Message.DeliveryID, d.Message.Event, size, limit)
+			}
+			msg := d.Message
+			msg.Payload = truncatePayload(msg.Payload, limit)
+			msg.Truncated = true
+			d.SetMessage(msg)
+			return next.Handle(d)
+		})
+	}
+}
+
+// truncatePayload keeps the top-level fields of a JSON object, in order,
+// while the result stays within limit bytes. Fields that don't fit are
+// skipped, so small fields such as action after a large one are kept.
+func truncatePayload(payload json.RawMessage, limit int64) json.RawMessage {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return json.RawMessage("{}")
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			break
+		}
+		encodedKey, _ := json.Marshal(key)
+		// One byte for the separating comma and one for the closing brace.
+		if int64(out.Len()+len(encodedKey)+1+len(value)+2) > limit {
+			continue
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		out.Write(encodedKey)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes()
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// outputWriter receives output lines in place of stdout, keyed by the
+// --split-by value of their delivery.
+type outputWriter interface {
+	key(d *Delivery) string
+	write(key string, line []byte) error
+	flush() error
+	close() error
+}
+
+// newOutputWriter opens the destination cfg names with SinkPlugin, Output,
+// or OutputDir, or returns nil when output goes to stdout.
+func newOutputWriter(cfg Config) (outputWriter, error) {
+	if cfg.SinkPlugin != "" {
+		plugin, err := newPluginWriter(cfg.SinkPlugin)
+		if err != nil {
+			return nil, err
+		}
+		return plugin, nil
+	}
+	if cfg.Output != "" {
+		return openOutput(cfg)
+	}
+	if cfg.OutputDir != "" {
+		split, err := newSplitWriter(cfg.OutputDir, cfg.SplitBy, cfg.Encrypt)
+		if err != nil {
+			return nil, err
+		}
+		return split, nil
+	}
+	return nil, nil
+}
+
+// splitWriter appends output lines to one <value>.jsonl file per value of
+// the split path (the event type by default) in a directory, opening files
+// as new values appear.
+//
+// With recipients (--encrypt), files are <value>.jsonl.age instead. An age
+// file can't be appended to, so one that already exists is an error.
+type splitWriter struct {
+	dir        string
+	by         string
+	recipients []age.Recipient
+	files      map[string]*os.File
+	encrypted  map[string]io.WriteCloser
+	bufs       map[string]*bufio.Writer
+	counts     map[string]int
+}
+
+func newSplitWriter(dir, by string, recipients []age.Recipient) (*splitWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --output-dir: %v", err)}
+	}
+	return &splitWriter{
+		dir:        dir,
+		by:         by,
+		recipients: recipients,
+		files:      make(map[string]*os.File),
+		encrypted:  make(map[string]io.WriteCloser),
+		bufs:       make(map[string]*bufio.Writer),
+		counts:     make(map[string]int),
+	}, nil
+}
+
+func (s *splitWriter) write(key string, line []byte) error {
+	name := splitFileName(key)
+	w, ok := s.bufs[name]
+	if !ok {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		path := filepath.Join(s.dir, name)
+		if len(s.recipients) > 0 {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_EXCL
+			path += ".age"
+		}
+		f, err := os.OpenFile(path, flags, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists, and encrypted files can't be appended to", path)
+		}
+		if err != nil {
+			return err
+		}
+		enc := encryptTo(f, s.recipients)
+		w = bufio.NewWriter(enc)
+		s.files[name] = f
+		s.encrypted[name] = enc
+		s.bufs[name] = w
+	}
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	s.counts[name]++
+	return w.WriteByte('\n')
+}
+
+func (s *splitWriter) flush() error {
+	var errs []error
+	for _, w := range s.bufs {
+		errs = append(errs, w.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *splitWriter) close() error {
+	err := s.flush()
+	for name, f := range s.files {
+		err = errors.Join(err, s.encrypted[name].Close(), f.Close())
+	}
+	return err
+}
+
+// key returns the value of the split path for a delivery, or "" when it is
+// missing.
+func (s *splitWriter) key(d *Delivery) string {
+	return splitKey(s.by, d)
+}
+
+func splitKey(by string, d *Delivery) string {
+	if by == "" || by == "event" {
+		return d.Message.Event
+	}
+	doc, err := d.Document()
+	if err != nil {
+		return ""
+	}
+	key, _ := doc.Lookup(by)
+	return key
+}
+
+// splitFileName maps a split value to a file name, replacing characters that
+// are unsafe in paths.
+func splitFileName(event string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, event)
+	if name == "" {
+		name = "unknown"
+	}
+	return name + ".jsonl"
+}
+
+// splitStage writes each delivery to w instead of stdout.
+func splitStage(w outputWriter, logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(d *Delivery) error {
+			lines, err := d.Output()
+			if err != nil {
+				if logger != nil {
+					logger.Printf("failed to encode event: %v", err)
+				}
+				return nil
+			}
+			for _, line := range lines {
+				if err := w.write(w.key(d), line); err != nil {
+					return err
+				}
+			}
+			if err := w.flush(); err != nil {
+				return err
+			}
+			return next.Handle(d)
+		})
+	}
+}
+
+// SplitResult is one file written by Split and how many events it received.
+type SplitResult struct {
+	File   string
+	Events int
+}
+
+// Split reads a JSONL capture and appends each event to one file per value
+// of the envelope path by (e.g. "event" or "payload.repository.full_name")
+// in dir. Events missing the path go to unknown.jsonl.
+func Split(ctx context.Context, r io.Reader, dir, by string, logger *log.Logger) ([]SplitResult, error) {
+	w, err := newSplitWriter(dir, by, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = Replay(ctx, Config{}, r, logger, func(msg message.EventMessage) error {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return w.write(w.key(&Delivery{Message: msg}), line)
+	})
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SplitResult, 0, len(w.counts))
+	for name, n := range w.counts {
+		results = append(results, SplitResult{File: filepath.Join(dir, name), Events: n})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema stores one row per event, with the columns most queries
+// filter on indexed and the payload kept as JSON for SQLite's json
+// functions.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id          INTEGER PRIMARY KEY,
+	delivery_id TEXT,
+	event       TEXT,
+	action      TEXT,
+	repo        TEXT,
+	received_at TEXT,
+	payload     TEXT
+);
+CREATE INDEX IF NOT EXISTS events_delivery_id ON events (delivery_id);
+CREATE INDEX IF NOT EXISTS events_event ON events (event);
+CREATE INDEX IF NOT EXISTS events_action ON events (action);
+CREATE INDEX IF NOT EXISTS events_repo ON events (repo);
+CREATE INDEX IF NOT EXISTS events_received_at ON events (received_at);
+`
+
+// sqliteTime formats received_at so it sorts as text and SQLite's date
+// functions understand it.
+const sqliteTime = "2006-01-02T15:04:05.000Z"
+
+// sqliteWriter inserts output lines into an events table, one transaction
+// per flush.
+type sqliteWriter struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	insert *sql.Stmt
+}
+
+func newSQLiteWriter(path string) (*sqliteWriter, error) {
+	// WAL lets other processes query the database while events arrive.
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, configError{err: fmt.Errorf("invalid --output: %v", err)}
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, configError{err: fmt.Errorf("invalid --output: %s: %v", path, err)}
+	}
+	return &sqliteWriter{db: db}, nil
+}
+
+func (s *sqliteWriter) key(d *Delivery) string {
+	return d.Message.Event
+}
+
+func (s *sqliteWriter) write(key string, line []byte) error {
+	row, err := decodeRow(line)
+	if err != nil {
+		return err
+	}
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		insert, err := tx.Prepare(`INSERT INTO events (delivery_id, event, action, repo, received_at, payload) VALUES (?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		s.tx, s.insert = tx, insert
+	}
+	var receivedAt any
+	if !row.ReceivedAt.IsZero() {
+		receivedAt = row.ReceivedAt.UTC().Format(sqliteTime)
+	}
+	var payload any
+	if len(row.Payload) > 0 {
+		payload = string(row.Payload)
+	}
+	_, err = s.insert.Exec(nullString(row.DeliveryID), nullString(row.Event), nullString(row.Action), nullString(row.Repo), receivedAt, payload)
+	return err
+}
+
+func (s *sqliteWriter) flush() error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx, s.insert = nil, nil
+	return tx.Commit()
+}
+
+func (s *sqliteWriter) close() error {
+	err := s.flush()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// nullString stores empty values as NULL.
+func nullString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/metrics"
+)
+
+// statsdInterval is how often reconnects are sent to StatsD; event counters
+// are sent as events arrive.
+const statsdInterval = 10 * time.Second
+
+// statsdReporter sends a run's counters to StatsD (--statsd):
+//
+//	<prefix>.events.<event>  every event received, by type
+//	<prefix>.written         events that reached the output
+//	<prefix>.reconnects      channel reconnects
+type statsdReporter struct {
+	client  *metrics.StatsD
+	sources []source
+	sent    int64
+}
+
+func newStatsdReporter(addr string, tags []string, prefix string, sources []source) (*statsdReporter, error) {
+	client, err := metrics.NewStatsD(addr, prefix)
+	if err != nil {
+		return nil, configError{err: err}
+	}
+	client.Tags = tags
+	return &statsdReporter{client: client, sources: sources}, nil
+}
+
+// count is the first stage of the chain and sees every delivery.
+func (r *statsdReporter) count(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		_ = r.client.Count("events."+metrics.SanitizeName(d.Message.Event), 1)
+		return next.Handle(d)
+	})
+}
+
+// pass runs just ahead of the sink and counts deliveries with output.
+func (r *statsdReporter) pass(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		if lines, err := d.Output(); err == nil && len(lines) > 0 {
+			_ = r.client.Count("written", 1)
+		}
+		return next.Handle(d)
+	})
+}
+
+// start sends reconnects periodically until the returned function is
+// called, which sends the remainder and closes the connection.
+func (r *statsdReporter) start() func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(statsdInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.flush()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		r.flush()
+		_ = r.client.Close()
+	}
+}
+
+func (r *statsdReporter) flush() {
+	total := reconnects(r.sources)
+	if delta := total - r.sent; delta > 0 {
+		_ = r.client.Count("reconnects", delta)
+		r.sent = total
+	}
+}
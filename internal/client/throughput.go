@@ -0,0 +1,137 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/sse"
+	"github.com/kehao95/gh-pulse/internal/stats"
+)
+
+// throughput counts events passing through a run and logs the rates every
+// interval (--stats-interval), for watching long sessions from stderr.
+type throughput struct {
+	received atomic.Int64
+	bytes    atomic.Int64
+	written  atomic.Int64
+	// latency holds the delivery latencies of the events since the last
+	// line, for events that carry a GitHub timestamp.
+	mu      sync.Mutex
+	latency stats.Latencies
+	// buffer, in capture mode, reports how many events and bytes are held.
+	buffer  func() (int, int64)
+	sources []source
+	logger  *log.Logger
+}
+
+func newThroughput(sources []source, logger *log.Logger) *throughput {
+	return &throughput{sources: sources, logger: logger}
+}
+
+// count is the first stage of the chain and sees every delivery.
+func (t *throughput) count(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		t.received.Add(1)
+		t.bytes.Add(int64(len(d.Message.Payload)))
+		t.mu.Lock()
+		t.latency.Add(d.Message)
+		t.mu.Unlock()
+		return next.Handle(d)
+	})
+}
+
+// pass runs just ahead of the sink and counts deliveries with output; the
+// rest were dropped by filters, the size guard, or the rate limit.
+func (t *throughput) pass(next Handler) Handler {
+	return HandlerFunc(func(d *Delivery) error {
+		if lines, err := d.Output(); err == nil && len(lines) > 0 {
+			t.written.Add(1)
+		}
+		return next.Handle(d)
+	})
+}
+
+// start logs a line every interval until the returned function is called.
+func (t *throughput) start(interval time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				t.log(now.Sub(last))
+				last = now
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (t *throughput) log(elapsed time.Duration) {
+	received := t.received.Swap(0)
+	bytes := t.bytes.Swap(0)
+	written := t.written.Swap(0)
+	seconds := elapsed.Seconds()
+
+	line := fmt.Sprintf("stats: %.1f events/s, %s/s in; %d written, %d dropped", float64(received)/seconds, formatBytes(float64(bytes)/seconds), written, max(received-written, 0))
+	if t.buffer != nil {
+		events, size := t.buffer()
+		line += fmt.Sprintf("; buffer %d events (%s)", events, formatBytes(float64(size)))
+	}
+	line += fmt.Sprintf("; %d reconnects", reconnects(t.sources))
+	t.mu.Lock()
+	latency := t.latency.Summary()
+	t.latency.Reset()
+	t.mu.Unlock()
+	if latency != nil && latency.Samples > 0 {
+		line += fmt.Sprintf("; latency p50 %s, p99 %s", formatMillis(latency.P50), formatMillis(latency.P99))
+	}
+	t.logger.Print(line)
+	if latency != nil && latency.Skewed > 0 {
+		t.logger.Printf("stats: %d events were timestamped more than %s after they arrived; the local clock may be behind", latency.Skewed, stats.SkewTolerance)
+	}
+}
+
+// reconnects totals how often the channel sources have reconnected.
+func reconnects(sources []source) int64 {
+	var n int64
+	for _, src := range sources {
+		if client, ok := src.(*sse.Client); ok {
+			n += client.Reconnects()
+		}
+	}
+	return n
+}
+
+// statsLogger returns the run's logger, or a stderr logger when the run is
+// quiet, since stats were asked for explicitly.
+func statsLogger(logger *log.Logger) *log.Logger {
+	if logger != nil {
+		return logger
+	}
+	return log.New(os.Stderr, "", log.LstdFlags)
+}
+
+func formatMillis(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
+
+func formatBytes(n float64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", n/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", n/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", n/(1<<10))
+	}
+	return fmt.Sprintf("%.0fB", n)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// verifyEvent checks the forwarded X-Hub-Signature-256 (or Gitea's
+// signature, or GitLab's token) against cfg.VerifySecret, or the current
+// value of cfg.VerifySecretFrom. It records the outcome on msg and reports
+// whether the event should be kept.
+func verifyEvent(cfg Config, msg *message.EventMessage, logger *log.Logger) bool {
+	key := cfg.VerifySecret
+	if cfg.VerifySecretFrom != nil {
+		key = cfg.VerifySecretFrom.Value()
+	}
+	if key == "" {
+		return true
+	}
+	var verified bool
+	if msg.Provider == "gitlab" {
+		verified = subtle.ConstantTimeCompare([]byte(key), []byte(msg.Token)) == 1
+	} else {
+		verified = validSignature(key, msg.Payload, msg.Signature)
+	}
+	msg.Verified = &verified
+	if verified {
+		return true
+	}
+	if logger != nil {
+		if cfg.KeepUnverified {
+			logger.Printf("event %s (%s) failed signature verification", msg.DeliveryID, msg.Event)
+		} else {
+			logger.Printf("dropping event %s (%s): signature verification failed", msg.DeliveryID, msg.Event)
+		}
+	}
+	return cfg.KeepUnverified
+}
+
+func validSignature(secret string, body []byte, signature string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
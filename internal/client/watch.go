@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/sse"
+)
+
+// Watch runs the filter pipeline and passes each surviving event to handle
+// instead of writing it to stdout. It returns when ctx is cancelled.
+func Watch(ctx context.Context, cfg Config, logger *log.Logger, handle func(message.EventMessage)) error {
+	if err := ValidateURL(cfg.URL); err != nil {
+		return err
+	}
+	stages, err := pipeline(cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	sink := HandlerFunc(func(d *Delivery) error {
+		handle(d.Message)
+		return nil
+	})
+	client := sse.NewClient(cfg.URL, logger)
+	return client.Run(ctx, deliver(Chain(sink, stages...)))
+}
+
+// Replay reads JSONL envelopes from r, as written by stream or capture, and
+// passes those surviving the filter pipeline to handle. Lines that are not
+// event envelopes are skipped.
+func Replay(ctx context.Context, cfg Config, r io.Reader, logger *log.Logger, handle func(message.EventMessage) error) error {
+	stages, err := pipeline(cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	sink := HandlerFunc(func(d *Delivery) error {
+		return handle(d.Message)
+	})
+	handler := deliver(Chain(sink, stages...))
+
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg message.EventMessage
+			if err := json.Unmarshal(line, &msg); err != nil {
+				if logger != nil {
+					logger.Printf("line %d: skipping invalid JSON: %v", lineNo, err)
+				}
+			} else if msg.Type == "event" {
+				if err := handler(msg); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package diff compares two captures of webhook events.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// Record is a JSONL line emitted by the diff command.
+type Record struct {
+	Type        string       `json:"type"`
+	Key         string       `json:"key,omitempty"`
+	File        string       `json:"file,omitempty"`
+	Event       string       `json:"event,omitempty"`
+	DeliveryID  string       `json:"delivery_id,omitempty"`
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// Difference is a single path whose value differs between the captures. A
+// side is omitted when the path is absent from it.
+type Difference struct {
+	Path string          `json:"path"`
+	A    json.RawMessage `json:"a,omitempty"`
+	B    json.RawMessage `json:"b,omitempty"`
+}
+
+// Summary totals the comparison.
+type Summary struct {
+	Type      string `json:"type"`
+	OnlyInA   int    `json:"only_in_a"`
+	OnlyInB   int    `json:"only_in_b"`
+	Changed   int    `json:"changed"`
+	Identical int    `json:"identical"`
+}
+
+// Key builds the comparison key for msg from the given envelope paths,
+// joining multiple values with "|".
+func Key(msg message.EventMessage, paths []string) string {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		value, _ := assertion.Lookup(encoded, path)
+		parts[i] = value
+	}
+	return strings.Join(parts, "|")
+}
+
+// Compare reports events present in only one capture and payload
+// differences between events sharing a key. Records are ordered by their
+// position in a, followed by events only in b.
+func Compare(nameA string, a []message.EventMessage, nameB string, b []message.EventMessage, paths []string) ([]Record, Summary) {
+	summary := Summary{Type: "summary"}
+	indexB := make(map[string]message.EventMessage, len(b))
+	for _, msg := range b {
+		key := Key(msg, paths)
+		if _, ok := indexB[key]; !ok {
+			indexB[key] = msg
+		}
+	}
+
+	var records []Record
+	seen := make(map[string]bool, len(a))
+	for _, msg := range a {
+		key := Key(msg, paths)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		other, ok := indexB[key]
+		if !ok {
+			summary.OnlyInA++
+			records = append(records, Record{Type: "only_in", Key: key, File: nameA, Event: msg.Event, DeliveryID: msg.DeliveryID})
+			continue
+		}
+		differences := comparePayloads(msg.Payload, other.Payload)
+		if len(differences) == 0 {
+			summary.Identical++
+			continue
+		}
+		summary.Changed++
+		records = append(records, Record{Type: "changed", Key: key, Event: msg.Event, DeliveryID: msg.DeliveryID, Differences: differences})
+	}
+	for _, msg := range b {
+		key := Key(msg, paths)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		summary.OnlyInB++
+		records = append(records, Record{Type: "only_in", Key: key, File: nameB, Event: msg.Event, DeliveryID: msg.DeliveryID})
+	}
+	return records, summary
+}
+
+func comparePayloads(a, b json.RawMessage) []Difference {
+	va, errA := decode(a)
+	vb, errB := decode(b)
+	if errA != nil || errB != nil {
+		if bytes.Equal(a, b) {
+			return nil
+		}
+		return []Difference{{Path: "payload", A: a, B: b}}
+	}
+	var differences []Difference
+	walk("payload", va, vb, true, true, &differences)
+	return differences
+}
+
+func walk(path string, a, b interface{}, hasA, hasB bool, out *[]Difference) {
+	if hasA && hasB {
+		switch left := a.(type) {
+		case map[string]interface{}:
+			if right, ok := b.(map[string]interface{}); ok {
+				keys := make(map[string]bool, len(left)+len(right))
+				for key := range left {
+					keys[key] = true
+				}
+				for key := range right {
+					keys[key] = true
+				}
+				sorted := make([]string, 0, len(keys))
+				for key := range keys {
+					sorted = append(sorted, key)
+				}
+				sort.Strings(sorted)
+				for _, key := range sorted {
+					childA, okA := left[key]
+					childB, okB := right[key]
+					walk(path+"."+key, childA, childB, okA, okB, out)
+				}
+				return
+			}
+		case []interface{}:
+			if right, ok := b.([]interface{}); ok {
+				for i := 0; i < len(left) || i < len(right); i++ {
+					var childA, childB interface{}
+					if i < len(left) {
+						childA = left[i]
+					}
+					if i < len(right) {
+						childB = right[i]
+					}
+					walk(path+"."+strconv.Itoa(i), childA, childB, i < len(left), i < len(right), out)
+				}
+				return
+			}
+		}
+	}
+
+	encodedA := encode(a, hasA)
+	encodedB := encode(b, hasB)
+	if hasA == hasB && bytes.Equal(encodedA, encodedB) {
+		return
+	}
+	*out = append(*out, Difference{Path: path, A: encodedA, B: encodedB})
+}
+
+func decode(raw json.RawMessage) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+func encode(value interface{}, present bool) json.RawMessage {
+	if !present {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
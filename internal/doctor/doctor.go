@@ -0,0 +1,232 @@
+// Package doctor diagnoses the network path between gh-pulse and a smee
+// channel.
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/fixture"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/sse"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+)
+
+// Result is the outcome of one check, printed as a JSON line.
+type Result struct {
+	Type       string `json:"type"`
+	Check      string `json:"check"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Summary follows the individual results.
+type Summary struct {
+	Type   string `json:"type"`
+	Passed int    `json:"passed"`
+	Failed int    `json:"failed"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context) (detail string, hint string, err error)
+}
+
+// Run checks DNS, TCP, TLS, the SSE handshake for channelURL, and a round
+// trip through a temporary channel on the same host. Each check gets its own
+// timeout; checks that depend on a failed one are skipped.
+func Run(ctx context.Context, channelURL string, timeout time.Duration, report func(Result) error) (Summary, error) {
+	target, err := url.Parse(channelURL)
+	if err != nil {
+		return Summary{}, err
+	}
+	host := target.Hostname()
+	port := target.Port()
+	if port == "" {
+		port = "443"
+		if target.Scheme == "http" {
+			port = "80"
+		}
+	}
+	addr := net.JoinHostPort(host, port)
+	httpClient := &http.Client{}
+
+	checks := []check{
+		{"dns", func(ctx context.Context) (string, string, error) {
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			if err != nil {
+				return "", "check the hostname in --url and your DNS settings", err
+			}
+			return strings.Join(addrs, ", "), "", nil
+		}},
+		{"tcp", func(ctx context.Context) (string, string, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return "", fmt.Sprintf("outbound connections to %s are blocked; check firewalls or set HTTPS_PROXY", addr), err
+			}
+			defer conn.Close()
+			return "connected to " + conn.RemoteAddr().String(), "", nil
+		}},
+		{"tls", func(ctx context.Context) (string, string, error) {
+			if target.Scheme != "https" {
+				return "", "", errSkip
+			}
+			dialer := tls.Dialer{Config: &tls.Config{ServerName: host}}
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return "", "a TLS-intercepting proxy or outdated CA bundle may be in the way", err
+			}
+			defer conn.Close()
+			state := conn.(*tls.Conn).ConnectionState()
+			cert := state.PeerCertificates[0]
+			return fmt.Sprintf("%s, certificate for %s issued by %s, expires %s",
+				tls.VersionName(state.Version), cert.Subject.CommonName, cert.Issuer.CommonName,
+				cert.NotAfter.UTC().Format(time.DateOnly)), "", nil
+		}},
+		{"sse", func(ctx context.Context) (string, string, error) {
+			return handshake(ctx, httpClient, channelURL)
+		}},
+		{"delivery", func(ctx context.Context) (string, string, error) {
+			return roundTrip(ctx, httpClient, target)
+		}},
+	}
+
+	summary := Summary{Type: "summary"}
+	failed := false
+	for _, c := range checks {
+		result := Result{Type: "check", Check: c.name}
+		if failed {
+			result.Status = "skip"
+			result.Detail = "skipped after an earlier failure"
+		} else {
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			start := time.Now()
+			detail, hint, err := c.run(checkCtx)
+			cancel()
+			result.DurationMS = time.Since(start).Milliseconds()
+			switch {
+			case errors.Is(err, errSkip):
+				result.Status = "skip"
+				result.Detail = "not applicable"
+			case err != nil:
+				if ctx.Err() != nil {
+					return summary, ctx.Err()
+				}
+				result.Status = "fail"
+				result.Detail = err.Error()
+				result.Hint = hint
+				summary.Failed++
+				failed = true
+			default:
+				result.Status = "pass"
+				result.Detail = detail
+				summary.Passed++
+			}
+		}
+		if err := report(result); err != nil {
+			return summary, err
+		}
+	}
+	if !failed {
+		summary.Hint = "connectivity is fine; if events are missing, check that the webhook's Payload URL matches --url and review Recent Deliveries in the repository's webhook settings"
+	}
+	return summary, nil
+}
+
+var errSkip = errors.New("skip")
+
+// handshake opens the event stream and waits for the first line the server
+// sends, which smee uses to confirm the subscription.
+func handshake(ctx context.Context, httpClient *http.Client, channelURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, channelURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", "the channel host is reachable but HTTP requests fail; check proxy settings", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "check that --url is a smee channel URL such as https://smee.io/<channel>", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		return "", "the URL does not serve an event stream; check that --url is a smee channel URL", fmt.Errorf("unexpected content type %q", contentType)
+	}
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		if ctx.Err() != nil {
+			err = errors.New("no data received on the event stream")
+		}
+		return "", "a proxy may be buffering the response; streaming connections must pass through unbuffered", err
+	}
+	return "event stream open", "", nil
+}
+
+// roundTrip subscribes to a temporary channel on the same host, posts a ping
+// event to it, and waits for the event to come back.
+func roundTrip(ctx context.Context, httpClient *http.Client, target *url.URL) (string, string, error) {
+	suffix := make([]byte, 6)
+	_, _ = rand.Read(suffix)
+	temp := *target
+	temp.Path = "/gh-pulse-doctor-" + hex.EncodeToString(suffix)
+	temp.RawQuery = ""
+	tempURL := temp.String()
+
+	msg, err := fixture.Generate("ping", fixture.Options{})
+	if err != nil {
+		return "", "", err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	received := make(chan time.Time, 1)
+	client := sse.NewClient(tempURL, nil)
+	client.HTTPClient = httpClient
+	go func() {
+		_ = client.Run(runCtx, func(got message.EventMessage) error {
+			if got.DeliveryID == msg.DeliveryID {
+				received <- time.Now()
+				return context.Canceled
+			}
+			return nil
+		})
+	}()
+
+	// The subscription may not be open when the first post arrives, so keep
+	// posting until the event comes back.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var sent time.Time
+	for {
+		sent = time.Now()
+		status, err := webhook.Post(ctx, httpClient, tempURL, msg, "")
+		if err == nil && (status < 200 || status > 299) {
+			err = fmt.Errorf("posting to %s: unexpected status %d", tempURL, status)
+		}
+		if err != nil && ctx.Err() == nil {
+			return "", "the channel host rejected a test delivery; GitHub's deliveries may be rejected the same way", err
+		}
+		select {
+		case at := <-received:
+			return fmt.Sprintf("test event delivered via %s in %s", tempURL, at.Sub(sent).Round(time.Millisecond)), "", nil
+		case <-ctx.Done():
+			return "", "the stream connects but events do not arrive; a proxy may be buffering the event stream", errors.New("test event was not received")
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Package filter holds the event and action filters shared by the CLI and
+// the pulse library.
+package filter
+
+import "encoding/json"
+
+// Event reports whether candidate is one of events; no events allows all.
+func Event(events []string, candidate string) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, event := range events {
+		if event == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Action reports whether the payload's action is one of actions; no actions
+// allows all.
+func Action(actions []string, payload json.RawMessage) bool {
+	if len(actions) == 0 {
+		return true
+	}
+	var decoded struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return false
+	}
+	for _, action := range actions {
+		if action == decoded.Action {
+			return true
+		}
+	}
+	return false
+}
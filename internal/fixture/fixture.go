@@ -0,0 +1,277 @@
+// Package fixture renders synthetic GitHub webhook payloads from embedded
+// templates for local testing.
+package fixture
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+var templates = template.Must(template.New("fixture").Funcs(template.FuncMap{
+	"json":      toJSON,
+	"id":        randomID,
+	"userID":    stableID,
+	"repoID":    stableID,
+	"short":     func(sha string) string { return sha[:min(len(sha), 12)] },
+	"last":      func(commits []Commit) Commit { return commits[len(commits)-1] },
+	"hasSuffix": strings.HasSuffix,
+}).ParseFS(embedded, "templates/*.tmpl"))
+
+// Options controls the values substituted into a template.
+type Options struct {
+	// Repo is the repository as owner/name.
+	Repo   string
+	Sender string
+	// Action is the payload action; empty uses the template's default.
+	Action  string
+	Ref     string
+	Number  int
+	Commits int
+	Tag     string
+	// Set holds path=value overrides applied to the rendered envelope.
+	Set []string
+}
+
+// Commit is a generated push commit.
+type Commit struct {
+	ID        string
+	TreeID    string
+	Message   string
+	Timestamp string
+}
+
+type data struct {
+	Owner    string
+	Name     string
+	FullName string
+	Sender   string
+	Action   string
+	Ref      string
+	Branch   string
+	Number   int
+	Tag      string
+	SHA      string
+	Before   string
+	Commits  []Commit
+	Now      string
+}
+
+// Events lists the event types with a built-in template.
+func Events() []string {
+	var names []string
+	for _, tmpl := range templates.Templates() {
+		if name, ok := strings.CutSuffix(tmpl.Name(), ".json.tmpl"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate renders one event envelope for the event type.
+func Generate(event string, opts Options) (message.EventMessage, error) {
+	tmpl := templates.Lookup(event + ".json.tmpl")
+	if tmpl == nil {
+		return message.EventMessage{}, fmt.Errorf("no template for event %q (known: %s)", event, strings.Join(Events(), ", "))
+	}
+	d, err := newData(opts)
+	if err != nil {
+		return message.EventMessage{}, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return message.EventMessage{}, fmt.Errorf("failed to render %s: %w", event, err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, buf.Bytes()); err != nil {
+		return message.EventMessage{}, fmt.Errorf("template %s produced invalid JSON: %w", event, err)
+	}
+
+	msg := message.EventMessage{
+		Type:       "event",
+		Event:      event,
+		DeliveryID: newUUID(),
+		Payload:    compact.Bytes(),
+	}
+	if len(opts.Set) == 0 {
+		return msg, nil
+	}
+	return applyOverrides(msg, opts.Set)
+}
+
+func newData(opts Options) (data, error) {
+	repo := opts.Repo
+	if repo == "" {
+		repo = "octo-org/octo-repo"
+	}
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return data{}, fmt.Errorf("invalid repo %q (expected owner/name)", repo)
+	}
+	d := data{
+		Owner:    owner,
+		Name:     name,
+		FullName: repo,
+		Sender:   opts.Sender,
+		Action:   opts.Action,
+		Ref:      opts.Ref,
+		Number:   opts.Number,
+		Tag:      opts.Tag,
+		SHA:      randomHex(20),
+		Before:   randomHex(20),
+		Now:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if d.Sender == "" {
+		d.Sender = owner
+	}
+	if d.Ref == "" {
+		d.Ref = "refs/heads/main"
+	}
+	if !strings.HasPrefix(d.Ref, "refs/") {
+		d.Ref = "refs/heads/" + d.Ref
+	}
+	d.Branch = strings.TrimPrefix(strings.TrimPrefix(d.Ref, "refs/heads/"), "refs/tags/")
+	if d.Number <= 0 {
+		d.Number = 1
+	}
+	if d.Tag == "" {
+		d.Tag = "v1.0.0"
+	}
+	for i := range opts.Commits {
+		id := randomHex(20)
+		if i == opts.Commits-1 {
+			id = d.SHA
+		}
+		d.Commits = append(d.Commits, Commit{
+			ID:        id,
+			TreeID:    randomHex(20),
+			Message:   fmt.Sprintf("Commit %d", i+1),
+			Timestamp: d.Now,
+		})
+	}
+	return d, nil
+}
+
+// applyOverrides sets envelope paths in assertion path syntax, e.g.
+// payload.pull_request.title=Fix. Values are parsed as JSON when possible
+// and used as strings otherwise.
+func applyOverrides(msg message.EventMessage, overrides []string) (message.EventMessage, error) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return msg, err
+	}
+	var envelope map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&envelope); err != nil {
+		return msg, err
+	}
+	for _, override := range overrides {
+		path, rawValue, ok := assertion.CutPath(override, '=')
+		if !ok || path == "" {
+			return msg, fmt.Errorf("invalid override %q (expected path=value)", override)
+		}
+		keys, err := assertion.SplitPath(path)
+		if err != nil {
+			return msg, fmt.Errorf("invalid override %q: %w", override, err)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(rawValue), &value); err != nil {
+			value = rawValue
+		}
+		if err := setPath(envelope, keys, value); err != nil {
+			return msg, fmt.Errorf("invalid override %q: %w", override, err)
+		}
+	}
+	raw, err = json.Marshal(envelope)
+	if err != nil {
+		return msg, err
+	}
+	var updated message.EventMessage
+	if err := json.Unmarshal(raw, &updated); err != nil {
+		return msg, err
+	}
+	return updated, nil
+}
+
+func setPath(node interface{}, parts []string, value interface{}) error {
+	part := parts[0]
+	last := len(parts) == 1
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if last {
+			n[part] = value
+			return nil
+		}
+		child, ok := n[part]
+		if !ok || child == nil {
+			child = make(map[string]interface{})
+			n[part] = child
+		}
+		return setPath(child, parts[1:], value)
+	case []interface{}:
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 || idx >= len(n) {
+			return fmt.Errorf("index %q out of range", part)
+		}
+		if last {
+			n[idx] = value
+			return nil
+		}
+		return setPath(n[idx], parts[1:], value)
+	default:
+		return fmt.Errorf("cannot set %q on a non-object value", part)
+	}
+}
+
+func toJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func randomID() int64 {
+	n, err := rand.Int(rand.Reader, big.NewInt(1<<40))
+	if err != nil {
+		return 1
+	}
+	return n.Int64() + 1
+}
+
+// stableID derives an ID from a name so the same user or repository keeps
+// the same ID across generated events.
+func stableID(name string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return h.Sum32()
+}
+
+func newUUID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+}
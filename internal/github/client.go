@@ -0,0 +1,148 @@
+// Package github is a minimal GitHub REST API client.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is used unless GITHUB_API_URL is set.
+const DefaultBaseURL = "https://api.github.com"
+
+// Client calls the GitHub REST API with a token.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+	// TokenFunc, when set, supplies the token for each request instead of
+	// Token, e.g. a GitHub App JWT that must be refreshed.
+	TokenFunc func() (string, error)
+}
+
+// NewClient returns a client for baseURL, or the GITHUB_API_URL environment
+// variable (falling back to DefaultBaseURL) when baseURL is empty.
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = os.Getenv("GITHUB_API_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// TokenFromEnv returns GITHUB_TOKEN, or GH_TOKEN when it is unset.
+func TokenFromEnv() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// Error is a non-2xx API response.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("GitHub API returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("GitHub API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Get fetches path (e.g. /repos/o/r/pulls/1) and returns the raw JSON body.
+func (c *Client) Get(ctx context.Context, path string) (json.RawMessage, error) {
+	return c.Do(ctx, http.MethodGet, path, nil)
+}
+
+// Do sends a request with an optional JSON body and returns the raw response
+// body.
+func (c *Client) Do(ctx context.Context, method, path string, body io.Reader) (json.RawMessage, error) {
+	data, _, err := c.do(ctx, method, path, body)
+	return data, err
+}
+
+// GetPage fetches one page of a paginated list and returns the URL of the next
+// page from the Link header, or "" on the last page. The next URL can be
+// passed back to GetPage as path.
+func (c *Client) GetPage(ctx context.Context, path string) (json.RawMessage, string, error) {
+	data, header, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, nextLink(header.Get("Link")), nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (json.RawMessage, http.Header, error) {
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = c.BaseURL + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	token := c.Token
+	if c.TokenFunc != nil {
+		if token, err = c.TokenFunc(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var parsed struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &parsed) == nil {
+			apiErr.Message = parsed.Message
+		}
+		return nil, nil, apiErr
+	}
+	return data, resp.Header, nil
+}
+
+// nextLink extracts the rel="next" URL from a Link header.
+func nextLink(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		link, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(link), "<>")
+	}
+	return ""
+}
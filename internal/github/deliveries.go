@@ -0,0 +1,90 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Deliveries accesses a webhook's delivery log: the App's webhook
+// ("/app/hook") or a repository hook ("/repos/o/r/hooks/1").
+type Deliveries struct {
+	API  *Client
+	Base string
+}
+
+// Delivery is an entry in a webhook's delivery log.
+type Delivery struct {
+	ID          int64     `json:"id"`
+	GUID        string    `json:"guid"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Redelivery  bool      `json:"redelivery"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"status_code"`
+	Event       string    `json:"event"`
+	Action      string    `json:"action"`
+	Request     *struct {
+		Headers map[string]string `json:"headers"`
+		Payload json.RawMessage   `json:"payload"`
+	} `json:"request,omitempty"`
+}
+
+// Failed reports whether the endpoint did not accept the delivery.
+func (d Delivery) Failed() bool {
+	return d.StatusCode < 200 || d.StatusCode > 299
+}
+
+// List returns the most recent deliveries, newest first.
+func (d Deliveries) List(ctx context.Context, perPage int) ([]Delivery, error) {
+	raw, err := d.API.Get(ctx, fmt.Sprintf("%s/deliveries?per_page=%d", d.Base, perPage))
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []Delivery
+	if err := json.Unmarshal(raw, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Get returns one delivery including its request headers and payload.
+func (d Deliveries) Get(ctx context.Context, id int64) (Delivery, error) {
+	var delivery Delivery
+	raw, err := d.API.Get(ctx, fmt.Sprintf("%s/deliveries/%d", d.Base, id))
+	if err != nil {
+		return delivery, err
+	}
+	err = json.Unmarshal(raw, &delivery)
+	return delivery, err
+}
+
+// Redeliver asks GitHub to attempt the delivery again.
+func (d Deliveries) Redeliver(ctx context.Context, id int64) error {
+	_, err := d.API.Do(ctx, http.MethodPost, fmt.Sprintf("%s/deliveries/%d/attempts", d.Base, id), nil)
+	return err
+}
+
+// Walk calls fn for each delivery in the log, newest first, fetching further
+// pages until fn returns false or the log ends.
+func (d Deliveries) Walk(ctx context.Context, fn func(Delivery) bool) error {
+	next := fmt.Sprintf("%s/deliveries?per_page=100", d.Base)
+	for next != "" {
+		raw, link, err := d.API.GetPage(ctx, next)
+		if err != nil {
+			return err
+		}
+		var deliveries []Delivery
+		if err := json.Unmarshal(raw, &deliveries); err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			if !fn(delivery) {
+				return nil
+			}
+		}
+		next = link
+	}
+	return nil
+}
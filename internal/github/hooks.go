@@ -0,0 +1,77 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Hook is a repository webhook.
+type Hook struct {
+	ID     int64    `json:"id"`
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+	} `json:"config"`
+}
+
+// HookOptions describes a webhook to create.
+type HookOptions struct {
+	URL    string
+	Events []string
+	Secret string
+}
+
+// CreateHook registers a JSON webhook on repo (owner/name) that delivers
+// events to opts.URL.
+func (c *Client) CreateHook(ctx context.Context, repo string, opts HookOptions) (Hook, error) {
+	config := map[string]string{"url": opts.URL, "content_type": "json"}
+	if opts.Secret != "" {
+		config["secret"] = opts.Secret
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"name":   "web",
+		"active": true,
+		"events": opts.Events,
+		"config": config,
+	})
+	if err != nil {
+		return Hook{}, err
+	}
+	var hook Hook
+	raw, err := c.Do(ctx, http.MethodPost, "/repos/"+repo+"/hooks", bytes.NewReader(body))
+	if err != nil {
+		return hook, err
+	}
+	err = json.Unmarshal(raw, &hook)
+	return hook, err
+}
+
+// ListHooks returns the webhooks registered on repo.
+func (c *Client) ListHooks(ctx context.Context, repo string) ([]Hook, error) {
+	raw, err := c.Get(ctx, "/repos/"+repo+"/hooks?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	var hooks []Hook
+	if err := json.Unmarshal(raw, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// DeleteHook removes a webhook from repo.
+func (c *Client) DeleteHook(ctx context.Context, repo string, id int64) error {
+	_, err := c.Do(ctx, http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%d", repo, id), nil)
+	return err
+}
+
+// PingHook asks GitHub to send a ping event to the webhook.
+func (c *Client) PingHook(ctx context.Context, repo string, id int64) error {
+	_, err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/hooks/%d/pings", repo, id), nil)
+	return err
+}
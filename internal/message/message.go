@@ -1,12 +1,30 @@
 package message
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // EventMessage is the JSONL envelope for GitHub webhook events.
 type EventMessage struct {
-	Type       string          `json:"type"`
-	Event      string          `json:"event"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	// Provider is "gitlab" or "gitea" for webhooks from those services, and
+	// empty for GitHub.
+	Provider   string          `json:"provider,omitempty"`
 	DeliveryID string          `json:"delivery_id"`
 	Truncated  bool            `json:"truncated"`
 	Payload    json.RawMessage `json:"payload"`
+	ReceivedAt time.Time       `json:"received_at,omitzero"`
+	// Verified is set when --verify-secret checked the delivery signature.
+	Verified *bool `json:"verified,omitempty"`
+	// Enrichment holds related resources fetched from the GitHub API with
+	// --enrich, or state a wait command tracked, such as the combined state
+	// of a commit's checks.
+	Enrichment json.RawMessage `json:"enrichment,omitempty"`
+	// Signature is the X-Hub-Signature-256 header forwarded by smee.io, or
+	// Gitea's X-Gitea-Signature in the same sha256=<hex> form.
+	Signature string `json:"-"`
+	// Token is GitLab's X-Gitlab-Token header, the webhook secret itself.
+	Token string `json:"-"`
 }
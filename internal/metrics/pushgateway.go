@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Sample is a single Prometheus sample pushed to a Pushgateway.
+type Sample struct {
+	Name   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Push replaces the metrics of job on the Pushgateway at baseURL.
+func Push(ctx context.Context, baseURL, job string, samples []Sample) error {
+	endpoint := strings.TrimRight(baseURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(encodeText(samples)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway: unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// encodeText renders samples in the Prometheus text exposition format, which
+// requires all samples of a metric family to be contiguous.
+func encodeText(samples []Sample) []byte {
+	sorted := append([]Sample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var buf bytes.Buffer
+	typed := make(map[string]bool)
+	for _, sample := range sorted {
+		if sample.Type != "" && !typed[sample.Name] {
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", sample.Name, sample.Type)
+			typed[sample.Name] = true
+		}
+		buf.WriteString(sample.Name)
+		if len(sample.Labels) > 0 {
+			keys := make([]string, 0, len(sample.Labels))
+			for key := range sample.Labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			pairs := make([]string, 0, len(keys))
+			for _, key := range keys {
+				value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(sample.Labels[key])
+				pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", key, value))
+			}
+			buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		fmt.Fprintf(&buf, " %g\n", sample.Value)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// StatsD sends counters and gauges to a StatsD daemon over UDP. Tags, when
+// set, are appended in DogStatsD format (|#key:value,...).
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	Tags   []string
+}
+
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd %s: %w", addr, err)
+	}
+	return &StatsD{conn: conn, prefix: prefix}, nil
+}
+
+func (s *StatsD) Count(name string, value int64) error {
+	return s.send(fmt.Sprintf("%s:%d|c", s.metric(name), value))
+}
+
+func (s *StatsD) Gauge(name string, value float64) error {
+	return s.send(fmt.Sprintf("%s:%g|g", s.metric(name), value))
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) metric(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "." + name
+}
+
+func (s *StatsD) send(line string) error {
+	if len(s.Tags) > 0 {
+		line += "|#" + strings.Join(s.Tags, ",")
+	}
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+// SanitizeName makes an arbitrary string usable as a metric name segment.
+func SanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
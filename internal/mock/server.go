@@ -0,0 +1,173 @@
+// Package mock serves a smee.io-compatible channel that broadcasts
+// scripted webhook deliveries, so gh-pulse and handlers can be tested
+// without GitHub or the network.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/webhook"
+)
+
+// keepAlive is how often idle subscribers get a comment line, as smee.io
+// sends.
+const keepAlive = 30 * time.Second
+
+// Server is an http.Handler. GET requests on any path subscribe to the
+// channel's Server-Sent Events; POST /play calls OnPlay.
+type Server struct {
+	// Secret, when set, signs every delivery with X-Hub-Signature-256.
+	Secret string
+	// OnPlay is called for POST /play, e.g. to start the scenario again.
+	OnPlay func()
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+	joined      chan struct{}
+	closing     chan struct{}
+	closeOnce   sync.Once
+	streams     sync.WaitGroup
+}
+
+func NewServer(secret string) *Server {
+	return &Server{
+		Secret:      secret,
+		subscribers: make(map[chan []byte]struct{}),
+		joined:      make(chan struct{}),
+		closing:     make(chan struct{}),
+	}
+}
+
+// Close ends every subscription once the deliveries already published have
+// been written, so nothing is lost when the channel shuts down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+	s.streams.Wait()
+}
+
+// Subscribed is closed once the first subscriber has connected.
+func (s *Server) Subscribed() <-chan struct{} {
+	return s.joined
+}
+
+// Subscribers returns how many clients are connected.
+func (s *Server) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+// Publish sends msg to every subscriber as smee.io would relay GitHub's
+// delivery.
+func (s *Server) Publish(msg message.EventMessage) error {
+	data := map[string]any{
+		"content-type":      "application/json",
+		"user-agent":        webhook.UserAgent,
+		"x-github-event":    msg.Event,
+		"x-github-delivery": msg.DeliveryID,
+		"body":              msg.Payload,
+		"timestamp":         time.Now().UnixMilli(),
+	}
+	if s.Secret != "" {
+		data["x-hub-signature-256"] = webhook.Sign(s.Secret, msg.Payload)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	frame := []byte("data: " + string(encoded) + "\n\n")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- frame:
+		default:
+			// A subscriber too slow to keep up misses the delivery, as
+			// it would from a real relay.
+		}
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/play":
+		if s.OnPlay != nil {
+			s.OnPlay()
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodGet:
+		s.subscribe(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, "event: ready\ndata: {}\n\n"); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ch := make(chan []byte, 256)
+	s.streams.Add(1)
+	defer s.streams.Done()
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	if len(s.subscribers) == 1 {
+		select {
+		case <-s.joined:
+		default:
+			close(s.joined)
+		}
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case frame := <-ch:
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+		case <-s.closing:
+			for {
+				select {
+				case frame := <-ch:
+					if _, err := w.Write(frame); err != nil {
+						return
+					}
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ":\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,247 @@
+// Package redact scrubs personal data and secrets from webhook payloads so
+// captures can be shared, e.g. attached to public bug reports.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"gopkg.in/yaml.v3"
+)
+
+// Mask replaces each redacted value, or the redacted part of a string.
+const Mask = "[redacted]"
+
+// Classes are the built-in redactions:
+//
+//	emails - email addresses anywhere in a string
+//	tokens - GitHub, AWS, and Slack tokens, JWTs, private keys, URL
+//	         credentials, and the values of token, secret, and password fields
+//	names  - the name of commit authors, committers, pushers, and taggers
+var Classes = []string{"emails", "tokens", "names"}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	tokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+		regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+		regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+		regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	}
+	// urlCredentials matches the user:password@ part of a URL; only the
+	// credentials are masked so the host stays readable.
+	urlCredentials = regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`)
+	secretKey      = regexp.MustCompile(`(?i)^(.*_)?(token|secret|password|api_key|private_key)$`)
+	nameParents    = map[string]bool{"author": true, "committer": true, "pusher": true, "tagger": true}
+)
+
+// Rules selects what a Redactor removes. A rules file has the same shape:
+//
+//	redact: [emails, tokens, names]
+//	keys: [access_tokens_url]             # fields with these names, anywhere
+//	paths: ['payload.commits[].message']  # fields at these paths
+//	patterns: ['corp\.example\.com']      # text matching these regexps
+type Rules struct {
+	Redact   []string `yaml:"redact"`
+	Keys     []string `yaml:"keys"`
+	Paths    []string `yaml:"paths"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// LoadRules reads redaction rules from a YAML file.
+func LoadRules(path string) (Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, fmt.Errorf("failed to read redact file: %w", err)
+	}
+	var rules Rules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return Rules{}, fmt.Errorf("invalid redact file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Redactor removes the values selected by its rules from event payloads.
+type Redactor struct {
+	emails   bool
+	tokens   bool
+	names    bool
+	keys     map[string]bool
+	paths    [][]string
+	patterns []*regexp.Regexp
+}
+
+// New validates rules and returns a Redactor for them.
+func New(rules Rules) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]bool)}
+	for _, class := range rules.Redact {
+		switch strings.TrimSpace(class) {
+		case "emails":
+			r.emails = true
+		case "tokens":
+			r.tokens = true
+		case "names":
+			r.names = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown redaction %q (expected %s)", class, strings.Join(Classes, ", "))
+		}
+	}
+	for _, key := range rules.Keys {
+		if key == "" {
+			return nil, fmt.Errorf("empty redact key")
+		}
+		r.keys[key] = true
+	}
+	for _, path := range rules.Paths {
+		keys, err := assertion.SplitPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact path: %w", err)
+		}
+		if keys[0] != "payload" && keys[0] != "enrichment" {
+			return nil, fmt.Errorf("invalid redact path %q: paths start with payload or enrichment", path)
+		}
+		r.paths = append(r.paths, keys)
+	}
+	for _, pattern := range rules.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Message returns msg with its payload and enrichment redacted, and how many
+// values were changed.
+func (r *Redactor) Message(msg message.EventMessage) (message.EventMessage, int, error) {
+	total := 0
+	for _, field := range []struct {
+		root string
+		raw  *json.RawMessage
+	}{{"payload", &msg.Payload}, {"enrichment", &msg.Enrichment}} {
+		redacted, n, err := r.document(field.root, *field.raw)
+		if err != nil {
+			return msg, 0, fmt.Errorf("invalid %s: %w", field.root, err)
+		}
+		*field.raw = redacted
+		total += n
+	}
+	return msg, total, nil
+}
+
+// document redacts one JSON document, re-encoding it only when something
+// changed so untouched payloads keep their original formatting.
+func (r *Redactor) document(root string, raw json.RawMessage) (json.RawMessage, int, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return raw, 0, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, 0, err
+	}
+	count := 0
+	value = r.walk(value, []string{root}, &count)
+	if count == 0 {
+		return raw, 0, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return encoded, count, nil
+}
+
+func (r *Redactor) walk(value any, keys []string, count *int) any {
+	if value != nil && r.whole(value, keys) {
+		*count++
+		return Mask
+	}
+	keys = keys[:len(keys):len(keys)]
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = r.walk(child, append(keys, k), count)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.walk(child, append(keys, strconv.Itoa(i)), count)
+		}
+	case string:
+		if text := r.text(v); text != v {
+			*count++
+			return text
+		}
+	}
+	return value
+}
+
+// whole reports whether the value at keys is removed entirely.
+func (r *Redactor) whole(value any, keys []string) bool {
+	last := keys[len(keys)-1]
+	if r.keys[last] {
+		return true
+	}
+	if s, ok := value.(string); ok && s != "" && s != Mask {
+		if r.tokens && secretKey.MatchString(last) {
+			return true
+		}
+		if r.names && last == "name" && len(keys) >= 2 && nameParents[keys[len(keys)-2]] {
+			return true
+		}
+	}
+	for _, path := range r.paths {
+		if pathMatches(path, keys) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) text(s string) string {
+	if r.emails {
+		s = emailPattern.ReplaceAllString(s, Mask)
+	}
+	if r.tokens {
+		for _, re := range tokenPatterns {
+			s = re.ReplaceAllString(s, Mask)
+		}
+		s = urlCredentials.ReplaceAllString(s, "${1}"+Mask+"@")
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// pathMatches reports whether keys is the field at path, with [] matching
+// any array index.
+func pathMatches(path, keys []string) bool {
+	if len(path) != len(keys) {
+		return false
+	}
+	for i, key := range path {
+		if assertion.IsProjection(key) {
+			if _, err := strconv.Atoi(keys[i]); err != nil {
+				return false
+			}
+			continue
+		}
+		if key != keys[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+// Package route loads the routing rules that forward events to local
+// services (--routes).
+package route
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/filter"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for routes that leave out a timeout, retry policy, or
+// concurrency.
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultAttempts   = 3
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultConcurrency is how many deliveries per-key and parallel
+	// routes have in flight.
+	DefaultConcurrency = 4
+)
+
+// Orderings a route can deliver with.
+const (
+	// Serial delivers one event at a time, in arrival order.
+	Serial = "serial"
+	// PerKey delivers events with the same Key value in arrival order, and
+	// events with different values in parallel.
+	PerKey = "per-key"
+	// Parallel delivers up to Concurrency events at once, in any order.
+	Parallel = "parallel"
+)
+
+// file is the YAML layout accepted by --routes:
+//
+//	retry:
+//	  attempts: 5
+//	routes:
+//	  - events: [pull_request]
+//	    target: http://reviewbot:3000/webhook
+//	  - events: [workflow_run]
+//	    match: [payload.workflow_run.conclusion=failure]
+//	    target: http://ci-gateway:8080/hooks/github
+//	    secret_env: CI_GATEWAY_SECRET
+//	    retry: {attempts: 10, backoff: 2s}
+//	  - events: [pull_request, pull_request_review]
+//	    target: http://reviewbot:3000/webhook
+//	    ordering: per-key
+//	    key: payload.pull_request.number
+type file struct {
+	Retry  Retry   `yaml:"retry"`
+	Routes []Route `yaml:"routes"`
+}
+
+// Route forwards the events it matches to Target.
+type Route struct {
+	// Name labels the route in logs and names its journal. It defaults to
+	// the target's host, followed by #n for the nth route when an earlier
+	// route already has that name.
+	Name string `yaml:"name"`
+	// Events, Actions, and Match select events: by type, by payload
+	// action, and by assertions that must all match. Empty selects all.
+	Events  []string `yaml:"events"`
+	Actions []string `yaml:"actions"`
+	Match   []string `yaml:"match"`
+	Target  string   `yaml:"target"`
+	// Secret, or the environment variable SecretEnv names, signs each
+	// delivery with X-Hub-Signature-256.
+	Secret    string `yaml:"secret"`
+	SecretEnv string `yaml:"secret_env"`
+	// Timeout limits each delivery attempt.
+	Timeout  time.Duration `yaml:"timeout"`
+	Retry    Retry         `yaml:"retry"`
+	Ordering Ordering      `yaml:",inline"`
+
+	match []assertion.Assertion
+}
+
+// Retry is how a route retries a delivery that failed with a network error,
+// a 5xx, 408, or 429 response: up to Attempts tries in all, waiting Backoff
+// and doubling it each time up to MaxBackoff.
+type Retry struct {
+	Attempts   int           `yaml:"attempts"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// Ordering is how a route's deliveries may overlap: Mode is Serial, PerKey
+// (by the value at the path Key), or Parallel, with up to Concurrency
+// deliveries in flight for the last two.
+type Ordering struct {
+	Mode        string `yaml:"ordering"`
+	Key         string `yaml:"key"`
+	Concurrency int    `yaml:"concurrency"`
+}
+
+// Load reads and checks a routes file. Routes that leave out their ordering
+// take it from defaults.
+func Load(path string, defaults Ordering) ([]Route, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes file: %w", err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid routes file %s: %w", path, err)
+	}
+	if len(f.Routes) == 0 {
+		return nil, fmt.Errorf("invalid routes file %s: no routes", path)
+	}
+	retry := f.Retry.withDefaults(Retry{Attempts: DefaultAttempts, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff})
+	for i := range f.Routes {
+		named := f.Routes[i].Name != ""
+		if err := f.Routes[i].init(retry, defaults); err != nil {
+			return nil, fmt.Errorf("invalid routes file %s: route %d: %w", path, i+1, err)
+		}
+		// Unnamed routes to the same host are told apart by their number.
+		if !named && slices.ContainsFunc(f.Routes[:i], func(r Route) bool { return r.Name == f.Routes[i].Name }) {
+			f.Routes[i].Name = fmt.Sprintf("%s#%d", f.Routes[i].Name, i+1)
+		}
+	}
+	return f.Routes, nil
+}
+
+func (r *Route) init(retry Retry, ordering Ordering) error {
+	target, err := url.Parse(r.Target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("target must be an http(s) URL, got %q", r.Target)
+	}
+	if r.Name == "" {
+		r.Name = target.Host
+	}
+	if r.SecretEnv != "" {
+		if r.Secret != "" {
+			return fmt.Errorf("secret and secret_env cannot both be set")
+		}
+		r.Secret = os.Getenv(r.SecretEnv)
+		if r.Secret == "" {
+			return fmt.Errorf("secret_env %s is not set", r.SecretEnv)
+		}
+	}
+	if r.match, err = assertion.ParseAssertions(r.Match, 0); err != nil {
+		return err
+	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("timeout must be non-negative")
+	}
+	if r.Timeout == 0 {
+		r.Timeout = DefaultTimeout
+	}
+	r.Retry = r.Retry.withDefaults(retry)
+	if r.Retry.Attempts < 1 || r.Retry.Backoff < 0 || r.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("retry attempts must be at least 1 and backoffs non-negative")
+	}
+	r.Ordering = r.Ordering.withDefaults(ordering)
+	switch r.Ordering.Mode {
+	case Serial, Parallel:
+	case PerKey:
+		if r.Ordering.Key == "" {
+			return fmt.Errorf("ordering %s needs a key", PerKey)
+		}
+	default:
+		return fmt.Errorf("ordering must be %s, %s, or %s, got %q", Serial, PerKey, Parallel, r.Ordering.Mode)
+	}
+	if r.Ordering.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	return nil
+}
+
+// withDefaults fills the fields o leaves out from defaults, then Serial.
+func (o Ordering) withDefaults(defaults Ordering) Ordering {
+	if o.Mode == "" {
+		o.Mode = defaults.Mode
+	}
+	if o.Mode == "" {
+		o.Mode = Serial
+	}
+	if o.Key == "" {
+		o.Key = defaults.Key
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = defaults.Concurrency
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	return o
+}
+
+// withDefaults fills the fields r leaves out from defaults.
+func (r Retry) withDefaults(defaults Retry) Retry {
+	if r.Attempts == 0 {
+		r.Attempts = defaults.Attempts
+	}
+	if r.Backoff == 0 {
+		r.Backoff = defaults.Backoff
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = defaults.MaxBackoff
+	}
+	return r
+}
+
+// Matches reports whether the route selects an event.
+func (r *Route) Matches(event string, doc assertion.Document) bool {
+	if !filter.Event(r.Events, event) {
+		return false
+	}
+	if len(r.Actions) > 0 {
+		action, _ := doc.Lookup("payload.action")
+		if !slices.Contains(r.Actions, action) {
+			return false
+		}
+	}
+	for _, a := range r.match {
+		if !doc.MatchAny([]assertion.Assertion{a}) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrderKey returns the value a PerKey route orders the event data by; events
+// without one share the empty key.
+func (r *Route) OrderKey(data []byte) string {
+	key, _ := assertion.Lookup(data, r.Ordering.Key)
+	return key
+}
+
+// Retryable reports whether a delivery that got status, or err, is worth
+// trying again.
+func Retryable(status int, err error) bool {
+	return err != nil || status >= 500 || status == 408 || status == 429
+}
+
+// Delay returns how long to wait before retry n (1 for the first retry).
+func (r Retry) Delay(n int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < n && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.MaxBackoff)
+}
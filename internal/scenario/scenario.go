@@ -0,0 +1,376 @@
+// Package scenario loads scripted sequences of webhook events and plays
+// them in order, for driving handlers and tests without GitHub.
+package scenario
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/fixture"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed scenarios/*.yaml
+var builtin embed.FS
+
+// variable matches a ${name} reference in a step field.
+var variable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Scenario is a named list of steps. Repo and Sender are the defaults for
+// steps that don't set their own, Vars the initial ${name} values, and
+// Templates named steps that others build on with use.
+type Scenario struct {
+	Name      string            `yaml:"name"`
+	Repo      string            `yaml:"repo"`
+	Sender    string            `yaml:"sender"`
+	Vars      map[string]string `yaml:"vars"`
+	Templates map[string]Step   `yaml:"templates"`
+	Steps     []Step            `yaml:"steps"`
+}
+
+// Step generates one event from the event's built-in template, after
+// waiting Delay, or with Repeat runs its nested Steps that many times.
+// String fields and Number may refer to variables as ${name}.
+type Step struct {
+	// Use starts the step from a named template; fields set on the step
+	// replace the template's, and Set is appended to it.
+	Use     string        `yaml:"use"`
+	Event   string        `yaml:"event"`
+	Delay   time.Duration `yaml:"delay"`
+	Repo    string        `yaml:"repo"`
+	Sender  string        `yaml:"sender"`
+	Action  string        `yaml:"action"`
+	Ref     string        `yaml:"ref"`
+	Number  string        `yaml:"number"`
+	Commits int           `yaml:"commits"`
+	Tag     string        `yaml:"tag"`
+	// Set overrides fields as path=value, like generate --set.
+	Set []string `yaml:"set"`
+	// Save stores values from the generated event as variables for later
+	// steps, as name: path (e.g. sha: payload.pull_request.head.sha).
+	Save map[string]string `yaml:"save"`
+	// Repeat runs Steps this many times with ${i} counting from 1.
+	Repeat int    `yaml:"repeat"`
+	Steps  []Step `yaml:"steps"`
+}
+
+// Emitted is an event played by a scenario, with the total delay before it
+// since the start of the play.
+type Emitted struct {
+	Message message.EventMessage
+	Offset  time.Duration
+}
+
+// Builtin lists the names of the scenarios shipped with gh-pulse.
+func Builtin() []string {
+	entries, _ := fs.ReadDir(builtin, "scenarios")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load reads a scenario file, or a built-in scenario by name when no such
+// file exists.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if embedded, embedErr := builtin.ReadFile("scenarios/" + strings.TrimSuffix(path, ".yaml") + ".yaml"); embedErr == nil {
+			data, err = embedded, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func (s *Scenario) validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	for name, tmpl := range s.Templates {
+		if tmpl.Use != "" || tmpl.Repeat != 0 || len(tmpl.Steps) > 0 {
+			return fmt.Errorf("template %s: templates cannot use other templates or repeat", name)
+		}
+	}
+	return s.validateSteps(s.Steps, "step ")
+}
+
+func (s *Scenario) validateSteps(steps []Step, prefix string) error {
+	known := fixture.Events()
+	for i, step := range steps {
+		where := fmt.Sprintf("%s%d", prefix, i+1)
+		if step.Delay < 0 {
+			return fmt.Errorf("%s: delay must be non-negative", where)
+		}
+		if step.Repeat != 0 || len(step.Steps) > 0 {
+			if step.Repeat < 1 {
+				return fmt.Errorf("%s: repeat must be at least 1", where)
+			}
+			if len(step.Steps) == 0 {
+				return fmt.Errorf("%s: repeat needs steps", where)
+			}
+			if step.Event != "" || step.Use != "" {
+				return fmt.Errorf("%s: a repeat step cannot also have an event", where)
+			}
+			if err := s.validateSteps(step.Steps, where+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		step, err := s.resolve(step)
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if step.Event == "" {
+			return fmt.Errorf("%s: missing event", where)
+		}
+		if !slices.Contains(known, step.Event) {
+			return fmt.Errorf("%s: no template for event %q (known: %s)", where, step.Event, strings.Join(known, ", "))
+		}
+		for name, path := range step.Save {
+			if !variable.MatchString("${" + name + "}") {
+				return fmt.Errorf("%s: invalid variable name %q", where, name)
+			}
+			if err := assertion.ValidatePath(path); err != nil {
+				return fmt.Errorf("%s: invalid save path for %s: %w", where, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve merges a step onto the template it uses.
+func (s *Scenario) resolve(step Step) (Step, error) {
+	if step.Use == "" {
+		return step, nil
+	}
+	tmpl, ok := s.Templates[step.Use]
+	if !ok {
+		return Step{}, fmt.Errorf("unknown template %q", step.Use)
+	}
+	merged := tmpl
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&merged.Event, step.Event}, {&merged.Repo, step.Repo}, {&merged.Sender, step.Sender},
+		{&merged.Action, step.Action}, {&merged.Ref, step.Ref}, {&merged.Number, step.Number}, {&merged.Tag, step.Tag},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+		}
+	}
+	if step.Delay > 0 {
+		merged.Delay = step.Delay
+	}
+	if step.Commits > 0 {
+		merged.Commits = step.Commits
+	}
+	merged.Set = append(slices.Clone(tmpl.Set), step.Set...)
+	merged.Save = make(map[string]string, len(tmpl.Save)+len(step.Save))
+	for name, path := range tmpl.Save {
+		merged.Save[name] = path
+	}
+	for name, path := range step.Save {
+		merged.Save[name] = path
+	}
+	merged.Use = ""
+	return merged, nil
+}
+
+// Play generates each step's event in order, waiting out its delay, and
+// passes it to emit. It stops at the first error, or when ctx is done.
+func (s *Scenario) Play(ctx context.Context, emit func(message.EventMessage) error) error {
+	p := &player{scenario: s, vars: s.initialVars(), wait: true}
+	return p.run(ctx, s.Steps, func(e Emitted) error { return emit(e.Message) })
+}
+
+// Events generates every event without waiting, each with the delay it
+// would have been played at.
+func (s *Scenario) Events() ([]Emitted, error) {
+	var events []Emitted
+	p := &player{scenario: s, vars: s.initialVars()}
+	err := p.run(context.Background(), s.Steps, func(e Emitted) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+func (s *Scenario) initialVars() map[string]string {
+	vars := make(map[string]string, len(s.Vars))
+	for name, value := range s.Vars {
+		vars[name] = value
+	}
+	return vars
+}
+
+// player holds the variables and elapsed delay of one play.
+type player struct {
+	scenario *Scenario
+	vars     map[string]string
+	offset   time.Duration
+	// wait makes delays real; otherwise they only add to offset.
+	wait bool
+}
+
+func (p *player) run(ctx context.Context, steps []Step, emit func(Emitted) error) error {
+	for _, step := range steps {
+		if err := p.delay(ctx, step.Delay); err != nil {
+			return err
+		}
+		if step.Repeat > 0 {
+			outer, hadOuter := p.vars["i"]
+			for i := 1; i <= step.Repeat; i++ {
+				p.vars["i"] = strconv.Itoa(i)
+				if err := p.run(ctx, step.Steps, emit); err != nil {
+					return err
+				}
+			}
+			if hadOuter {
+				p.vars["i"] = outer
+			} else {
+				delete(p.vars, "i")
+			}
+			continue
+		}
+		step, err := p.scenario.resolve(step)
+		if err != nil {
+			return err
+		}
+		opts, err := p.options(step)
+		if err != nil {
+			return fmt.Errorf("%s step: %w", step.Event, err)
+		}
+		msg, err := fixture.Generate(step.Event, opts)
+		if err != nil {
+			return err
+		}
+		if err := p.save(step, msg); err != nil {
+			return err
+		}
+		if err := emit(Emitted{Message: msg, Offset: p.offset}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *player) delay(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	p.offset += d
+	if !p.wait {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// options expands a step's variables into generate options.
+func (p *player) options(step Step) (fixture.Options, error) {
+	var err error
+	expand := func(value string) string {
+		if err != nil {
+			return ""
+		}
+		var expanded string
+		expanded, err = p.expand(value)
+		return expanded
+	}
+	opts := fixture.Options{
+		Repo:    expand(step.Repo),
+		Sender:  expand(step.Sender),
+		Action:  expand(step.Action),
+		Ref:     expand(step.Ref),
+		Commits: step.Commits,
+		Tag:     expand(step.Tag),
+	}
+	for _, set := range step.Set {
+		opts.Set = append(opts.Set, expand(set))
+	}
+	if number := expand(step.Number); number != "" && err == nil {
+		if opts.Number, err = strconv.Atoi(number); err != nil {
+			err = fmt.Errorf("number must be an integer, got %q", number)
+		}
+	}
+	if err != nil {
+		return fixture.Options{}, err
+	}
+	if opts.Repo == "" {
+		opts.Repo = p.scenario.Repo
+	}
+	if opts.Sender == "" {
+		opts.Sender = p.scenario.Sender
+	}
+	return opts, nil
+}
+
+// expand replaces each ${name} in value, failing on undefined variables.
+func (p *player) expand(value string) (string, error) {
+	var missing string
+	expanded := variable.ReplaceAllStringFunc(value, func(ref string) string {
+		name := variable.FindStringSubmatch(ref)[1]
+		v, ok := p.vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable ${%s}", missing)
+	}
+	return expanded, nil
+}
+
+// save stores the values a step asks for from its generated event.
+func (p *player) save(step Step, msg message.EventMessage) error {
+	if len(step.Save) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	doc, err := assertion.Decode(encoded)
+	if err != nil {
+		return err
+	}
+	for name, path := range step.Save {
+		value, ok := doc.Lookup(path)
+		if !ok {
+			return fmt.Errorf("%s step: nothing at %s to save as %s", step.Event, path, name)
+		}
+		p.vars[name] = value
+	}
+	return nil
+}
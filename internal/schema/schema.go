@@ -0,0 +1,126 @@
+// Package schema validates webhook payloads against JSON schemas for the
+// common GitHub event types.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+//go:embed schemas/*.json
+var embedded embed.FS
+
+const baseURL = "gh-pulse:///schemas/"
+
+// Validator checks payloads against per-event schemas.
+type Validator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewValidator compiles the embedded schemas. Files named <event>.json in
+// dir, when set, override or extend them and may reference common.json.
+func NewValidator(dir string) (*Validator, error) {
+	docs := make(map[string][]byte)
+	if err := collect(embedded, "schemas", docs); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := collect(os.DirFS(dir), ".", docs); err != nil {
+			return nil, fmt.Errorf("failed to read schema dir: %w", err)
+		}
+	}
+
+	compiler := jsonschema.NewCompiler()
+	for name, data := range docs {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", name, err)
+		}
+		if err := compiler.AddResource(baseURL+name, doc); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", name, err)
+		}
+	}
+
+	v := &Validator{schemas: make(map[string]*jsonschema.Schema)}
+	for name := range docs {
+		if name == "common.json" {
+			continue
+		}
+		compiled, err := compiler.Compile(baseURL + name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", name, err)
+		}
+		v.schemas[strings.TrimSuffix(name, ".json")] = compiled
+	}
+	return v, nil
+}
+
+func collect(fsys fs.FS, dir string, docs map[string][]byte) error {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		data, err := fs.ReadFile(fsys, match)
+		if err != nil {
+			return err
+		}
+		docs[path.Base(match)] = data
+	}
+	return nil
+}
+
+// Events lists the event types that have a schema.
+func (v *Validator) Events() []string {
+	events := make([]string, 0, len(v.schemas))
+	for event := range v.schemas {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Validate checks payload against the schema for event. checked is false when
+// no schema exists for the event type.
+func (v *Validator) Validate(event string, payload json.RawMessage) (checked bool, problems []string) {
+	compiled, ok := v.schemas[event]
+	if !ok {
+		return false, nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return true, []string{fmt.Sprintf("payload is not valid JSON: %v", err)}
+	}
+	err = compiled.Validate(doc)
+	if err == nil {
+		return true, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return true, []string{err.Error()}
+	}
+	return true, leafProblems(validationErr.BasicOutput())
+}
+
+func leafProblems(unit *jsonschema.OutputUnit) []string {
+	var problems []string
+	for _, cause := range unit.Errors {
+		if cause.Error == nil || len(cause.Errors) > 0 {
+			problems = append(problems, leafProblems(&cause)...)
+			continue
+		}
+		location := "payload" + strings.ReplaceAll(cause.InstanceLocation, "/", ".")
+		problems = append(problems, fmt.Sprintf("%s: %s", location, cause.Error.String()))
+	}
+	return problems
+}
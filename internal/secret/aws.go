@@ -0,0 +1,201 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsSecrets reads a secret from AWS Secrets Manager's GetSecretValue API,
+// signed with Signature Version 4. It takes the region and credentials from
+// the standard environment variables; a JSON key picks one field of a secret
+// stored as a JSON object.
+type awsSecrets struct {
+	id       string
+	key      string
+	region   string
+	endpoint string
+	creds    awsCredentials
+	http     *http.Client
+}
+
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func newAWS(id, key string) (*awsSecrets, error) {
+	a := &awsSecrets{
+		id:  id,
+		key: key,
+		creds: awsCredentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		http: &http.Client{Timeout: fetchTimeout},
+	}
+	a.region = os.Getenv("AWS_REGION")
+	if a.region == "" {
+		a.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if a.region == "" {
+		return nil, fmt.Errorf("aws-sm needs AWS_REGION")
+	}
+	if a.creds.accessKey == "" || a.creds.secretKey == "" {
+		return nil, fmt.Errorf("aws-sm needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	for _, name := range []string{"AWS_ENDPOINT_URL_SECRETS_MANAGER", "AWS_ENDPOINT_URL"} {
+		if a.endpoint = os.Getenv(name); a.endpoint != "" {
+			break
+		}
+	}
+	if a.endpoint == "" {
+		a.endpoint = "https://secretsmanager." + a.region + ".amazonaws.com"
+	}
+	return a, nil
+}
+
+func (a *awsSecrets) Fetch(ctx context.Context) (string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": a.id})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWS(req, body, a.creds, a.region, "secretsmanager", time.Now())
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		SecretString string `json:"SecretString"`
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("aws-sm: invalid response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		kind := out.Type[strings.LastIndex(out.Type, "#")+1:]
+		return "", fmt.Errorf("aws-sm: %s: %s %s", resp.Status, kind, out.Message)
+	}
+	if a.key == "" {
+		if out.SecretString == "" {
+			return "", fmt.Errorf("aws-sm: %s has no secret string", a.id)
+		}
+		return out.SecretString, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("aws-sm: %s is not a JSON object", a.id)
+	}
+	value, ok := fields[a.key].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("aws-sm: %s has no string key %q", a.id, a.key)
+	}
+	return value, nil
+}
+
+func (a *awsSecrets) String() string {
+	if a.key != "" {
+		return "aws-sm:" + a.id + "#" + a.key
+	}
+	return "aws-sm:" + a.id
+}
+
+// signAWS adds Signature Version 4 headers to req, signing every header it
+// has set along with Host and the body.
+func signAWS(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as
+// Signature Version 4 requires.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,131 @@
+// Package secret reads webhook secrets from files and secret managers, so
+// they needn't be passed in flags or environment variables where ps and CI
+// logs can show them.
+package secret
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fetchTimeout limits each read of a secret.
+const fetchTimeout = 30 * time.Second
+
+// Source reads a secret's current value.
+type Source interface {
+	Fetch(ctx context.Context) (string, error)
+	// String names the source in logs, without the secret.
+	String() string
+}
+
+// Parse reads a secret manager reference:
+//
+//	vault:<path>#<field>            a Vault KV secret (VAULT_ADDR, VAULT_TOKEN)
+//	aws-sm:<secret-id>[#<json-key>] an AWS Secrets Manager secret (AWS_REGION and credentials from the environment)
+func Parse(ref string) (Source, error) {
+	kind, rest, _ := strings.Cut(ref, ":")
+	name, field, _ := strings.Cut(rest, "#")
+	switch kind {
+	case "vault":
+		if name == "" || field == "" {
+			return nil, fmt.Errorf("vault secrets are vault:<path>#<field>, got %q", ref)
+		}
+		return newVault(name, field)
+	case "aws-sm":
+		if name == "" {
+			return nil, fmt.Errorf("aws-sm secrets are aws-sm:<secret-id>[#<json-key>], got %q", ref)
+		}
+		return newAWS(name, field)
+	default:
+		return nil, fmt.Errorf("unknown secret manager %q: must be vault:<path>#<field> or aws-sm:<secret-id>", kind)
+	}
+}
+
+// File reads a secret from path, ignoring a trailing newline.
+func File(path string) Source {
+	return file(path)
+}
+
+type file string
+
+func (f file) Fetch(context.Context) (string, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", string(f))
+	}
+	return value, nil
+}
+
+func (f file) String() string {
+	return string(f)
+}
+
+// Secret is a secret read from a Source and, when refresh is set, read again
+// that often for the life of the process, so a rotated secret is picked up
+// without a restart. A failed refresh keeps the current value.
+type Secret struct {
+	source Source
+	logger *log.Logger
+
+	mu    sync.RWMutex
+	value string
+}
+
+// New reads src, failing if it can't, and starts refreshing it.
+func New(src Source, refresh time.Duration, logger *log.Logger) (*Secret, error) {
+	s := &Secret{source: src, logger: logger}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	value, err := src.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from %s: %w", src, err)
+	}
+	s.value = value
+	if refresh > 0 {
+		go s.refreshEvery(refresh)
+	}
+	return s, nil
+}
+
+// Value returns the secret's current value.
+func (s *Secret) Value() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+func (s *Secret) refreshEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		value, err := s.source.Fetch(ctx)
+		cancel()
+		if err != nil {
+			s.logf("failed to refresh secret from %s, keeping the current one: %v", s.source, err)
+			continue
+		}
+		s.mu.Lock()
+		changed := value != s.value
+		s.value = value
+		s.mu.Unlock()
+		if changed {
+			s.logf("secret from %s changed", s.source)
+		}
+	}
+}
+
+func (s *Secret) logf(format string, args ...any) {
+	if s.logger != nil {
+		s.logger.Printf(format, args...)
+	}
+}
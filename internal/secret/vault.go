@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vault reads a field of a Vault KV secret, version 1 or 2, over Vault's
+// HTTP API. Like the vault CLI, it takes the server from VAULT_ADDR and the
+// token from VAULT_TOKEN or ~/.vault-token.
+type vault struct {
+	addr      string
+	token     string
+	namespace string
+	path      string
+	field     string
+	http      *http.Client
+}
+
+func newVault(path, field string) (*vault, error) {
+	v := &vault{
+		addr:      strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		path:      strings.Trim(path, "/"),
+		field:     field,
+		http:      &http.Client{Timeout: fetchTimeout},
+	}
+	if v.addr == "" {
+		v.addr = "https://127.0.0.1:8200"
+	}
+	if v.token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				v.token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if v.token == "" {
+		return nil, fmt.Errorf("vault needs VAULT_TOKEN or ~/.vault-token")
+	}
+	return v, nil
+}
+
+func (v *vault) Fetch(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+v.path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s", resp.Status)
+	}
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: invalid response: %w", err)
+	}
+	data := body.Data
+	// KV version 2 nests the secret's fields under data.data.
+	if nested, ok := data["data"]; ok && data["metadata"] != nil {
+		data = nil
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return "", fmt.Errorf("vault: invalid response: %w", err)
+		}
+	}
+	var value string
+	if raw, ok := data[v.field]; !ok || json.Unmarshal(raw, &value) != nil || value == "" {
+		return "", fmt.Errorf("vault: %s has no string field %q", v.path, v.field)
+	}
+	return value, nil
+}
+
+func (v *vault) String() string {
+	return "vault:" + v.path + "#" + v.field
+}
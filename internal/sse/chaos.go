@@ -0,0 +1,83 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errChaosDrop ends a stream that Chaos chose to drop.
+var errChaosDrop = errors.New("chaos: dropped connection")
+
+// Chaos injects faults into a client's stream so reconnects, deduplication,
+// and timeouts can be exercised in integration tests.
+type Chaos struct {
+	// Drop is the probability of closing the connection after an event.
+	Drop float64
+	// Delay is the longest an event is held before it is handled; each
+	// event waits a random time up to it.
+	Delay time.Duration
+	// Duplicate is the probability of handling an event twice.
+	Duplicate float64
+
+	rand *rand.Rand
+}
+
+// ParseChaos parses a comma-separated list of drop=P, delay=D, dup=P, and
+// seed=N settings, e.g. "drop=0.05,delay=200ms,dup=0.1". Without a seed the
+// faults differ on every run.
+func ParseChaos(spec string) (*Chaos, error) {
+	chaos := &Chaos{}
+	seed := rand.Uint64()
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid chaos setting %q (expected key=value)", part)
+		}
+		var err error
+		switch key {
+		case "drop":
+			chaos.Drop, err = parseProbability(value)
+		case "dup":
+			chaos.Duplicate, err = parseProbability(value)
+		case "delay":
+			chaos.Delay, err = time.ParseDuration(value)
+			if err == nil && chaos.Delay < 0 {
+				err = fmt.Errorf("must be non-negative")
+			}
+		case "seed":
+			seed, err = strconv.ParseUint(value, 10, 64)
+		default:
+			return nil, fmt.Errorf("unknown chaos setting %q (expected drop, delay, dup, or seed)", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid chaos %s=%s: %v", key, value, err)
+		}
+	}
+	chaos.rand = rand.New(rand.NewPCG(seed, seed))
+	return chaos, nil
+}
+
+func parseProbability(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || p < 0 || p > 1 {
+		return 0, fmt.Errorf("must be a probability between 0 and 1")
+	}
+	return p, nil
+}
+
+func (c *Chaos) chance(p float64) bool {
+	return p > 0 && c.rand.Float64() < p
+}
+
+// delay waits a random time up to Delay.
+func (c *Chaos) delay(ctx context.Context) {
+	if c.Delay <= 0 {
+		return
+	}
+	wait(ctx, time.Duration(c.rand.Int64N(int64(c.Delay)+1)))
+}
@@ -3,6 +3,8 @@ package sse
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/kehao95/gh-pulse/internal/message"
@@ -19,10 +22,60 @@ type Client struct {
 	URL        string
 	HTTPClient *http.Client
 	Logger     *log.Logger
+	// MaxAttempts is how many consecutive connection attempts may fail
+	// before Run gives up with a *ConnectError; 0 retries forever.
+	MaxAttempts int
+	// Generic accepts deliveries without GitHub headers, for relaying
+	// other services' webhooks. The event name is read from EventHeader
+	// when set, and is "webhook" when no header names it; deliveries
+	// without an ID get one derived from their content.
+	Generic     bool
+	EventHeader string
+	// OnReady, when set, is called each time the channel confirms the
+	// subscription with a ready event.
+	OnReady func()
+	// Chaos, when set, randomly delays, duplicates, and drops the stream's
+	// events for resilience testing.
+	Chaos *Chaos
+
+	connects  atomic.Int64
+	connected atomic.Bool
 }
 
+// ConnectError reports that the channel could not be reached within
+// MaxAttempts consecutive attempts.
+type ConnectError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("giving up after %d failed connection attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// NewClient returns a client for an http(s) channel URL, or a unix:// URL
+// naming a socket the relay listens on.
 func NewClient(url string, logger *log.Logger) *Client {
-	return &Client{URL: url, HTTPClient: http.DefaultClient, Logger: logger}
+	httpClient := http.DefaultClient
+	if socket, _, ok := splitUnixURL(url); ok {
+		httpClient = unixHTTPClient(socket)
+	}
+	return &Client{URL: url, HTTPClient: httpClient, Logger: logger}
+}
+
+// Reconnects returns how many times Run has connected again after its first
+// successful connection.
+func (c *Client) Reconnects() int64 {
+	return max(c.connects.Load()-1, 0)
+}
+
+// Connected reports whether Run currently has the stream open.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
 }
 
 func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) error) error {
@@ -31,6 +84,7 @@ func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) erro
 		client = http.DefaultClient
 	}
 	backoff := time.Second
+	failures := 0
 
 	for {
 		if ctx.Err() != nil {
@@ -41,7 +95,7 @@ func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) erro
 			c.Logger.Printf("connecting to %s", c.URL)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.requestURL(), nil)
 		if err != nil {
 			return err
 		}
@@ -49,9 +103,16 @@ func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) erro
 
 		resp, err := client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if c.Logger != nil {
 				c.Logger.Printf("connect failed: %v", err)
 			}
+			failures++
+			if c.MaxAttempts > 0 && failures >= c.MaxAttempts {
+				return &ConnectError{Attempts: failures, Err: err}
+			}
 			wait(ctx, backoff)
 			backoff = nextBackoff(backoff)
 			continue
@@ -62,6 +123,10 @@ func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) erro
 				c.Logger.Printf("unexpected status: %s", resp.Status)
 			}
 			_ = resp.Body.Close()
+			failures++
+			if c.MaxAttempts > 0 && failures >= c.MaxAttempts {
+				return &ConnectError{Attempts: failures, Err: fmt.Errorf("unexpected status: %s", resp.Status)}
+			}
 			wait(ctx, backoff)
 			backoff = nextBackoff(backoff)
 			continue
@@ -71,9 +136,13 @@ func (c *Client) Run(ctx context.Context, handle func(message.EventMessage) erro
 			c.Logger.Printf("connected to %s", c.URL)
 		}
 		backoff = time.Second
+		failures = 0
+		c.connects.Add(1)
+		c.connected.Store(true)
 
 		err = c.readStream(ctx, resp.Body, handle)
 		_ = resp.Body.Close()
+		c.connected.Store(false)
 
 		if errors.Is(err, context.Canceled) {
 			return err
@@ -121,9 +190,36 @@ func (e streamError) Unwrap() error {
 }
 
 type smeePayload struct {
-	Event      string      `json:"x-github-event"`
-	DeliveryID string      `json:"x-github-delivery"`
-	Body       interface{} `json:"body"`
+	Event        string          `json:"x-github-event"`
+	DeliveryID   string          `json:"x-github-delivery"`
+	Signature256 string          `json:"x-hub-signature-256"`
+	Body         json.RawMessage `json:"body"`
+
+	GitLabEvent     string `json:"x-gitlab-event"`
+	GitLabEventUUID string `json:"x-gitlab-event-uuid"`
+	GitLabToken     string `json:"x-gitlab-token"`
+	GiteaEvent      string `json:"x-gitea-event"`
+	GiteaDelivery   string `json:"x-gitea-delivery"`
+	GiteaSignature  string `json:"x-gitea-signature"`
+}
+
+// provider recognizes GitLab and Gitea deliveries by their headers and
+// returns their normalized event name, delivery ID, and signature. Gitea
+// also sends GitHub's headers, so it is checked first.
+func (p smeePayload) provider() (provider, event, deliveryID, signature string) {
+	switch {
+	case p.GiteaEvent != "":
+		if p.GiteaSignature != "" {
+			signature = "sha256=" + p.GiteaSignature
+		}
+		return "gitea", p.GiteaEvent, p.GiteaDelivery, signature
+	case p.GitLabEvent != "":
+		// "Merge Request Hook" becomes merge_request, matching the body's
+		// object_kind.
+		event = strings.TrimSuffix(strings.ToLower(p.GitLabEvent), " hook")
+		return "gitlab", strings.ReplaceAll(event, " ", "_"), p.GitLabEventUUID, ""
+	}
+	return "", p.Event, p.DeliveryID, p.Signature256
 }
 
 type sseEvent struct {
@@ -153,11 +249,14 @@ func (c *Client) readStream(ctx context.Context, body io.Reader, handle func(mes
 				continue
 			}
 			if current.event == "ready" {
+				if c.OnReady != nil {
+					c.OnReady()
+				}
 				current = sseEvent{}
 				continue
 			}
 
-			payload, err := decodeSmeeData(strings.Join(current.data, "\n"))
+			payload, err := c.decode(strings.Join(current.data, "\n"))
 			if err != nil {
 				if c.Logger != nil {
 					c.Logger.Printf("failed to decode smee payload: %v", err)
@@ -166,9 +265,22 @@ func (c *Client) readStream(ctx context.Context, body io.Reader, handle func(mes
 				continue
 			}
 
+			if c.Chaos != nil {
+				c.Chaos.delay(ctx)
+			}
 			if err := handle(payload); err != nil {
 				return err
 			}
+			if c.Chaos != nil {
+				if c.Chaos.chance(c.Chaos.Duplicate) {
+					if err := handle(payload); err != nil {
+						return err
+					}
+				}
+				if c.Chaos.chance(c.Chaos.Drop) {
+					return streamError{err: errChaosDrop}
+				}
+			}
 
 			current = sseEvent{}
 			continue
@@ -201,32 +313,64 @@ func splitSSELine(line string) (string, string) {
 	return field, value
 }
 
-func decodeSmeeData(raw string) (message.EventMessage, error) {
+func (c *Client) decode(raw string) (message.EventMessage, error) {
 	var payload smeePayload
 	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
 		return message.EventMessage{}, err
 	}
-	if payload.Event == "" {
-		return message.EventMessage{}, fmt.Errorf("missing x-github-event")
+	provider, event, deliveryID, signature := payload.provider()
+	if c.EventHeader != "" {
+		if value := smeeHeader(raw, c.EventHeader); value != "" {
+			event = value
+		}
 	}
-	if payload.DeliveryID == "" {
-		return message.EventMessage{}, fmt.Errorf("missing x-github-delivery")
+	if event == "" {
+		if !c.Generic {
+			return message.EventMessage{}, fmt.Errorf("missing x-github-event")
+		}
+		event = "webhook"
+	}
+	if deliveryID == "" {
+		if !c.Generic && provider != "gitlab" {
+			return message.EventMessage{}, fmt.Errorf("missing x-github-delivery")
+		}
+		// Older GitLab versions send no delivery ID.
+		sum := sha256.Sum256([]byte(raw))
+		deliveryID = hex.EncodeToString(sum[:16])
 	}
 
-	body, err := json.Marshal(payload.Body)
-	if err != nil {
-		return message.EventMessage{}, fmt.Errorf("failed to encode smee body: %w", err)
+	body := payload.Body
+	if len(body) == 0 {
+		body = json.RawMessage("null")
 	}
 
 	return message.EventMessage{
 		Type:       "event",
-		Event:      payload.Event,
-		DeliveryID: payload.DeliveryID,
+		Event:      event,
+		Provider:   provider,
+		DeliveryID: deliveryID,
 		Truncated:  false,
 		Payload:    body,
+		ReceivedAt: time.Now().UTC(),
+		Signature:  signature,
+		Token:      payload.GitLabToken,
 	}, nil
 }
 
+// smeeHeader returns a forwarded request header, which smee.io stores under
+// its lowercased name, or "" when it is missing or not a string.
+func smeeHeader(raw, name string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(fields[strings.ToLower(name)], &value); err != nil {
+		return ""
+	}
+	return value
+}
+
 func wait(ctx context.Context, d time.Duration) {
 	timer := time.NewTimer(d)
 	defer timer.Stop()
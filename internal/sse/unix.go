@@ -0,0 +1,55 @@
+package sse
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// splitUnixURL splits a unix:// channel URL into the socket path and the HTTP
+// path requested over it. The socket path runs through the first path
+// segment ending in ".sock", so unix:///run/relay.sock/my-channel requests
+// /my-channel from /run/relay.sock; without such a segment the whole path is
+// the socket and / is requested.
+func splitUnixURL(raw string) (socket, path string, ok bool) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "unix" || parsed.Path == "" {
+		return "", "", false
+	}
+	socket, path = parsed.Path, "/"
+	segments := strings.Split(parsed.Path, "/")
+	for i, segment := range segments {
+		if strings.HasSuffix(segment, ".sock") && i < len(segments)-1 {
+			socket = strings.Join(segments[:i+1], "/")
+			path = "/" + strings.Join(segments[i+1:], "/")
+			break
+		}
+	}
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	return socket, path, true
+}
+
+// requestURL returns the URL to request for the channel, rewriting unix://
+// URLs to the HTTP path served on the socket.
+func (c *Client) requestURL() string {
+	if _, path, ok := splitUnixURL(c.URL); ok {
+		return "http://unix" + path
+	}
+	return c.URL
+}
+
+// unixHTTPClient dials socket for every request regardless of the host.
+func unixHTTPClient(socket string) *http.Client {
+	var dialer net.Dialer
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
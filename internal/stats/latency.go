@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// SkewTolerance is how far ahead of the receive time an event's own
+// timestamp may be before it is counted as clock skew rather than latency.
+// GitHub timestamps have one-second resolution.
+const SkewTolerance = 2 * time.Second
+
+// sourceTimePaths are the payload fields holding the time GitHub last
+// changed the event's subject, per event type, in order of preference. The
+// delivery went out shortly after, so receive time minus this is the
+// delivery latency through GitHub and the relay.
+var sourceTimePaths = map[string][]string{
+	"check_run":                   {"check_run.completed_at", "check_run.started_at"},
+	"check_suite":                 {"check_suite.updated_at"},
+	"create":                      {"repository.pushed_at"},
+	"delete":                      {"repository.pushed_at"},
+	"deployment":                  {"deployment.updated_at"},
+	"deployment_status":           {"deployment_status.updated_at"},
+	"discussion":                  {"discussion.updated_at"},
+	"discussion_comment":          {"comment.updated_at"},
+	"issue_comment":               {"comment.updated_at"},
+	"issues":                      {"issue.updated_at"},
+	"merge_group":                 {"merge_group.updated_at"},
+	"pull_request":                {"pull_request.updated_at"},
+	"pull_request_review":         {"review.submitted_at"},
+	"push":                        {"repository.pushed_at"},
+	"release":                     {"release.published_at", "release.created_at"},
+	"status":                      {"updated_at"},
+	"workflow_job":                {"workflow_job.completed_at", "workflow_job.started_at", "workflow_job.created_at"},
+	"workflow_run":                {"workflow_run.updated_at"},
+	"pull_request_review_comment": {"comment.updated_at"},
+}
+
+// SourceTime returns the time GitHub stamped on the event's subject, for
+// event types that carry one.
+func SourceTime(msg message.EventMessage) (time.Time, bool) {
+	for _, path := range sourceTimePaths[msg.Event] {
+		if at, ok := timeAt(msg.Payload, strings.Split(path, ".")); ok {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeAt reads an RFC 3339 string or Unix seconds at keys in raw.
+func timeAt(raw json.RawMessage, keys []string) (time.Time, bool) {
+	for _, key := range keys {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return time.Time{}, false
+		}
+		if raw = fields[key]; raw == nil {
+			return time.Time{}, false
+		}
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		at, err := time.Parse(time.RFC3339, text)
+		return at, err == nil
+	}
+	var seconds int64
+	if err := json.Unmarshal(raw, &seconds); err == nil && seconds > 0 {
+		return time.Unix(seconds, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// Latency summarises delivery latency in milliseconds: the receive time
+// minus the event's own timestamp.
+type Latency struct {
+	Samples int   `json:"samples"`
+	P50     int64 `json:"p50_ms"`
+	P90     int64 `json:"p90_ms"`
+	P99     int64 `json:"p99_ms"`
+	Max     int64 `json:"max_ms"`
+	// Skewed counts events timestamped more than SkewTolerance after they
+	// were received, which points at a wrong local clock. They are left
+	// out of the percentiles.
+	Skewed int `json:"skewed,omitempty"`
+}
+
+// Latencies collects delivery latencies. It is not safe for concurrent use.
+type Latencies struct {
+	samples reservoir[int64]
+	skewed  int
+}
+
+// Add records the latency of msg, reporting false when it has no receive
+// time or timestamp. A latency below -SkewTolerance is counted as skew.
+func (l *Latencies) Add(msg message.EventMessage) (time.Duration, bool) {
+	if msg.ReceivedAt.IsZero() {
+		return 0, false
+	}
+	at, ok := SourceTime(msg)
+	if !ok {
+		return 0, false
+	}
+	latency := msg.ReceivedAt.Sub(at)
+	if latency < -SkewTolerance {
+		l.skewed++
+		return latency, true
+	}
+	l.samples.add(max(latency, 0).Milliseconds())
+	return latency, true
+}
+
+// Summary returns the percentiles of the latencies added so far, or nil
+// when there are none.
+func (l *Latencies) Summary() *Latency {
+	if l.samples.count == 0 && l.skewed == 0 {
+		return nil
+	}
+	summary := &Latency{Samples: l.samples.count, Skewed: l.skewed}
+	if l.samples.count == 0 {
+		return summary
+	}
+	sorted := l.samples.sorted()
+	summary.P50 = percentile(sorted, 50)
+	summary.P90 = percentile(sorted, 90)
+	summary.P99 = percentile(sorted, 99)
+	summary.Max = l.samples.max
+	return summary
+}
+
+// Reset discards the latencies added so far.
+func (l *Latencies) Reset() {
+	l.samples = reservoir[int64]{values: l.samples.values[:0]}
+	l.skewed = 0
+}
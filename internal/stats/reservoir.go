@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// reservoirSize is how many values a reservoir keeps. Percentiles over more
+// values than that are estimated from a uniform sample of them.
+const reservoirSize = 10000
+
+// reservoir keeps a uniform random sample of the values added (Algorithm R)
+// along with their exact count and maximum, so live stats (--url) summarise
+// a long run in bounded memory and time.
+type reservoir[T int | int64] struct {
+	values []T
+	count  int
+	max    T
+}
+
+func (r *reservoir[T]) add(value T) {
+	r.count++
+	if r.count == 1 || value > r.max {
+		r.max = value
+	}
+	if len(r.values) < reservoirSize {
+		r.values = append(r.values, value)
+		return
+	}
+	if i := rand.IntN(r.count); i < reservoirSize {
+		r.values[i] = value
+	}
+}
+
+// sorted returns the sample in ascending order.
+func (r *reservoir[T]) sorted() []T {
+	sorted := slices.Clone(r.values)
+	slices.Sort(sorted)
+	return sorted
+}
@@ -0,0 +1,142 @@
+// Package stats aggregates webhook events into summary reports.
+package stats
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// Report is the JSONL record emitted by the stats command.
+type Report struct {
+	Type         string         `json:"type"`
+	Events       int            `json:"events"`
+	ByEvent      map[string]int `json:"by_event"`
+	ByAction     map[string]int `json:"by_action"`
+	ByRepository map[string]int `json:"by_repository"`
+	PayloadBytes Sizes          `json:"payload_bytes"`
+	// Latency is the delivery latency of events that carry a GitHub
+	// timestamp.
+	Latency         *Latency   `json:"latency,omitempty"`
+	EventsPerMinute float64    `json:"events_per_minute"`
+	First           *time.Time `json:"first,omitempty"`
+	Last            *time.Time `json:"last,omitempty"`
+}
+
+// Sizes summarises payload sizes in bytes.
+type Sizes struct {
+	P50 int `json:"p50"`
+	P90 int `json:"p90"`
+	P99 int `json:"p99"`
+	Max int `json:"max"`
+}
+
+// Aggregator accumulates events for a Report. It is not safe for concurrent
+// use.
+type Aggregator struct {
+	events   int
+	byEvent  map[string]int
+	byAction map[string]int
+	byRepo   map[string]int
+	sizes    reservoir[int]
+	latency  Latencies
+	first    time.Time
+	last     time.Time
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		byEvent:  make(map[string]int),
+		byAction: make(map[string]int),
+		byRepo:   make(map[string]int),
+	}
+}
+
+// Add records msg. Events without a received_at timestamp are counted at
+// the current time.
+func (a *Aggregator) Add(msg message.EventMessage) {
+	var fields struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	_ = json.Unmarshal(msg.Payload, &fields)
+
+	a.events++
+	a.byEvent[msg.Event]++
+	if fields.Action != "" {
+		a.byAction[msg.Event+"."+fields.Action]++
+	}
+	if fields.Repository.FullName != "" {
+		a.byRepo[fields.Repository.FullName]++
+	}
+	a.sizes.add(len(msg.Payload))
+	a.latency.Add(msg)
+
+	at := msg.ReceivedAt
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	if a.first.IsZero() || at.Before(a.first) {
+		a.first = at
+	}
+	if at.After(a.last) {
+		a.last = at
+	}
+}
+
+// Report summarises the events added so far. since, when non-zero, is used
+// as the start of the rate window instead of the first event, so live
+// reports account for idle time before the first delivery.
+func (a *Aggregator) Report(since time.Time) Report {
+	report := Report{
+		Type:         "stats",
+		Events:       a.events,
+		ByEvent:      copyCounts(a.byEvent),
+		ByAction:     copyCounts(a.byAction),
+		ByRepository: copyCounts(a.byRepo),
+	}
+	if a.events == 0 {
+		return report
+	}
+
+	sizes := a.sizes.sorted()
+	report.PayloadBytes = Sizes{
+		P50: percentile(sizes, 50),
+		P90: percentile(sizes, 90),
+		P99: percentile(sizes, 99),
+		Max: a.sizes.max,
+	}
+
+	report.Latency = a.latency.Summary()
+
+	first, last := a.first, a.last
+	report.First, report.Last = &first, &last
+	start, end := first, last
+	if !since.IsZero() {
+		start, end = since, time.Now()
+	}
+	if minutes := end.Sub(start).Minutes(); minutes > 0 {
+		report.EventsPerMinute = float64(a.events) / minutes
+	}
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile[T int | int64](sorted []T, p int) T {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, value := range counts {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,287 @@
+// Package tui implements the interactive event browser behind `gh-pulse watch`.
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// EventMsg delivers a webhook event to the program.
+type EventMsg message.EventMessage
+
+// StatusMsg updates the connection status line.
+type StatusMsg string
+
+type item struct {
+	msg        message.EventMessage
+	receivedAt time.Time
+	action     string
+	repo       string
+	search     string
+	pretty     []string
+}
+
+func newItem(msg message.EventMessage, receivedAt time.Time) item {
+	var fields struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	_ = json.Unmarshal(msg.Payload, &fields)
+	return item{
+		msg:        msg,
+		receivedAt: receivedAt,
+		action:     fields.Action,
+		repo:       fields.Repository.FullName,
+		search:     strings.ToLower(msg.Event + " " + msg.DeliveryID + " " + string(msg.Payload)),
+	}
+}
+
+// prettyLines lazily renders the indented payload for the detail pane.
+func (it *item) prettyLines() []string {
+	if it.pretty == nil {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, it.msg.Payload, "", "  "); err != nil {
+			buf.Reset()
+			buf.Write(it.msg.Payload)
+		}
+		it.pretty = strings.Split(buf.String(), "\n")
+	}
+	return it.pretty
+}
+
+// Model is the bubbletea model for the event browser.
+type Model struct {
+	title     string
+	status    string
+	items     []item
+	pending   []item
+	visible   []int
+	cursor    int
+	offset    int
+	detailTop int
+	follow    bool
+	paused    bool
+	searching bool
+	query     string
+	width     int
+	height    int
+}
+
+func NewModel(title string) Model {
+	return Model{title: title, status: "connecting", follow: true, width: 80, height: 24}
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case StatusMsg:
+		m.status = string(msg)
+	case EventMsg:
+		it := newItem(message.EventMessage(msg), time.Now())
+		if m.paused {
+			m.pending = append(m.pending, it)
+			return m, nil
+		}
+		m.appendItems(it)
+	case tea.KeyMsg:
+		if m.searching {
+			return m.updateSearch(msg), nil
+		}
+		return m.updateKeys(msg)
+	}
+	return m, nil
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) Model {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searching = false
+	case tea.KeyEsc:
+		m.searching = false
+		m.query = ""
+	case tea.KeyBackspace:
+		if m.query != "" {
+			runes := []rune(m.query)
+			m.query = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.query += string(msg.Runes)
+	}
+	m.refilter()
+	return m
+}
+
+func (m Model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.listHeight())
+	case "pgdown":
+		m.move(m.listHeight())
+	case "g", "home":
+		m.move(-len(m.visible))
+	case "G", "end":
+		m.move(len(m.visible))
+	case "ctrl+u":
+		m.detailTop = max(0, m.detailTop-m.detailHeight()/2)
+	case "ctrl+d":
+		m.detailTop += m.detailHeight() / 2
+	case "p", " ":
+		m.paused = !m.paused
+		if !m.paused {
+			pending := m.pending
+			m.pending = nil
+			m.appendItems(pending...)
+		}
+	case "/":
+		m.searching = true
+	case "esc":
+		m.query = ""
+		m.refilter()
+	}
+	return m, nil
+}
+
+func (m *Model) appendItems(items ...item) {
+	for _, it := range items {
+		m.items = append(m.items, it)
+		if m.matches(it) {
+			m.visible = append(m.visible, len(m.items)-1)
+		}
+	}
+	if m.follow && len(m.visible) > 0 {
+		m.cursor = len(m.visible) - 1
+		m.detailTop = 0
+		m.scrollToCursor()
+	}
+}
+
+func (m *Model) matches(it item) bool {
+	return m.query == "" || strings.Contains(it.search, strings.ToLower(m.query))
+}
+
+func (m *Model) refilter() {
+	m.visible = m.visible[:0]
+	for i, it := range m.items {
+		if m.matches(it) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = max(0, len(m.visible)-1)
+	m.follow = true
+	m.detailTop = 0
+	m.scrollToCursor()
+}
+
+func (m *Model) move(delta int) {
+	if len(m.visible) == 0 {
+		return
+	}
+	m.cursor = min(max(m.cursor+delta, 0), len(m.visible)-1)
+	m.follow = m.cursor == len(m.visible)-1
+	m.detailTop = 0
+	m.scrollToCursor()
+}
+
+func (m *Model) scrollToCursor() {
+	height := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+height {
+		m.offset = m.cursor - height + 1
+	}
+}
+
+// listHeight is the number of event rows; the rest of the screen below the
+// header and above the footer goes to the detail pane.
+func (m Model) listHeight() int {
+	return max(3, (m.height-3)*2/5)
+}
+
+func (m Model) detailHeight() int {
+	return max(1, m.height-3-m.listHeight()-1)
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	state := "live"
+	if m.paused {
+		state = fmt.Sprintf("paused (%d pending)", len(m.pending))
+	}
+	b.WriteString(m.fit(fmt.Sprintf("gh-pulse watch  %s  [%s]  %d/%d events  %s", m.title, state, len(m.visible), len(m.items), m.status)))
+	b.WriteString("\n")
+	b.WriteString(m.fit(fmt.Sprintf("%-8s  %-24s  %-16s  %s", "TIME", "EVENT", "ACTION", "REPOSITORY")))
+	b.WriteString("\n")
+
+	for row := 0; row < m.listHeight(); row++ {
+		idx := m.offset + row
+		if idx < len(m.visible) {
+			it := m.items[m.visible[idx]]
+			line := m.fit(fmt.Sprintf("%-8s  %-24s  %-16s  %s", it.receivedAt.Format("15:04:05"), clip(it.msg.Event, 24), clip(it.action, 16), it.repo))
+			if idx == m.cursor {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.fit(strings.Repeat("─", m.width)))
+	b.WriteString("\n")
+	var detail []string
+	if m.cursor < len(m.visible) {
+		it := &m.items[m.visible[m.cursor]]
+		detail = append([]string{"delivery " + it.msg.DeliveryID}, it.prettyLines()...)
+	}
+	top := min(m.detailTop, max(0, len(detail)-1))
+	for row := 0; row < m.detailHeight(); row++ {
+		if top+row < len(detail) {
+			b.WriteString(m.fit(detail[top+row]))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.searching {
+		b.WriteString(m.fit("/" + m.query + "█"))
+	} else if m.query != "" {
+		b.WriteString(m.fit(fmt.Sprintf("filter: %q  (esc clears)  j/k move  ctrl+d/u scroll  p pause  / search  q quit", m.query)))
+	} else {
+		b.WriteString(m.fit("j/k move  ctrl+d/u scroll payload  p pause  / search  q quit"))
+	}
+	return b.String()
+}
+
+func (m Model) fit(line string) string {
+	return clip(line, m.width)
+}
+
+func clip(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "…"
+}
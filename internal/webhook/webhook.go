@@ -0,0 +1,53 @@
+// Package webhook delivers events to an HTTP endpoint the way GitHub does.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/kehao95/gh-pulse/internal/message"
+)
+
+// UserAgent mimics GitHub's delivery agent so handlers that check it accept
+// replayed events.
+const UserAgent = "GitHub-Hookshot/gh-pulse"
+
+// Sign returns the X-Hub-Signature-256 header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post sends the event's payload to target with GitHub's webhook headers and
+// returns the response status code. The payload is signed when secret is set.
+func Post(ctx context.Context, httpClient *http.Client, target string, msg message.EventMessage, secret string) (int, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("X-GitHub-Event", msg.Event)
+	req.Header.Set("X-GitHub-Delivery", msg.DeliveryID)
+	if secret != "" {
+		req.Header.Set("X-Hub-Signature-256", Sign(secret, msg.Payload))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deliver %s: %w", msg.DeliveryID, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
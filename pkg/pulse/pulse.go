@@ -0,0 +1,125 @@
+// Package pulse subscribes to GitHub webhooks relayed through a smee.io
+// channel, for Go programs that want to embed gh-pulse instead of running the
+// binary.
+//
+//	events, err := pulse.Subscribe(ctx, pulse.Options{URL: "https://smee.io/my-channel"})
+//	if err != nil {
+//		return err
+//	}
+//	done, _ := pulse.ParseAssertion("event=push")
+//	for event := range events {
+//		if pulse.Matches(event, done) {
+//			break
+//		}
+//	}
+package pulse
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kehao95/gh-pulse/internal/assertion"
+	"github.com/kehao95/gh-pulse/internal/client"
+	"github.com/kehao95/gh-pulse/internal/filter"
+	"github.com/kehao95/gh-pulse/internal/message"
+	"github.com/kehao95/gh-pulse/internal/sse"
+)
+
+// Event is the envelope gh-pulse emits for each webhook delivery.
+type Event = message.EventMessage
+
+// Assertion is a parsed path condition such as "event=push".
+type Assertion = assertion.Assertion
+
+// Delivery is an event moving through a handler chain. Stages read and
+// replace Delivery.Message; use SetMessage so cached encodings are dropped.
+type Delivery = client.Delivery
+
+// Handler processes a delivery; returning an error stops the subscription.
+type Handler = client.Handler
+
+type HandlerFunc = client.HandlerFunc
+
+// Middleware is a pipeline stage such as a filter, transform, or side-effect
+// sink. A stage drops a delivery by returning nil without calling next.
+type Middleware = client.Middleware
+
+// Chain builds a handler that runs stages in order and then final.
+func Chain(final Handler, stages ...Middleware) Handler {
+	return client.Chain(final, stages...)
+}
+
+// Options configures Subscribe.
+type Options struct {
+	// URL is the smee.io channel to connect to.
+	URL string
+	// Events restricts delivery to these GitHub event types. Empty means all.
+	Events []string
+	// Buffer is the capacity of the returned channel. Defaults to 16.
+	Buffer int
+	// Logger receives connection status messages. Nil disables logging.
+	Logger *log.Logger
+	// HTTPClient is used for the SSE connection. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Middleware stages run, in order, on each event that passes the Events
+	// filter before it is sent on the channel.
+	Middleware []Middleware
+}
+
+// Subscribe connects to the channel and returns events as they arrive. The
+// connection is retried with backoff until ctx is cancelled, at which point
+// the channel is closed.
+func Subscribe(ctx context.Context, opts Options) (<-chan Event, error) {
+	if err := client.ValidateURL(opts.URL); err != nil {
+		return nil, err
+	}
+	size := opts.Buffer
+	if size <= 0 {
+		size = 16
+	}
+	sseClient := sse.NewClient(opts.URL, opts.Logger)
+	if opts.HTTPClient != nil {
+		sseClient.HTTPClient = opts.HTTPClient
+	}
+
+	events := make(chan Event, size)
+	send := HandlerFunc(func(d *Delivery) error {
+		select {
+		case events <- d.Message:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	handler := Chain(send, opts.Middleware...)
+	go func() {
+		defer close(events)
+		_ = sseClient.Run(ctx, func(msg message.EventMessage) error {
+			if !filter.Event(opts.Events, msg.Event) {
+				return nil
+			}
+			return handler.Handle(&Delivery{Message: msg, ReceivedAt: time.Now()})
+		})
+	}()
+	return events, nil
+}
+
+// ParseAssertion parses an assertion in the CLI syntax: "path=value",
+// "path=~regex", "path exists", "path any=value", "path all=value", or
+// "path#length>=n". Paths are resolved against the envelope, e.g. "event" or
+// "payload.action".
+func ParseAssertion(input string) (Assertion, error) {
+	return assertion.ParseAssertion(input, 0)
+}
+
+// Matches reports whether event satisfies any of the assertions.
+func Matches(event Event, assertions ...Assertion) bool {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	return assertion.MatchAny(encoded, assertions)
+}